@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -41,6 +42,10 @@ func main() {
 	firstNode.Connect(outNode)
 	outNode.Connect(inputNode)
 
+	graph := nlib.NewGraph(inputNode)
+	graph.Start(context.Background())
+	defer graph.Stop()
+
 	signal := node.Signal{NodeID: inputNode.ID()}
 	// Send it
 	inputNode.InputCh() <- signal
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
@@ -33,6 +34,11 @@ func main() {
 	// Connect
 	firstNode.Connect(outNode)
 
+	// Start the nodes and stop them when done
+	graph := nlib.NewGraph(firstNode)
+	graph.Start(context.Background())
+	defer graph.Stop()
+
 	sig := node.Signal{
 		NodeID: firstNode.ID(),
 		Task:   &nlib.Carrier{TextData: "Why is the sky blue?"},
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -45,6 +46,10 @@ func main() {
 	taskNode.Connect(validateNode)
 	validateNode.Connect(outNode)
 
+	graph := nlib.NewGraph(taskNode)
+	graph.Start(context.Background())
+	defer graph.Stop()
+
 	taskNode.InputCh() <- sig
 
 	stateMgr.WaitFor(outNode)
@@ -17,6 +17,7 @@ const (
 
 // Compile-time check
 var _ llm.LLM = (*Anthropic)(nil)
+var _ llm.ToolChatter = (*Anthropic)(nil)
 
 type Anthropic struct {
 	model      string
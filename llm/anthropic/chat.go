@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/dshills/wiggle/llm"
 )
@@ -18,33 +19,61 @@ func (ant *Anthropic) GenerateResponse(info string, instruct string) (string, er
 	return resp.Content, err
 }
 
-func (ant *Anthropic) Chat(ctx context.Context, msgs llm.MessageList) (llm.Message, error) {
+func (ant *Anthropic) Chat(ctx context.Context, msgs llm.MessageList) (llm.Response, error) {
+	return ant.ChatWithTools(ctx, msgs, nil)
+}
+
+// ChatWithTools is Chat offering the tools to the model
+func (ant *Anthropic) ChatWithTools(ctx context.Context, msgs llm.MessageList, tools []llm.Tool) (llm.Response, error) {
+	start := time.Now()
+	system, messages := toMessages(msgs)
 	oreq := chatRequest{
 		Stream:    false,
-		Messages:  msgs,
+		System:    system,
+		Messages:  messages,
+		Tools:     toTools(tools),
 		Model:     ant.model,
 		MaxTokens: ant.maxTokens,
 	}
 	js, err := json.Marshal(&oreq)
 	if err != nil {
-		return llm.Message{}, err
+		return llm.Response{}, err
 	}
 	reader := bytes.NewReader(js)
 	resp, err := ant.send(ctx, ant.baseURL, reader)
 	if err != nil {
-		return llm.Message{}, err
+		return llm.Response{}, err
 	}
 	if resp == nil || len(resp.Content) == 0 {
-		return llm.Message{}, fmt.Errorf("nothing returned")
+		return llm.Response{}, fmt.Errorf("nothing returned")
 	}
-	msg := llm.Message{
-		Role:    llm.RoleAssistant,
-		Content: resp.Content[0].Text,
-	}
-	return msg, nil
+	return llm.Response{
+		Message:      toLLM(resp.Content),
+		Usage:        resp.Usage.toLLM(),
+		FinishReason: finishReason(resp.StopReason),
+		Model:        resp.Model,
+		Latency:      time.Since(start),
+	}, nil
 }
 
 func (ant *Anthropic) send(ctx context.Context, baseURL string, reader io.Reader) (*chatResponse, error) {
+	resp, err := ant.post(ctx, baseURL, reader)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	chatResp := chatResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&chatResp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chatResp, nil
+}
+
+// post sends a chat request and returns the response for the caller to read and close
+func (ant *Anthropic) post(ctx context.Context, baseURL string, reader io.Reader) (*http.Response, error) {
 	const chatEndpoint = "/v1//messages"
 
 	ep, err := url.JoinPath(baseURL, chatEndpoint)
@@ -65,34 +94,24 @@ func (ant *Anthropic) send(ctx context.Context, baseURL string, reader io.Reader
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
-		var byts []byte
-		if resp != nil {
-			byts, _ = io.ReadAll(resp.Body)
-		}
-		return nil, fmt.Errorf("ERROR: %v %v %v", resp.StatusCode, resp.Status, string(byts))
+		defer resp.Body.Close()
+		return nil, llm.NewHTTPError("Anthropic: Chat", resp)
 	}
-
-	chatResp := chatResponse{}
-	err = json.NewDecoder(resp.Body).Decode(&chatResp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &chatResp, nil
+	return resp, nil
 }
 
 type chatRequest struct {
-	Model         string        `json:"model,omitempty"`          // REQUIRED
-	MaxTokens     int           `json:"max_tokens,omitempty"`     // The maximum number of tokens to generate before stopping.
-	Messages      []llm.Message `json:"messages,omitempty"`       // REQUIRED
-	MetaData      MetaData      `json:"metadata,omitempty"`       // Set a user id
-	StopSequences []string      `json:"stop_sequences,omitempty"` // Set of text strings that will trigger a stop
-	Stream        bool          `json:"stream,omitempty"`         // Whether to incrementally stream the response using server-sent events.
-	System        string        `json:"system"`                   // System prompt
-	Temperature   float32       `json:"temperature,omitempty"`    // Amount of randomness injected into the response. 0.0 - 1.0
+	Model         string    `json:"model,omitempty"`          // REQUIRED
+	MaxTokens     int       `json:"max_tokens,omitempty"`     // The maximum number of tokens to generate before stopping.
+	Messages      []message `json:"messages,omitempty"`       // REQUIRED
+	MetaData      MetaData  `json:"metadata,omitempty"`       // Set a user id
+	StopSequences []string  `json:"stop_sequences,omitempty"` // Set of text strings that will trigger a stop
+	Stream        bool      `json:"stream,omitempty"`         // Whether to incrementally stream the response using server-sent events.
+	System        string    `json:"system,omitempty"`         // System prompt
+	Temperature   float32   `json:"temperature,omitempty"`    // Amount of randomness injected into the response. 0.0 - 1.0
+	Tools         []tool    `json:"tools,omitempty"`          // Tools the model may use
 }
 
 type MetaData struct {
@@ -100,19 +119,10 @@ type MetaData struct {
 }
 
 type chatResponse struct {
-	ID      string `json:"id"`
-	Content []struct {
-		Text  string `json:"text,omitempty"`
-		ID    string `json:"id,omitempty"`
-		Name  string `json:"name,omitempty"`
-		Input struct {
-		} `json:"input,omitempty"`
-	} `json:"content"`
-	Model        string  `json:"model"`
-	StopReason   string  `json:"stop_reason"`
-	StopSequence *string `json:"stop_sequence"`
-	Usage        struct {
-		InputTokens  int `json:"input_tokens"`
-		OutputTokens int `json:"output_tokens"`
-	} `json:"usage"`
+	ID           string         `json:"id"`
+	Content      []contentBlock `json:"content"`
+	Model        string         `json:"model"`
+	StopReason   string         `json:"stop_reason"`
+	StopSequence *string        `json:"stop_sequence"`
+	Usage        usage          `json:"usage"`
 }
@@ -6,9 +6,11 @@ package anthropic
 import (
 	"context"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/schema"
 )
 
 func TestChat(t *testing.T) {
@@ -29,4 +31,99 @@ func TestChat(t *testing.T) {
 	if respMsg.Content == "" {
 		t.Errorf("Expected a response got none")
 	}
+	if respMsg.Usage.TotalTokens == 0 || respMsg.Model == "" {
+		t.Errorf("Expected usage and model got %+v", respMsg.Usage)
+	}
+	if respMsg.FinishReason != llm.FinishStop {
+		t.Errorf("Expected finish reason %s got %s", llm.FinishStop, respMsg.FinishReason)
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	baseURL := os.Getenv("ANTHROPIC_API_URL")
+	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	maxTokens := 1024
+	ant := New(baseURL, ModelSonnet35, apiKey, maxTokens)
+
+	ctx := context.TODO()
+	msgs := llm.MessageList{
+		llm.Message{Role: llm.RoleUser, Content: "Why is the sky blue?"},
+	}
+	events, err := ant.ChatStream(ctx, msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deltas := 0
+	respMsg, err := llm.Collect(events, func(string) { deltas++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if respMsg.Content == "" || deltas == 0 {
+		t.Errorf("Expected a streamed response got none")
+	}
+}
+
+func TestChatTools(t *testing.T) {
+	baseURL := os.Getenv("ANTHROPIC_API_URL")
+	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	ant := New(baseURL, ModelSonnet35, apiKey, 1024)
+
+	weather := llm.Tool{
+		Name:        "get_weather",
+		Description: "Get the current weather in a city",
+		Parameters: schema.Schema{
+			Type:       "object",
+			Properties: map[string]schema.Schema{"city": {Type: "string", Description: "Name of the city"}},
+			Required:   []string{"city"},
+		},
+	}
+	ctx := context.TODO()
+	tools := []llm.Tool{weather}
+	msgs := llm.MessageList{
+		llm.Message{Role: llm.RoleUser, Content: "What is the weather in Paris?"},
+	}
+	respMsg, err := ant.ChatWithTools(ctx, msgs, tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(respMsg.ToolCalls) == 0 {
+		t.Fatalf("Expected a tool call got %+v", respMsg)
+	}
+	if respMsg.FinishReason != llm.FinishToolCalls {
+		t.Errorf("Expected finish reason %s got %s", llm.FinishToolCalls, respMsg.FinishReason)
+	}
+	args := struct{ City string }{}
+	if err := respMsg.ToolCalls[0].DecodeArguments(&args); err != nil || args.City == "" {
+		t.Fatalf("Expected a city got %v %v", args, err)
+	}
+
+	msgs = append(msgs, respMsg.Message, llm.ToolResultMsg(respMsg.ToolCalls[0], "Sunny, 21C"))
+	respMsg, err = ant.ChatWithTools(ctx, msgs, tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if respMsg.Content == "" {
+		t.Errorf("Expected a response got none")
+	}
+}
+
+func TestChatConversation(t *testing.T) {
+	baseURL := os.Getenv("ANTHROPIC_API_URL")
+	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	ant := New(baseURL, ModelSonnet35, apiKey, 1024)
+
+	msgs := llm.MessageList{
+		llm.SystemMsg("Answer with a single word."),
+		llm.UserMsg("My favorite color is green."),
+		llm.AssistantMsg("Noted."),
+		llm.UserMsg("What is my favorite color?"),
+	}
+	respMsg, err := ant.Chat(context.TODO(), msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.ToLower(respMsg.Content), "green") {
+		t.Errorf("Expected the conversation to be remembered got %q", respMsg.Content)
+	}
 }
@@ -0,0 +1,125 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/schema"
+)
+
+// message is a chat message in the Anthropic format
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+// contentBlock is a part of a message. The fields set depend on its type: text,
+// tool_use for a tool call by the model, or tool_result for the result of a call.
+type contentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+type tool struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	InputSchema schema.Schema `json:"input_schema"`
+}
+
+// toMessages converts the messages to the Anthropic format. System messages are
+// returned as the system prompt, since Anthropic does not accept them in the messages.
+// Tool results are sent in user messages, and consecutive messages with the same role
+// are combined into one message as Anthropic expects the roles to alternate. Messages
+// without content are left out, Anthropic rejects empty text blocks.
+func toMessages(msgs llm.MessageList) (string, []message) {
+	system := []string{}
+	messages := []message{}
+	for _, m := range msgs {
+		if m.Role == llm.RoleSystem {
+			if m.Content != "" {
+				system = append(system, m.Content)
+			}
+			continue
+		}
+
+		msg := message{Role: m.Role}
+		switch {
+		case m.ToolResult != nil:
+			msg.Role = llm.RoleUser
+			msg.Content = append(msg.Content, contentBlock{
+				Type:      "tool_result",
+				ToolUseID: m.ToolResult.CallID,
+				Content:   m.ToolResult.Content,
+				IsError:   m.ToolResult.IsError,
+			})
+		case m.Content != "":
+			msg.Content = append(msg.Content, contentBlock{Type: "text", Text: m.Content})
+		}
+		for _, c := range m.ToolCalls {
+			input := c.Arguments
+			if len(input) == 0 {
+				input = json.RawMessage("{}")
+			}
+			msg.Content = append(msg.Content, contentBlock{Type: "tool_use", ID: c.ID, Name: c.Name, Input: input})
+		}
+		if len(msg.Content) == 0 {
+			continue
+		}
+
+		if last := len(messages) - 1; last >= 0 && messages[last].Role == msg.Role {
+			messages[last].Content = append(messages[last].Content, msg.Content...)
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return strings.Join(system, "\n\n"), messages
+}
+
+// toLLM converts the content returned by Anthropic to an assistant message
+func toLLM(blocks []contentBlock) llm.Message {
+	msg := llm.Message{Role: llm.RoleAssistant}
+	text := strings.Builder{}
+	for _, b := range blocks {
+		switch b.Type {
+		case "text":
+			text.WriteString(b.Text)
+		case "tool_use":
+			input := b.Input
+			if len(input) == 0 {
+				input = json.RawMessage("{}")
+			}
+			msg.ToolCalls = append(msg.ToolCalls, llm.ToolCall{ID: b.ID, Name: b.Name, Arguments: input})
+		}
+	}
+	msg.Content = text.String()
+	return msg
+}
+
+// finishReason translates the reason Anthropic stopped generating a response
+func finishReason(reason string) string {
+	switch reason {
+	case "end_turn", "stop_sequence":
+		return llm.FinishStop
+	case "max_tokens":
+		return llm.FinishLength
+	case "tool_use":
+		return llm.FinishToolCalls
+	}
+	return reason
+}
+
+// toTools converts the tools to the Anthropic format
+func toTools(tools []llm.Tool) []tool {
+	out := []tool{}
+	for _, t := range tools {
+		out = append(out, tool{Name: t.Name, Description: t.Description, InputSchema: t.Parameters})
+	}
+	return out
+}
@@ -0,0 +1,119 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToMessages(t *testing.T) {
+	call := llm.ToolCall{ID: "toolu_1", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)}
+	tests := []struct {
+		name     string
+		msgs     llm.MessageList
+		system   string
+		messages []message
+	}{
+		{
+			name:     "user",
+			msgs:     llm.MessageList{llm.UserMsg("Hi")},
+			messages: []message{{Role: "user", Content: []contentBlock{{Type: "text", Text: "Hi"}}}},
+		},
+		{
+			name:     "system prompts",
+			msgs:     llm.MessageList{llm.SystemMsg("Be brief"), llm.UserMsg("Hi"), llm.SystemMsg("Use French"), llm.SystemMsg("")},
+			system:   "Be brief\n\nUse French",
+			messages: []message{{Role: "user", Content: []contentBlock{{Type: "text", Text: "Hi"}}}},
+		},
+		{
+			name: "same roles combined",
+			msgs: llm.MessageList{llm.UserMsg("Hi"), llm.UserMsg("Anyone?"), llm.AssistantMsg("Hello")},
+			messages: []message{
+				{Role: "user", Content: []contentBlock{{Type: "text", Text: "Hi"}, {Type: "text", Text: "Anyone?"}}},
+				{Role: "assistant", Content: []contentBlock{{Type: "text", Text: "Hello"}}},
+			},
+		},
+		{
+			name: "empty messages left out",
+			msgs: llm.MessageList{llm.UserMsg("Hi"), llm.AssistantMsg(""), llm.UserMsg("")},
+			messages: []message{
+				{Role: "user", Content: []contentBlock{{Type: "text", Text: "Hi"}}},
+			},
+		},
+		{
+			name: "tool calls and results",
+			msgs: llm.MessageList{
+				llm.UserMsg("Weather?"),
+				{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{call, {ID: "toolu_2", Name: "time"}}},
+				llm.ToolResultMsg(call, "21C"),
+				llm.ToolErrorMsg(llm.ToolCall{ID: "toolu_2", Name: "time"}, errors.New("no clock")),
+			},
+			messages: []message{
+				{Role: "user", Content: []contentBlock{{Type: "text", Text: "Weather?"}}},
+				{Role: "assistant", Content: []contentBlock{
+					{Type: "tool_use", ID: "toolu_1", Name: "weather", Input: json.RawMessage(`{"city":"Paris"}`)},
+					{Type: "tool_use", ID: "toolu_2", Name: "time", Input: json.RawMessage(`{}`)},
+				}},
+				{Role: "user", Content: []contentBlock{
+					{Type: "tool_result", ToolUseID: "toolu_1", Content: "21C"},
+					{Type: "tool_result", ToolUseID: "toolu_2", Content: "no clock", IsError: true},
+				}},
+			},
+		},
+		{
+			name: "text with tool calls",
+			msgs: llm.MessageList{{Role: llm.RoleAssistant, Content: "Checking", ToolCalls: []llm.ToolCall{call}}},
+			messages: []message{{Role: "assistant", Content: []contentBlock{
+				{Type: "text", Text: "Checking"},
+				{Type: "tool_use", ID: "toolu_1", Name: "weather", Input: json.RawMessage(`{"city":"Paris"}`)},
+			}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			system, messages := toMessages(tt.msgs)
+			assert.Equal(t, tt.system, system)
+			assert.Equal(t, tt.messages, messages)
+		})
+	}
+}
+
+func TestToLLM(t *testing.T) {
+	msg := toLLM([]contentBlock{
+		{Type: "text", Text: "Let me "},
+		{Type: "text", Text: "check"},
+		{Type: "tool_use", ID: "toolu_1", Name: "weather", Input: json.RawMessage(`{"city":"Paris"}`)},
+		{Type: "tool_use", ID: "toolu_2", Name: "time"},
+	})
+	assert.Equal(t, llm.Message{
+		Role:    llm.RoleAssistant,
+		Content: "Let me check",
+		ToolCalls: []llm.ToolCall{
+			{ID: "toolu_1", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)},
+			{ID: "toolu_2", Name: "time", Arguments: json.RawMessage(`{}`)},
+		},
+	}, msg)
+}
+
+func TestToTools(t *testing.T) {
+	params := schema.Schema{Type: "object"}
+	tools := toTools([]llm.Tool{{Name: "weather", Description: "Current weather", Parameters: params}})
+	assert.Equal(t, []tool{{Name: "weather", Description: "Current weather", InputSchema: params}}, tools)
+}
+
+func TestFinishReason(t *testing.T) {
+	tests := map[string]string{
+		"end_turn":      llm.FinishStop,
+		"stop_sequence": llm.FinishStop,
+		"max_tokens":    llm.FinishLength,
+		"tool_use":      llm.FinishToolCalls,
+		"refusal":       "refusal",
+	}
+	for reason, want := range tests {
+		assert.Equal(t, want, finishReason(reason), reason)
+	}
+}
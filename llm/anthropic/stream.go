@@ -0,0 +1,130 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dshills/wiggle/llm"
+)
+
+// Compile-time check
+var _ llm.Streamer = (*Anthropic)(nil)
+var _ llm.ToolStreamer = (*Anthropic)(nil)
+
+// ChatStream streams the response to msgs as it is generated
+func (ant *Anthropic) ChatStream(ctx context.Context, msgs llm.MessageList) (<-chan llm.StreamEvent, error) {
+	return ant.ChatStreamWithTools(ctx, msgs, nil)
+}
+
+// ChatStreamWithTools is ChatStream offering the tools to the model
+func (ant *Anthropic) ChatStreamWithTools(ctx context.Context, msgs llm.MessageList, tools []llm.Tool) (<-chan llm.StreamEvent, error) {
+	system, messages := toMessages(msgs)
+	start := time.Now()
+	oreq := chatRequest{
+		Stream:    true,
+		System:    system,
+		Messages:  messages,
+		Tools:     toTools(tools),
+		Model:     ant.model,
+		MaxTokens: ant.maxTokens,
+	}
+	js, err := json.Marshal(&oreq)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ant.post(ctx, ant.baseURL, bytes.NewReader(js))
+	if err != nil {
+		return nil, err
+	}
+	return llm.Stream(ctx, resp.Body, func(delta func(string)) (llm.Response, error) {
+		reply := llm.Response{}
+		use := usage{}
+		blocks := []contentBlock{}
+		inputs := map[int]*strings.Builder{} // Tool call arguments streamed in pieces
+		err := llm.ReadSSE(resp.Body, func(event, data string) error {
+			ev := streamEvent{}
+			if err := json.Unmarshal([]byte(data), &ev); err != nil {
+				return fmt.Errorf("Anthropic: ChatStream: %w", err)
+			}
+			switch event {
+			case "message_start":
+				use = ev.Message.Usage
+				reply.Model = ev.Message.Model
+			case "content_block_start":
+				if ev.Index >= len(blocks) {
+					blocks = append(blocks, make([]contentBlock, ev.Index-len(blocks)+1)...)
+				}
+				blocks[ev.Index] = ev.ContentBlock
+				if ev.ContentBlock.Type == "tool_use" {
+					inputs[ev.Index] = &strings.Builder{}
+				}
+			case "content_block_delta":
+				if ev.Index >= len(blocks) {
+					return fmt.Errorf("Anthropic: ChatStream: delta for unknown content block %d", ev.Index)
+				}
+				switch ev.Delta.Type {
+				case "text_delta":
+					blocks[ev.Index].Text += ev.Delta.Text
+					delta(ev.Delta.Text)
+				case "input_json_delta":
+					if in := inputs[ev.Index]; in != nil {
+						in.WriteString(ev.Delta.PartialJSON)
+					}
+				}
+			case "message_delta":
+				use.OutputTokens = ev.Usage.OutputTokens
+				reply.FinishReason = finishReason(ev.Delta.StopReason)
+			case "message_stop":
+				return llm.ErrStopSSE
+			case "error":
+				return fmt.Errorf("Anthropic: ChatStream: %s: %s", ev.Error.Type, ev.Error.Message)
+			}
+			return nil
+		})
+		for i, in := range inputs {
+			if json.Valid([]byte(in.String())) {
+				blocks[i].Input = json.RawMessage(in.String())
+			}
+		}
+		reply.Message = toLLM(blocks)
+		reply.Usage = use.toLLM()
+		reply.Latency = time.Since(start)
+		return reply, err
+	}), nil
+}
+
+// streamEvent is the data of a server-sent event of a streamed response. The
+// fields set depend on the type of the event.
+type streamEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"` // content_block_start and content_block_delta
+	Message struct {
+		Model string `json:"model"`
+		Usage usage  `json:"usage"`
+	} `json:"message"` // message_start
+	ContentBlock contentBlock `json:"content_block"` // content_block_start
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"` // content_block_delta and message_delta
+	Usage usage `json:"usage"` // message_delta
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"` // error
+}
+
+type usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func (u usage) toLLM() llm.Usage {
+	return llm.Usage{PromptTokens: u.InputTokens, CompletionTokens: u.OutputTokens, TotalTokens: u.InputTokens + u.OutputTokens}
+}
@@ -0,0 +1,139 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestServer replies to every request with body, sending the decoded request on reqs
+func newTestServer(t *testing.T, body string, reqs chan<- chatRequest) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := chatRequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		reqs <- req
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+const textStream = `event: message_start
+data: {"type":"message_start","message":{"model":"claude-3-5-sonnet-20240620","usage":{"input_tokens":12,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" there"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+const toolStream = `event: message_start
+data: {"type":"message_start","message":{"model":"claude-3-5-sonnet-20240620","usage":{"input_tokens":30,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"weather","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+func TestChatStreamOffline(t *testing.T) {
+	reqs := make(chan chatRequest, 1)
+	srv := newTestServer(t, textStream, reqs)
+	ant := New(srv.URL, ModelSonnet35, "key", 0)
+
+	events, err := ant.ChatStream(context.Background(), llm.MessageList{llm.SystemMsg("Be brief"), llm.UserMsg("Hi")})
+	assert.NoError(t, err)
+	deltas := []string{}
+	resp, err := llm.Collect(events, func(d string) { deltas = append(deltas, d) })
+	assert.NoError(t, err)
+
+	req := <-reqs
+	assert.True(t, req.Stream)
+	assert.Equal(t, "Be brief", req.System)
+	assert.Len(t, req.Messages, 1)
+
+	assert.Equal(t, []string{"Hello", " there"}, deltas)
+	assert.Equal(t, "Hello there", resp.Content)
+	assert.Equal(t, llm.RoleAssistant, resp.Role)
+	assert.Equal(t, llm.Usage{PromptTokens: 12, CompletionTokens: 5, TotalTokens: 17}, resp.Usage)
+	assert.Equal(t, llm.FinishStop, resp.FinishReason)
+	assert.Equal(t, ModelSonnet35, resp.Model)
+	assert.Positive(t, resp.Latency)
+}
+
+func TestChatStreamOffline_ToolCalls(t *testing.T) {
+	reqs := make(chan chatRequest, 1)
+	srv := newTestServer(t, toolStream, reqs)
+	ant := New(srv.URL, ModelSonnet35, "key", 0)
+
+	events, err := ant.ChatStreamWithTools(context.Background(), llm.MessageList{llm.UserMsg("Weather?")}, []llm.Tool{{Name: "weather"}})
+	assert.NoError(t, err)
+	resp, err := llm.Collect(events, nil)
+	assert.NoError(t, err)
+
+	assert.Len(t, (<-reqs).Tools, 1)
+	assert.Equal(t, []llm.ToolCall{{ID: "toolu_1", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)}}, resp.ToolCalls)
+	assert.Equal(t, llm.FinishToolCalls, resp.FinishReason)
+}
+
+func TestChatStreamOffline_Error(t *testing.T) {
+	body := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"m\"}}\n\n" +
+		"event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"
+	srv := newTestServer(t, body, make(chan chatRequest, 1))
+	ant := New(srv.URL, ModelSonnet35, "key", 0)
+
+	events, err := ant.ChatStream(context.Background(), llm.MessageList{llm.UserMsg("Hi")})
+	assert.NoError(t, err)
+	_, err = llm.Collect(events, nil)
+	assert.EqualError(t, err, "Anthropic: ChatStream: overloaded_error: Overloaded")
+}
+
+func TestChatOffline(t *testing.T) {
+	body := `{"id":"msg_1","model":"claude-3-5-sonnet-20240620","stop_reason":"max_tokens",
+		"content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":3,"output_tokens":4}}`
+	reqs := make(chan chatRequest, 1)
+	srv := newTestServer(t, body, reqs)
+	ant := New(srv.URL, ModelSonnet35, "key", 0)
+
+	resp, err := ant.Chat(context.Background(), llm.MessageList{llm.UserMsg("Hi")})
+	assert.NoError(t, err)
+	assert.False(t, (<-reqs).Stream)
+	assert.Equal(t, "Hello", resp.Content)
+	assert.Equal(t, llm.FinishLength, resp.FinishReason)
+	assert.Equal(t, 7, resp.Usage.TotalTokens)
+}
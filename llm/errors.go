@@ -0,0 +1,31 @@
+package llm
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HTTPError is returned by providers when the API responds with a non-success status code.
+// It allows callers to decide how to handle failures, e.g. retry on 429 or 5xx.
+type HTTPError struct {
+	Provider   string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+// NewHTTPError creates an HTTPError from the response, reading the body for details.
+func NewHTTPError(provider string, resp *http.Response) *HTTPError {
+	body, _ := io.ReadAll(resp.Body)
+	return &HTTPError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       string(body),
+	}
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s: %v %v\n%v", e.Provider, e.StatusCode, e.Status, e.Body)
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/dshills/wiggle/llm"
 )
@@ -18,33 +19,52 @@ func (g *Gemini) GenerateResponse(info string, instruct string) (string, error)
 	return resp.Content, err
 }
 
-func (g *Gemini) Chat(ctx context.Context, conv llm.MessageList) (llm.Message, error) {
-	conlist := []content{}
-	for _, m := range conv {
-		con := content{Role: m.Role, Parts: []part{{Text: m.Content}}}
-		conlist = append(conlist, con)
-	}
-	req := chatRequest{Contents: conlist}
+func (g *Gemini) Chat(ctx context.Context, conv llm.MessageList) (llm.Response, error) {
+	return g.ChatWithTools(ctx, conv, nil)
+}
+
+// ChatWithTools is Chat offering the tools to the model
+func (g *Gemini) ChatWithTools(ctx context.Context, conv llm.MessageList, tools []llm.Tool) (llm.Response, error) {
+	start := time.Now()
+	req := newChatRequest(conv, tools)
 	js, err := json.Marshal(&req)
 	if err != nil {
-		return llm.Message{}, err
+		return llm.Response{}, err
 	}
 	reader := bytes.NewReader(js)
 	resp, err := g.send(ctx, g.baseURL, reader)
 	if err != nil {
-		return llm.Message{}, err
+		return llm.Response{}, err
 	}
 
-	retRespone := llm.Message{
-		Role:    resp.Candidates[0].Content.Role,
-		Content: resp.Candidates[0].Content.Parts[0].Text,
-	}
-	return retRespone, nil
+	reply := resp.toLLM()
+	reply.Latency = time.Since(start)
+	return reply, nil
 }
 
 func (g *Gemini) send(ctx context.Context, baseURL string, reader io.Reader) (*chatResponse, error) {
 	const geminiEP = "/v1beta/models/%%MODEL%%:generateContent?key=%%APIKEY%%"
-	ep := fmt.Sprintf("%v%v", baseURL, geminiEP)
+	resp, err := g.post(ctx, baseURL, geminiEP, reader)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	chatResp := chatResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&chatResp)
+	if err != nil {
+		return nil, err
+	}
+	if len(chatResp.Candidates) == 0 {
+		return nil, fmt.Errorf("no content")
+	}
+
+	return &chatResp, nil
+}
+
+// post sends a request to the endpoint and returns the response for the caller to read and close
+func (g *Gemini) post(ctx context.Context, baseURL, endpoint string, reader io.Reader) (*http.Response, error) {
+	ep := fmt.Sprintf("%v%v", baseURL, endpoint)
 	ep = strings.Replace(ep, "%%MODEL%%", g.model, 1)
 	ep = strings.Replace(ep, "%%APIKEY%%", g.apiKey, 1)
 
@@ -61,44 +81,49 @@ func (g *Gemini) send(ctx context.Context, baseURL string, reader io.Reader) (*c
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("%v %v", resp.StatusCode, resp.Status)
+		defer resp.Body.Close()
+		return nil, llm.NewHTTPError("Gemini: Chat", resp)
 	}
-
-	chatResp := chatResponse{}
-	err = json.NewDecoder(resp.Body).Decode(&chatResp)
-	if err != nil {
-		return nil, err
-	}
-	if len(chatResp.Candidates) == 0 {
-		return nil, fmt.Errorf("no content")
-	}
-
-	return &chatResp, nil
+	return resp, nil
 }
 
 type chatRequest struct {
-	Contents []content `json:"contents"`
+	SystemInstruction *content  `json:"systemInstruction,omitempty"`
+	Contents          []content `json:"contents"`
+	Tools             []tool    `json:"tools,omitempty"`
 }
 
 type chatResponse struct {
-	Candidates []candidate
+	Candidates    []candidate   `json:"candidates"`
+	UsageMetadata usageMetadata `json:"usageMetadata"`
+	ModelVersion  string        `json:"modelVersion"`
 }
 
-type candidate struct {
-	Content      content `json:"content"`
-	FinishReason string  `json:"finish_reason"`
-	TokenCount   int     `json:"token_count"`
-	Index        int     `json:"index"`
+// toLLM converts the first candidate of a response
+func (r chatResponse) toLLM() llm.Response {
+	reply := llm.Response{Usage: r.UsageMetadata.toLLM(), Model: r.ModelVersion}
+	if len(r.Candidates) > 0 {
+		reply.Message = r.Candidates[0].Content.toLLM()
+		reply.FinishReason = finishReason(r.Candidates[0].FinishReason, reply.Message)
+	}
+	return reply
 }
 
-type content struct {
-	Role  string `json:"role"`
-	Parts []part `json:"parts"`
+type usageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
 }
 
-type part struct {
-	Text string `json:"text"`
+func (u usageMetadata) toLLM() llm.Usage {
+	return llm.Usage{PromptTokens: u.PromptTokenCount, CompletionTokens: u.CandidatesTokenCount, TotalTokens: u.TotalTokenCount}
+}
+
+type candidate struct {
+	Content      content `json:"content"`
+	FinishReason string  `json:"finishReason"`
+	TokenCount   int     `json:"tokenCount"`
+	Index        int     `json:"index"`
 }
@@ -8,6 +8,7 @@ import (
 
 // Compile-time check
 var _ llm.LLM = (*Gemini)(nil)
+var _ llm.ToolChatter = (*Gemini)(nil)
 
 type Gemini struct {
 	model      string
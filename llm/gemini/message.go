@@ -0,0 +1,161 @@
+package gemini
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/schema"
+)
+
+// Gemini roles. Gemini calls the assistant "model" and takes the system prompt as a
+// separate instruction.
+const (
+	roleUser  = "user"
+	roleModel = "model"
+)
+
+type content struct {
+	Role  string `json:"role,omitempty"` // Not set for the system instruction
+	Parts []part `json:"parts"`
+}
+
+// part is a part of a content. It holds text, a function call by the model or the
+// response to a function call.
+type part struct {
+	Text             string            `json:"text,omitempty"`
+	FunctionCall     *functionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *functionResponse `json:"functionResponse,omitempty"`
+}
+
+type functionCall struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"` // JSON object
+}
+
+type functionResponse struct {
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+type tool struct {
+	FunctionDeclarations []functionDeclaration `json:"functionDeclarations"`
+}
+
+type functionDeclaration struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  *schema.Schema `json:"parameters,omitempty"`
+}
+
+// newChatRequest converts the messages and tools to a Gemini request. System messages
+// become the system instruction.
+func newChatRequest(conv llm.MessageList, tools []llm.Tool) chatRequest {
+	req := chatRequest{Contents: contents(conv), Tools: toTools(tools)}
+	for _, m := range conv {
+		if m.Role != llm.RoleSystem || m.Content == "" {
+			continue
+		}
+		if req.SystemInstruction == nil {
+			req.SystemInstruction = &content{}
+		}
+		req.SystemInstruction.Parts = append(req.SystemInstruction.Parts, part{Text: m.Content})
+	}
+	return req
+}
+
+// contents converts the messages other than system messages to Gemini contents, with
+// assistant messages in the model role. Gemini does not identify function calls;
+// responses are matched to calls by name and sent in the user role. Consecutive
+// contents with the same role are combined, so the responses to the calls of a turn
+// are sent together and the roles alternate. Messages without content are left out.
+func contents(conv llm.MessageList) []content {
+	conlist := []content{}
+	for _, m := range conv {
+		con := content{Role: roleUser}
+		switch {
+		case m.Role == llm.RoleSystem:
+			continue
+		case m.ToolResult != nil:
+			r := m.ToolResult
+			response := map[string]any{"content": r.Content}
+			if r.IsError {
+				response = map[string]any{"error": r.Content}
+			}
+			con.Parts = append(con.Parts, part{FunctionResponse: &functionResponse{Name: r.Name, Response: response}})
+		default:
+			if m.Role == llm.RoleAssistant {
+				con.Role = roleModel
+			}
+			if m.Content != "" {
+				con.Parts = append(con.Parts, part{Text: m.Content})
+			}
+			for _, c := range m.ToolCalls {
+				con.Parts = append(con.Parts, part{FunctionCall: &functionCall{Name: c.Name, Args: c.Arguments}})
+			}
+		}
+		if len(con.Parts) == 0 {
+			continue
+		}
+
+		if last := len(conlist) - 1; last >= 0 && conlist[last].Role == con.Role {
+			conlist[last].Parts = append(conlist[last].Parts, con.Parts...)
+			continue
+		}
+		conlist = append(conlist, con)
+	}
+	return conlist
+}
+
+// toLLM converts a content returned by Gemini to an assistant message. Function calls
+// are given IDs in the order they were made.
+func (c content) toLLM() llm.Message {
+	msg := llm.Message{Role: llm.RoleAssistant}
+	text := strings.Builder{}
+	for _, p := range c.Parts {
+		text.WriteString(p.Text)
+		if fc := p.FunctionCall; fc != nil {
+			args := fc.Args
+			if len(args) == 0 {
+				args = json.RawMessage("{}")
+			}
+			msg.ToolCalls = append(msg.ToolCalls, llm.ToolCall{ID: llm.CallID(len(msg.ToolCalls)), Name: fc.Name, Arguments: args})
+		}
+	}
+	msg.Content = text.String()
+	return msg
+}
+
+// finishReason translates the reason Gemini stopped generating a response. Gemini stops
+// with STOP when the model calls functions.
+func finishReason(reason string, msg llm.Message) string {
+	switch reason {
+	case "STOP":
+		if len(msg.ToolCalls) > 0 {
+			return llm.FinishToolCalls
+		}
+		return llm.FinishStop
+	case "MAX_TOKENS":
+		return llm.FinishLength
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		return llm.FinishContentFilter
+	}
+	return reason
+}
+
+// toTools converts the tools to Gemini function declarations
+func toTools(tools []llm.Tool) []tool {
+	if len(tools) == 0 {
+		return nil
+	}
+	decls := []functionDeclaration{}
+	for _, t := range tools {
+		decl := functionDeclaration{Name: t.Name, Description: t.Description}
+		if len(t.Parameters.Properties) > 0 {
+			params := t.Parameters
+			decl.Parameters = &params
+		}
+		decls = append(decls, decl)
+	}
+	return []tool{{FunctionDeclarations: decls}}
+}
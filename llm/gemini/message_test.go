@@ -0,0 +1,117 @@
+package gemini
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewChatRequest(t *testing.T) {
+	call := llm.ToolCall{ID: "call_0", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)}
+	tests := []struct {
+		name     string
+		conv     llm.MessageList
+		system   *content
+		contents []content
+	}{
+		{
+			name:     "user",
+			conv:     llm.MessageList{llm.UserMsg("Hi")},
+			contents: []content{{Role: roleUser, Parts: []part{{Text: "Hi"}}}},
+		},
+		{
+			name:     "system instruction",
+			conv:     llm.MessageList{llm.SystemMsg("Be brief"), llm.UserMsg("Hi"), llm.SystemMsg("Use French"), llm.SystemMsg("")},
+			system:   &content{Parts: []part{{Text: "Be brief"}, {Text: "Use French"}}},
+			contents: []content{{Role: roleUser, Parts: []part{{Text: "Hi"}}}},
+		},
+		{
+			name: "assistant in the model role",
+			conv: llm.MessageList{llm.UserMsg("Hi"), llm.UserMsg("Anyone?"), llm.AssistantMsg("Hello")},
+			contents: []content{
+				{Role: roleUser, Parts: []part{{Text: "Hi"}, {Text: "Anyone?"}}},
+				{Role: roleModel, Parts: []part{{Text: "Hello"}}},
+			},
+		},
+		{
+			name:     "empty messages left out",
+			conv:     llm.MessageList{llm.UserMsg("Hi"), llm.AssistantMsg(""), llm.UserMsg("")},
+			contents: []content{{Role: roleUser, Parts: []part{{Text: "Hi"}}}},
+		},
+		{
+			name: "function calls and responses",
+			conv: llm.MessageList{
+				llm.UserMsg("Weather?"),
+				{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{call}},
+				llm.ToolResultMsg(call, "21C"),
+				llm.ToolErrorMsg(call, errors.New("no station")),
+			},
+			contents: []content{
+				{Role: roleUser, Parts: []part{{Text: "Weather?"}}},
+				{Role: roleModel, Parts: []part{{FunctionCall: &functionCall{Name: "weather", Args: json.RawMessage(`{"city":"Paris"}`)}}}},
+				{Role: roleUser, Parts: []part{
+					{FunctionResponse: &functionResponse{Name: "weather", Response: map[string]any{"content": "21C"}}},
+					{FunctionResponse: &functionResponse{Name: "weather", Response: map[string]any{"error": "no station"}}},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newChatRequest(tt.conv, nil)
+			assert.Equal(t, tt.system, req.SystemInstruction)
+			assert.Equal(t, tt.contents, req.Contents)
+			assert.Nil(t, req.Tools)
+		})
+	}
+}
+
+func TestToTools(t *testing.T) {
+	params := schema.Schema{Type: "object", Properties: map[string]schema.Schema{"city": {Type: "string"}}}
+	tools := toTools([]llm.Tool{{Name: "weather", Description: "Weather of a city", Parameters: params}, {Name: "time"}})
+	assert.Equal(t, []tool{{FunctionDeclarations: []functionDeclaration{
+		{Name: "weather", Description: "Weather of a city", Parameters: &params},
+		{Name: "time"},
+	}}}, tools)
+	assert.Nil(t, toTools(nil))
+}
+
+func TestContentToLLM(t *testing.T) {
+	msg := content{Role: roleModel, Parts: []part{
+		{Text: "Let me "},
+		{Text: "check"},
+		{FunctionCall: &functionCall{Name: "weather", Args: json.RawMessage(`{"city":"Paris"}`)}},
+		{FunctionCall: &functionCall{Name: "time"}},
+	}}.toLLM()
+	assert.Equal(t, llm.Message{
+		Role:    llm.RoleAssistant,
+		Content: "Let me check",
+		ToolCalls: []llm.ToolCall{
+			{ID: "call_0", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)},
+			{ID: "call_1", Name: "time", Arguments: json.RawMessage(`{}`)},
+		},
+	}, msg)
+}
+
+func TestFinishReason(t *testing.T) {
+	calls := llm.Message{ToolCalls: []llm.ToolCall{{Name: "weather"}}}
+	tests := []struct {
+		reason string
+		msg    llm.Message
+		want   string
+	}{
+		{"STOP", llm.Message{}, llm.FinishStop},
+		{"STOP", calls, llm.FinishToolCalls},
+		{"MAX_TOKENS", llm.Message{}, llm.FinishLength},
+		{"SAFETY", llm.Message{}, llm.FinishContentFilter},
+		{"RECITATION", llm.Message{}, llm.FinishContentFilter},
+		{"OTHER", llm.Message{}, "OTHER"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, finishReason(tt.reason, tt.msg), tt.reason)
+	}
+}
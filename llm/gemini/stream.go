@@ -0,0 +1,67 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dshills/wiggle/llm"
+)
+
+// Compile-time check
+var _ llm.Streamer = (*Gemini)(nil)
+var _ llm.ToolStreamer = (*Gemini)(nil)
+
+// ChatStream streams the response to conv as it is generated
+func (g *Gemini) ChatStream(ctx context.Context, conv llm.MessageList) (<-chan llm.StreamEvent, error) {
+	return g.ChatStreamWithTools(ctx, conv, nil)
+}
+
+// ChatStreamWithTools is ChatStream offering the tools to the model
+func (g *Gemini) ChatStreamWithTools(ctx context.Context, conv llm.MessageList, tools []llm.Tool) (<-chan llm.StreamEvent, error) {
+	const geminiStreamEP = "/v1beta/models/%%MODEL%%:streamGenerateContent?alt=sse&key=%%APIKEY%%"
+	start := time.Now()
+	req := newChatRequest(conv, tools)
+	js, err := json.Marshal(&req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.post(ctx, g.baseURL, geminiStreamEP, bytes.NewReader(js))
+	if err != nil {
+		return nil, err
+	}
+	return llm.Stream(ctx, resp.Body, func(delta func(string)) (llm.Response, error) {
+		reply := llm.Response{}
+		con := content{Role: roleModel}
+		finish := ""
+		err := llm.ReadSSE(resp.Body, func(_, data string) error {
+			chunk := chatResponse{}
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return fmt.Errorf("Gemini: ChatStream: %w", err)
+			}
+			if chunk.UsageMetadata.TotalTokenCount > 0 {
+				reply.Usage = chunk.UsageMetadata.toLLM()
+			}
+			if chunk.ModelVersion != "" {
+				reply.Model = chunk.ModelVersion
+			}
+			if len(chunk.Candidates) == 0 {
+				return nil
+			}
+			if chunk.Candidates[0].FinishReason != "" {
+				finish = chunk.Candidates[0].FinishReason
+			}
+			for _, p := range chunk.Candidates[0].Content.Parts {
+				con.Parts = append(con.Parts, p)
+				delta(p.Text)
+			}
+			return nil
+		})
+		reply.Message = con.toLLM()
+		reply.FinishReason = finishReason(finish, reply.Message)
+		reply.Latency = time.Since(start)
+		return reply, err
+	}), nil
+}
@@ -0,0 +1,108 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestServer replies to every request with body, sending the decoded request and
+// its URL on reqs
+func newTestServer(t *testing.T, body string, reqs chan<- testRequest) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := testRequest{URL: r.URL.String()}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req.chatRequest))
+		reqs <- req
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type testRequest struct {
+	chatRequest
+	URL string
+}
+
+const textStream = `data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]},"index":0}],"modelVersion":"gemini-1.5-flash-002"}
+
+data: {"candidates":[{"content":{"role":"model","parts":[{"text":" there"}]},"finishReason":"STOP","index":0}],"usageMetadata":{"promptTokenCount":4,"candidatesTokenCount":2,"totalTokenCount":6},"modelVersion":"gemini-1.5-flash-002"}
+
+`
+
+const toolStream = `data: {"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"weather","args":{"city":"Paris"}}}]},"finishReason":"STOP","index":0}],"usageMetadata":{"promptTokenCount":20,"candidatesTokenCount":5,"totalTokenCount":25}}
+
+`
+
+func TestChatStreamOffline(t *testing.T) {
+	reqs := make(chan testRequest, 1)
+	srv := newTestServer(t, textStream, reqs)
+	g := New(srv.URL, "gemini-1.5-flash", "key", nil)
+
+	events, err := g.ChatStream(context.Background(), llm.MessageList{llm.SystemMsg("Be brief"), llm.UserMsg("Hi")})
+	assert.NoError(t, err)
+	deltas := []string{}
+	resp, err := llm.Collect(events, func(d string) { deltas = append(deltas, d) })
+	assert.NoError(t, err)
+
+	req := <-reqs
+	assert.Equal(t, "/v1beta/models/gemini-1.5-flash:streamGenerateContent?alt=sse&key=key", req.URL)
+	assert.Equal(t, "Be brief", req.SystemInstruction.Parts[0].Text)
+	assert.Len(t, req.Contents, 1)
+
+	assert.Equal(t, []string{"Hello", " there"}, deltas)
+	assert.Equal(t, "Hello there", resp.Content)
+	assert.Equal(t, llm.RoleAssistant, resp.Role)
+	assert.Equal(t, llm.Usage{PromptTokens: 4, CompletionTokens: 2, TotalTokens: 6}, resp.Usage)
+	assert.Equal(t, llm.FinishStop, resp.FinishReason)
+	assert.Equal(t, "gemini-1.5-flash-002", resp.Model)
+	assert.Positive(t, resp.Latency)
+}
+
+func TestChatStreamOffline_ToolCalls(t *testing.T) {
+	reqs := make(chan testRequest, 1)
+	srv := newTestServer(t, toolStream, reqs)
+	g := New(srv.URL, "gemini-1.5-flash", "key", nil)
+
+	events, err := g.ChatStreamWithTools(context.Background(), llm.MessageList{llm.UserMsg("Weather?")}, []llm.Tool{{Name: "weather"}})
+	assert.NoError(t, err)
+	resp, err := llm.Collect(events, nil)
+	assert.NoError(t, err)
+
+	assert.Len(t, (<-reqs).Tools, 1)
+	assert.Equal(t, []llm.ToolCall{{ID: "call_0", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)}}, resp.ToolCalls)
+	assert.Equal(t, llm.FinishToolCalls, resp.FinishReason)
+}
+
+func TestChatStreamOffline_Error(t *testing.T) {
+	srv := newTestServer(t, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"Hel\"}]}}]}\n\ndata: {not json}\n\n", make(chan testRequest, 1))
+	g := New(srv.URL, "gemini-1.5-flash", "key", nil)
+
+	events, err := g.ChatStream(context.Background(), llm.MessageList{llm.UserMsg("Hi")})
+	assert.NoError(t, err)
+	resp, err := llm.Collect(events, nil)
+	assert.ErrorContains(t, err, "Gemini: ChatStream:")
+	assert.Equal(t, "Hel", resp.Content, "the text received before the error is kept")
+}
+
+func TestChatOffline(t *testing.T) {
+	body := `{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]},"finishReason":"MAX_TOKENS","index":0}],
+		"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":4,"totalTokenCount":7},"modelVersion":"gemini-1.5-flash-002"}`
+	reqs := make(chan testRequest, 1)
+	srv := newTestServer(t, body, reqs)
+	g := New(srv.URL, "gemini-1.5-flash", "key", nil)
+
+	resp, err := g.Chat(context.Background(), llm.MessageList{llm.UserMsg("Hi")})
+	assert.NoError(t, err)
+	assert.Equal(t, "/v1beta/models/gemini-1.5-flash:generateContent?key=key", (<-reqs).URL)
+	assert.Equal(t, "Hello", resp.Content)
+	assert.Equal(t, llm.FinishLength, resp.FinishReason)
+	assert.Equal(t, 7, resp.Usage.TotalTokens)
+	assert.Equal(t, "gemini-1.5-flash-002", resp.Model)
+}
@@ -15,7 +15,7 @@ type Model struct {
 
 type LLM interface {
 	GenerateResponse(string, string) (string, error)
-	Chat(ctx context.Context, msgs MessageList) (Message, error)
+	Chat(ctx context.Context, msgs MessageList) (Response, error)
 	GenEmbed(ctx context.Context, txt string) ([]float32, error)
 	AvailableModels() ([]Model, error)
 	SetModel(model string)
@@ -9,17 +9,31 @@ const (
 	RoleAssistant = "assistant"
 	RoleUser      = "user"
 	RoleSystem    = "system"
+	RoleTool      = "tool" // The result of a tool call, see ToolResultMsg
 )
 
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string      `json:"role"`
+	Content    string      `json:"content"`
+	ToolCalls  []ToolCall  `json:"tool_calls,omitempty"`  // Tools an assistant message asks to call
+	ToolResult *ToolResult `json:"tool_result,omitempty"` // Result of a tool call for a RoleTool message
 }
 
 func UserMsg(content string) Message {
 	return Message{Role: RoleUser, Content: content}
 }
 
+// SystemMsg returns a system prompt. Providers without a system role in their messages
+// send it the way they take instructions.
+func SystemMsg(content string) Message {
+	return Message{Role: RoleSystem, Content: content}
+}
+
+// AssistantMsg returns a previous response of the model, to continue a conversation
+func AssistantMsg(content string) Message {
+	return Message{Role: RoleAssistant, Content: content}
+}
+
 type MessageList []Message
 
 func (ml MessageList) Latest() Message {
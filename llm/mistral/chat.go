@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/dshills/wiggle/llm"
 )
@@ -18,25 +19,58 @@ func (m *Mistral) GenerateResponse(info string, instruct string) (string, error)
 	return resp.Content, err
 }
 
-func (m *Mistral) Chat(ctx context.Context, conv llm.MessageList) (llm.Message, error) {
+func (m *Mistral) Chat(ctx context.Context, conv llm.MessageList) (llm.Response, error) {
+	return m.ChatWithTools(ctx, conv, nil)
+}
+
+// ChatWithTools is Chat offering the tools to the model
+func (m *Mistral) ChatWithTools(ctx context.Context, conv llm.MessageList, tools []llm.Tool) (llm.Response, error) {
+	start := time.Now()
 	chatReq := chatRequest{
 		Model:    m.model,
-		Messages: conv,
+		Messages: toMessages(conv),
+		Tools:    toTools(tools),
 	}
 	jsReq, err := json.Marshal(&chatReq)
 	if err != nil {
-		return llm.Message{}, err
+		return llm.Response{}, err
 	}
 
 	chatResp, err := m.send(ctx, bytes.NewReader(jsReq))
 	if err != nil {
-		return llm.Message{}, err
+		return llm.Response{}, err
 	}
 
-	return chatResp.Choices[0].Message, nil
+	return llm.Response{
+		Message:      chatResp.Choices[0].Message.toLLM(),
+		Usage:        chatResp.Usage,
+		FinishReason: finishReason(chatResp.Choices[0].FinishReason),
+		Model:        chatResp.Model,
+		Latency:      time.Since(start),
+	}, nil
 }
 
 func (m *Mistral) send(ctx context.Context, reader io.Reader) (*chatResponse, error) {
+	httpResp, err := m.post(ctx, reader, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	resp := chatResponse{}
+	err = json.NewDecoder(httpResp.Body).Decode(&resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no content")
+	}
+	return &resp, err
+}
+
+// post sends a chat request accepting the content type and returns the response for the
+// caller to read and close
+func (m *Mistral) post(ctx context.Context, reader io.Reader, accept string) (*http.Response, error) {
 	const chatEP = "/v1/chat/completions"
 	ep, err := url.JoinPath(m.baseURL, chatEP)
 	if err != nil {
@@ -48,40 +82,31 @@ func (m *Mistral) send(ctx context.Context, reader io.Reader) (*chatResponse, er
 		return nil, err
 	}
 	httpReq.Header.Add("Content-Type", "application/json")
-	httpReq.Header.Add("Accept", "application/json")
+	httpReq.Header.Add("Accept", accept)
 	httpReq.Header.Add("Authorization", fmt.Sprintf("Bearer %s", m.apiKey))
 
 	httpResp, err := m.httpClient.Do(httpReq)
 	if err != nil {
 		return nil, err
 	}
-	defer httpResp.Body.Close()
 
 	if httpResp.StatusCode >= 300 {
-		body, _ := io.ReadAll(httpResp.Body)
-		return nil, fmt.Errorf("Mistral: Chat: %v %v\n%v", httpResp.StatusCode, httpResp.Status, string(body))
-	}
-
-	resp := chatResponse{}
-	err = json.NewDecoder(httpResp.Body).Decode(&resp)
-	if err != nil {
-		return nil, err
+		defer httpResp.Body.Close()
+		return nil, llm.NewHTTPError("Mistral: Chat", httpResp)
 	}
-	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no content")
-	}
-	return &resp, err
+	return httpResp, nil
 }
 
 type chatRequest struct {
-	Model       string        `json:"model,omitempty"`
-	Messages    []llm.Message `json:"messages,omitempty"`
-	Temperature float64       `json:"temperature,omitempty"`
-	TopP        int           `json:"top_p,omitempty"`
-	MaxTokens   int           `json:"max_tokens,omitempty"`
-	Stream      bool          `json:"stream,omitempty"`
-	SafePrompt  bool          `json:"safe_prompt,omitempty"`
-	RandomSeed  int           `json:"random_seed,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	Messages    []message `json:"messages,omitempty"`
+	Tools       []tool    `json:"tools,omitempty"`
+	Temperature float64   `json:"temperature,omitempty"`
+	TopP        int       `json:"top_p,omitempty"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+	SafePrompt  bool      `json:"safe_prompt,omitempty"`
+	RandomSeed  int       `json:"random_seed,omitempty"`
 }
 
 type chatResponse struct {
@@ -90,13 +115,9 @@ type chatResponse struct {
 	Created int    `json:"created"`
 	Model   string `json:"model"`
 	Choices []struct {
-		Index        int         `json:"index"`
-		Message      llm.Message `json:"message"`
-		FinishReason string      `json:"finish_reason"`
+		Index        int     `json:"index"`
+		Message      message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage llm.Usage `json:"usage"`
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/dshills/wiggle/llm"
 	"github.com/dshills/wiggle/llm/mistral"
+	"github.com/dshills/wiggle/schema"
 )
 
 func TestChat(t *testing.T) {
@@ -31,4 +32,80 @@ func TestChat(t *testing.T) {
 	if respMsg.Content == "" {
 		t.Errorf("Expected a response got none")
 	}
+	if respMsg.Usage.TotalTokens == 0 || respMsg.Model == "" {
+		t.Errorf("Expected usage and model got %+v", respMsg.Usage)
+	}
+	if respMsg.FinishReason != llm.FinishStop {
+		t.Errorf("Expected finish reason %s got %s", llm.FinishStop, respMsg.FinishReason)
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	baseURL := os.Getenv("MISTRAL_API_URL")
+	apiKey := os.Getenv("MISTRAL_API_KEY")
+	model := "mistral-small-latest"
+
+	mist := mistral.New(baseURL, model, apiKey, nil)
+
+	ctx := context.TODO()
+	msgs := llm.MessageList{
+		llm.Message{Role: llm.RoleUser, Content: "Why is the sky blue?"},
+	}
+	events, err := mist.ChatStream(ctx, msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deltas := 0
+	respMsg, err := llm.Collect(events, func(string) { deltas++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if respMsg.Content == "" || deltas == 0 {
+		t.Errorf("Expected a streamed response got none")
+	}
+}
+
+func TestChatTools(t *testing.T) {
+	baseURL := os.Getenv("MISTRAL_API_URL")
+	apiKey := os.Getenv("MISTRAL_API_KEY")
+	mist := mistral.New(baseURL, "mistral-small-latest", apiKey, nil)
+
+	weather := llm.Tool{
+		Name:        "get_weather",
+		Description: "Get the current weather in a city",
+		Parameters: schema.Schema{
+			Type:       "object",
+			Properties: map[string]schema.Schema{"city": {Type: "string", Description: "Name of the city"}},
+			Required:   []string{"city"},
+		},
+	}
+	ctx := context.TODO()
+	tools := []llm.Tool{weather}
+	msgs := llm.MessageList{
+		llm.Message{Role: llm.RoleUser, Content: "What is the weather in Paris?"},
+	}
+	respMsg, err := mist.ChatWithTools(ctx, msgs, tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(respMsg.ToolCalls) == 0 {
+		t.Fatalf("Expected a tool call got %+v", respMsg)
+	}
+	if respMsg.FinishReason != llm.FinishToolCalls {
+		t.Errorf("Expected finish reason %s got %s", llm.FinishToolCalls, respMsg.FinishReason)
+	}
+	args := struct{ City string }{}
+	if err := respMsg.ToolCalls[0].DecodeArguments(&args); err != nil || args.City == "" {
+		t.Fatalf("Expected a city got %v %v", args, err)
+	}
+
+	msgs = append(msgs, respMsg.Message, llm.ToolResultMsg(respMsg.ToolCalls[0], "Sunny, 21C"))
+	respMsg, err = mist.ChatWithTools(ctx, msgs, tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if respMsg.Content == "" {
+		t.Errorf("Expected a response got none")
+	}
 }
@@ -0,0 +1,93 @@
+package mistral
+
+import (
+	"encoding/json"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/schema"
+)
+
+// message is a chat message in the Mistral format
+type message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	Name       string     `json:"name,omitempty"` // Name of the tool for tool messages
+}
+
+type toolCall struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"` // JSON object encoded as a string
+	} `json:"function"`
+}
+
+type tool struct {
+	Type     string   `json:"type"`
+	Function function `json:"function"`
+}
+
+type function struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Parameters  schema.Schema `json:"parameters"`
+}
+
+// toMessages converts the messages to the Mistral format
+func toMessages(msgs llm.MessageList) []message {
+	messages := []message{}
+	for _, m := range msgs {
+		msg := message{Role: m.Role, Content: m.Content}
+		for _, c := range m.ToolCalls {
+			tc := toolCall{ID: c.ID, Type: "function"}
+			tc.Function.Name = c.Name
+			tc.Function.Arguments = string(c.Arguments)
+			msg.ToolCalls = append(msg.ToolCalls, tc)
+		}
+		if m.ToolResult != nil {
+			msg.Content = m.ToolResult.Content
+			msg.ToolCallID = m.ToolResult.CallID
+			msg.Name = m.ToolResult.Name
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// finishReason translates the reason Mistral stopped generating a response
+func finishReason(reason string) string {
+	if reason == "model_length" {
+		return llm.FinishLength
+	}
+	return reason
+}
+
+// toLLM converts a message returned by Mistral
+func (m message) toLLM() llm.Message {
+	msg := llm.Message{Role: m.Role, Content: m.Content}
+	for _, tc := range m.ToolCalls {
+		args := json.RawMessage(tc.Function.Arguments)
+		if len(args) == 0 {
+			args = json.RawMessage("{}")
+		} else if !json.Valid(args) {
+			args, _ = json.Marshal(tc.Function.Arguments)
+		}
+		msg.ToolCalls = append(msg.ToolCalls, llm.ToolCall{ID: tc.ID, Name: tc.Function.Name, Arguments: args})
+	}
+	return msg
+}
+
+// toTools converts the tools to the Mistral format
+func toTools(tools []llm.Tool) []tool {
+	out := []tool{}
+	for _, t := range tools {
+		out = append(out, tool{
+			Type:     "function",
+			Function: function{Name: t.Name, Description: t.Description, Parameters: t.Parameters},
+		})
+	}
+	return out
+}
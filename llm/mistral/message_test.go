@@ -0,0 +1,77 @@
+package mistral
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToMessages(t *testing.T) {
+	call := llm.ToolCall{ID: "abc123xyz", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)}
+	weatherCall := toolCall{ID: "abc123xyz", Type: "function"}
+	weatherCall.Function.Name = "weather"
+	weatherCall.Function.Arguments = `{"city":"Paris"}`
+
+	msgs := toMessages(llm.MessageList{
+		llm.SystemMsg("Be brief"),
+		llm.UserMsg("Weather?"),
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{call}},
+		llm.ToolResultMsg(call, "21C"),
+		llm.ToolErrorMsg(call, errors.New("no station")),
+		llm.AssistantMsg("It is 21C"),
+	})
+	assert.Equal(t, []message{
+		{Role: "system", Content: "Be brief"},
+		{Role: "user", Content: "Weather?"},
+		{Role: "assistant", ToolCalls: []toolCall{weatherCall}},
+		{Role: "tool", Content: "21C", ToolCallID: "abc123xyz", Name: "weather"},
+		{Role: "tool", Content: "no station", ToolCallID: "abc123xyz", Name: "weather"},
+		{Role: "assistant", Content: "It is 21C"},
+	}, msgs)
+}
+
+func TestMessageToLLM(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want json.RawMessage
+	}{
+		{"object", `{"city":"Paris"}`, json.RawMessage(`{"city":"Paris"}`)},
+		{"empty", ``, json.RawMessage(`{}`)},
+		{"invalid", `{"city":`, json.RawMessage(`"{\"city\":"`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := toolCall{ID: "abc123xyz"}
+			tc.Function.Name = "weather"
+			tc.Function.Arguments = tt.args
+			msg := message{Role: "assistant", Content: "Checking", ToolCalls: []toolCall{tc}}.toLLM()
+			assert.Equal(t, llm.Message{
+				Role:      llm.RoleAssistant,
+				Content:   "Checking",
+				ToolCalls: []llm.ToolCall{{ID: "abc123xyz", Name: "weather", Arguments: tt.want}},
+			}, msg)
+		})
+	}
+}
+
+func TestToTools(t *testing.T) {
+	tools := toTools([]llm.Tool{{Name: "weather", Description: "Current weather"}})
+	assert.Equal(t, []tool{{Type: "function", Function: function{Name: "weather", Description: "Current weather"}}}, tools)
+}
+
+func TestFinishReason(t *testing.T) {
+	tests := map[string]string{
+		"stop":         llm.FinishStop,
+		"length":       llm.FinishLength,
+		"model_length": llm.FinishLength,
+		"tool_calls":   llm.FinishToolCalls,
+		"error":        "error",
+	}
+	for reason, want := range tests {
+		assert.Equal(t, want, finishReason(reason), reason)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 // Compile-time check
 var _ llm.LLM = (*Mistral)(nil)
+var _ llm.ToolChatter = (*Mistral)(nil)
 
 type Mistral struct {
 	model      string
@@ -0,0 +1,87 @@
+package mistral
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dshills/wiggle/llm"
+)
+
+// Compile-time check
+var _ llm.Streamer = (*Mistral)(nil)
+var _ llm.ToolStreamer = (*Mistral)(nil)
+
+// ChatStream streams the response to conv as it is generated
+func (m *Mistral) ChatStream(ctx context.Context, conv llm.MessageList) (<-chan llm.StreamEvent, error) {
+	return m.ChatStreamWithTools(ctx, conv, nil)
+}
+
+// ChatStreamWithTools is ChatStream offering the tools to the model
+func (m *Mistral) ChatStreamWithTools(ctx context.Context, conv llm.MessageList, tools []llm.Tool) (<-chan llm.StreamEvent, error) {
+	start := time.Now()
+	chatReq := chatRequest{
+		Model:    m.model,
+		Messages: toMessages(conv),
+		Tools:    toTools(tools),
+		Stream:   true,
+	}
+	jsReq, err := json.Marshal(&chatReq)
+	if err != nil {
+		return nil, err
+	}
+	httpResp, err := m.post(ctx, bytes.NewReader(jsReq), "text/event-stream")
+	if err != nil {
+		return nil, err
+	}
+	return llm.Stream(ctx, httpResp.Body, func(delta func(string)) (llm.Response, error) {
+		msg := message{Role: llm.RoleAssistant}
+		reply := llm.Response{}
+		content := strings.Builder{}
+		err := llm.ReadSSE(httpResp.Body, func(_, data string) error {
+			if data == "[DONE]" {
+				return llm.ErrStopSSE
+			}
+			chunk := streamChunk{}
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return fmt.Errorf("Mistral: ChatStream: %w", err)
+			}
+			if chunk.Usage != nil {
+				reply.Usage = *chunk.Usage
+			}
+			if chunk.Model != "" {
+				reply.Model = chunk.Model
+			}
+			for _, choice := range chunk.Choices {
+				if choice.Index == 0 {
+					content.WriteString(choice.Delta.Content)
+					delta(choice.Delta.Content)
+					msg.ToolCalls = append(msg.ToolCalls, choice.Delta.ToolCalls...)
+					if choice.FinishReason != "" {
+						reply.FinishReason = finishReason(choice.FinishReason)
+					}
+				}
+			}
+			return nil
+		})
+		msg.Content = content.String()
+		reply.Message = msg.toLLM()
+		reply.Latency = time.Since(start)
+		return reply, err
+	}), nil
+}
+
+// streamChunk is an event of a streamed chat response
+type streamChunk struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index        int     `json:"index"`
+		Delta        message `json:"delta"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *llm.Usage `json:"usage"`
+}
@@ -0,0 +1,105 @@
+package mistral
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestServer replies to every request with body, sending the decoded request on reqs
+func newTestServer(t *testing.T, body string, reqs chan<- chatRequest) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := chatRequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		reqs <- req
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+const textStream = `data: {"id":"1","model":"mistral-small-latest","choices":[{"index":0,"delta":{"role":"assistant","content":""},"finish_reason":null}]}
+
+data: {"id":"1","model":"mistral-small-latest","choices":[{"index":0,"delta":{"content":"Hello"},"finish_reason":null}]}
+
+data: {"id":"1","model":"mistral-small-latest","choices":[{"index":0,"delta":{"content":" there"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}
+
+data: [DONE]
+
+`
+
+const toolStream = `data: {"id":"1","model":"mistral-small-latest","choices":[{"index":0,"delta":{"role":"assistant","content":"","tool_calls":[{"id":"abc123xyz","function":{"name":"weather","arguments":"{\"city\": \"Paris\"}"}}]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":40,"completion_tokens":10,"total_tokens":50}}
+
+data: [DONE]
+
+`
+
+func TestChatStreamOffline(t *testing.T) {
+	reqs := make(chan chatRequest, 1)
+	srv := newTestServer(t, textStream, reqs)
+	m := New(srv.URL, "mistral-small-latest", "key", nil)
+
+	events, err := m.ChatStream(context.Background(), llm.MessageList{llm.SystemMsg("Be brief"), llm.UserMsg("Hi")})
+	assert.NoError(t, err)
+	deltas := []string{}
+	resp, err := llm.Collect(events, func(d string) { deltas = append(deltas, d) })
+	assert.NoError(t, err)
+
+	req := <-reqs
+	assert.True(t, req.Stream)
+	assert.Len(t, req.Messages, 2)
+
+	assert.Equal(t, []string{"Hello", " there"}, deltas)
+	assert.Equal(t, "Hello there", resp.Content)
+	assert.Equal(t, llm.RoleAssistant, resp.Role)
+	assert.Equal(t, llm.Usage{PromptTokens: 5, CompletionTokens: 2, TotalTokens: 7}, resp.Usage)
+	assert.Equal(t, llm.FinishStop, resp.FinishReason)
+	assert.Equal(t, "mistral-small-latest", resp.Model)
+	assert.Positive(t, resp.Latency)
+}
+
+func TestChatStreamOffline_ToolCalls(t *testing.T) {
+	reqs := make(chan chatRequest, 1)
+	srv := newTestServer(t, toolStream, reqs)
+	m := New(srv.URL, "mistral-small-latest", "key", nil)
+
+	events, err := m.ChatStreamWithTools(context.Background(), llm.MessageList{llm.UserMsg("Weather?")}, []llm.Tool{{Name: "weather"}})
+	assert.NoError(t, err)
+	resp, err := llm.Collect(events, nil)
+	assert.NoError(t, err)
+
+	assert.Len(t, (<-reqs).Tools, 1)
+	assert.Equal(t, []llm.ToolCall{{ID: "abc123xyz", Name: "weather", Arguments: json.RawMessage(`{"city": "Paris"}`)}}, resp.ToolCalls)
+	assert.Equal(t, llm.FinishToolCalls, resp.FinishReason)
+}
+
+func TestChatStreamOffline_Error(t *testing.T) {
+	srv := newTestServer(t, "data: {not json}\n\n", make(chan chatRequest, 1))
+	m := New(srv.URL, "mistral-small-latest", "key", nil)
+
+	events, err := m.ChatStream(context.Background(), llm.MessageList{llm.UserMsg("Hi")})
+	assert.NoError(t, err)
+	_, err = llm.Collect(events, nil)
+	assert.ErrorContains(t, err, "Mistral: ChatStream:")
+}
+
+func TestChatOffline(t *testing.T) {
+	body := `{"id":"1","model":"mistral-small-latest","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"model_length"}],
+		"usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7}}`
+	reqs := make(chan chatRequest, 1)
+	srv := newTestServer(t, body, reqs)
+	m := New(srv.URL, "mistral-small-latest", "key", nil)
+
+	resp, err := m.Chat(context.Background(), llm.MessageList{llm.UserMsg("Hi")})
+	assert.NoError(t, err)
+	assert.False(t, (<-reqs).Stream)
+	assert.Equal(t, "Hello", resp.Content)
+	assert.Equal(t, llm.FinishLength, resp.FinishReason)
+	assert.Equal(t, 7, resp.Usage.TotalTokens)
+}
@@ -19,27 +19,56 @@ func (o *Ollama) GenerateResponse(info string, instruct string) (string, error)
 	return resp.Content, err
 }
 
-func (o *Ollama) Chat(ctx context.Context, conv llm.MessageList) (llm.Message, error) {
+func (o *Ollama) Chat(ctx context.Context, conv llm.MessageList) (llm.Response, error) {
+	return o.ChatWithTools(ctx, conv, nil)
+}
+
+// ChatWithTools is Chat offering the tools to the model
+func (o *Ollama) ChatWithTools(ctx context.Context, conv llm.MessageList, tools []llm.Tool) (llm.Response, error) {
+	start := time.Now()
 	oreq := chatRequest{
 		Stream:   false,
-		Messages: conv,
+		Messages: toMessages(conv),
+		Tools:    toTools(tools),
 		Options:  o.options,
 		Model:    o.model,
 	}
 	js, err := json.Marshal(&oreq)
 	if err != nil {
-		return llm.Message{}, err
+		return llm.Response{}, err
 	}
 	reader := bytes.NewReader(js)
 	resp, err := o.send(ctx, o.baseURL, reader)
 	if err != nil {
-		return llm.Message{}, err
+		return llm.Response{}, err
 	}
 
-	return resp.Message, nil
+	reply := resp.toLLM(resp.Message)
+	reply.Latency = time.Since(start)
+	return reply, nil
 }
 
 func (o *Ollama) send(ctx context.Context, baseURL string, reader io.Reader) (*chatResponse, error) {
+	resp, err := o.post(ctx, baseURL, reader)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	chatResp := chatResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&chatResp)
+	if err != nil {
+		return nil, err
+	}
+	if len(chatResp.Message.Content) == 0 && len(chatResp.Message.ToolCalls) == 0 {
+		return nil, fmt.Errorf("no content")
+	}
+
+	return &chatResp, nil
+}
+
+// post sends a chat request and returns the response for the caller to read and close
+func (o *Ollama) post(ctx context.Context, baseURL string, reader io.Reader) (*http.Response, error) {
 	const ollamaChatEP = "api/chat"
 
 	ep, err := url.JoinPath(baseURL, ollamaChatEP)
@@ -58,43 +87,52 @@ func (o *Ollama) send(ctx context.Context, baseURL string, reader io.Reader) (*c
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
-		return nil, err
-	}
-
-	chatResp := chatResponse{}
-	err = json.NewDecoder(resp.Body).Decode(&chatResp)
-	if err != nil {
-		return nil, err
+		defer resp.Body.Close()
+		return nil, llm.NewHTTPError("Ollama: Chat", resp)
 	}
-	if len(chatResp.Message.Content) == 0 {
-		return nil, fmt.Errorf("no content")
-	}
-
-	return &chatResp, nil
+	return resp, nil
 }
 
 type chatRequest struct {
-	Model    string        `json:"model"`
-	Messages []llm.Message `json:"messages"`
-	Stream   bool          `json:"stream"`
-	Options  Options       `json:"options"`
+	Model    string    `json:"model"`
+	Messages []message `json:"messages"`
+	Stream   bool      `json:"stream"`
+	Options  Options   `json:"options"`
+	Tools    []tool    `json:"tools,omitempty"`
 }
 
 type chatResponse struct {
-	Model     string    `json:"model"`
-	CreatedAt time.Time `json:"created_at"`
-	Message   struct {
-		Role    string `json:"role"`
-		Content string `json:"content"`
-	} `json:"message"`
-	Done               bool  `json:"done"`
-	TotalDuration      int64 `json:"total_duration"`
-	LoadDuration       int   `json:"load_duration"`
-	PromptEvalCount    int   `json:"prompt_eval_count"`
-	PromptEvalDuration int   `json:"prompt_eval_duration"`
-	EvalCount          int   `json:"eval_count"`
-	EvalDuration       int64 `json:"eval_duration"`
+	Model              string    `json:"model"`
+	CreatedAt          time.Time `json:"created_at"`
+	Message            message   `json:"message"`
+	Done               bool      `json:"done"`
+	DoneReason         string    `json:"done_reason"`
+	TotalDuration      int64     `json:"total_duration"`
+	LoadDuration       int       `json:"load_duration"`
+	PromptEvalCount    int       `json:"prompt_eval_count"`
+	PromptEvalDuration int       `json:"prompt_eval_duration"`
+	EvalCount          int       `json:"eval_count"`
+	EvalDuration       int64     `json:"eval_duration"`
+	Error              string    `json:"error,omitempty"` // Set when a streamed response fails
+}
+
+// toLLM converts the final response with the complete message, which is split across
+// the responses when streaming. Ollama stops with "stop" when the model calls tools.
+func (r chatResponse) toLLM(msg message) llm.Response {
+	reply := llm.Response{
+		Message: msg.toLLM(),
+		Usage: llm.Usage{
+			PromptTokens:     r.PromptEvalCount,
+			CompletionTokens: r.EvalCount,
+			TotalTokens:      r.PromptEvalCount + r.EvalCount,
+		},
+		FinishReason: r.DoneReason,
+		Model:        r.Model,
+	}
+	if reply.FinishReason == llm.FinishStop && len(reply.ToolCalls) > 0 {
+		reply.FinishReason = llm.FinishToolCalls
+	}
+	return reply
 }
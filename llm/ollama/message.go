@@ -0,0 +1,81 @@
+package ollama
+
+import (
+	"encoding/json"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/schema"
+)
+
+// message is a chat message in the Ollama format
+type message struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+}
+
+type toolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"` // JSON object
+	} `json:"function"`
+}
+
+type tool struct {
+	Type     string   `json:"type"`
+	Function function `json:"function"`
+}
+
+type function struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Parameters  schema.Schema `json:"parameters"`
+}
+
+// toMessages converts the messages to the Ollama format
+func toMessages(msgs llm.MessageList) []message {
+	messages := []message{}
+	for _, m := range msgs {
+		msg := message{Role: m.Role, Content: m.Content}
+		for _, c := range m.ToolCalls {
+			tc := toolCall{}
+			tc.Function.Name = c.Name
+			tc.Function.Arguments = c.Arguments
+			if len(tc.Function.Arguments) == 0 {
+				tc.Function.Arguments = json.RawMessage("{}")
+			}
+			msg.ToolCalls = append(msg.ToolCalls, tc)
+		}
+		if m.ToolResult != nil {
+			msg.Content = m.ToolResult.Content
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// toLLM converts a message returned by Ollama. Ollama does not identify tool calls, so
+// they are given IDs in the order they were made.
+func (m message) toLLM() llm.Message {
+	msg := llm.Message{Role: m.Role, Content: m.Content}
+	for i, tc := range m.ToolCalls {
+		args := tc.Function.Arguments
+		if len(args) == 0 {
+			args = json.RawMessage("{}")
+		}
+		msg.ToolCalls = append(msg.ToolCalls, llm.ToolCall{ID: llm.CallID(i), Name: tc.Function.Name, Arguments: args})
+	}
+	return msg
+}
+
+// toTools converts the tools to the Ollama format
+func toTools(tools []llm.Tool) []tool {
+	out := []tool{}
+	for _, t := range tools {
+		out = append(out, tool{
+			Type:     "function",
+			Function: function{Name: t.Name, Description: t.Description, Parameters: t.Parameters},
+		})
+	}
+	return out
+}
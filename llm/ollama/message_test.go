@@ -0,0 +1,81 @@
+package ollama
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToMessages(t *testing.T) {
+	call := llm.ToolCall{ID: "call_0", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)}
+	weatherCall := toolCall{}
+	weatherCall.Function.Name = "weather"
+	weatherCall.Function.Arguments = json.RawMessage(`{"city":"Paris"}`)
+	timeCall := toolCall{}
+	timeCall.Function.Name = "time"
+	timeCall.Function.Arguments = json.RawMessage(`{}`)
+
+	msgs := toMessages(llm.MessageList{
+		llm.SystemMsg("Be brief"),
+		llm.UserMsg("Weather?"),
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{call, {ID: "call_1", Name: "time"}}},
+		llm.ToolResultMsg(call, "21C"),
+		llm.AssistantMsg("It is 21C"),
+	})
+	assert.Equal(t, []message{
+		{Role: "system", Content: "Be brief"},
+		{Role: "user", Content: "Weather?"},
+		{Role: "assistant", ToolCalls: []toolCall{weatherCall, timeCall}},
+		{Role: "tool", Content: "21C"},
+		{Role: "assistant", Content: "It is 21C"},
+	}, msgs)
+}
+
+func TestMessageToLLM(t *testing.T) {
+	weatherCall := toolCall{}
+	weatherCall.Function.Name = "weather"
+	weatherCall.Function.Arguments = json.RawMessage(`{"city":"Paris"}`)
+	timeCall := toolCall{}
+	timeCall.Function.Name = "time"
+
+	msg := message{Role: "assistant", Content: "Checking", ToolCalls: []toolCall{weatherCall, timeCall}}.toLLM()
+	assert.Equal(t, llm.Message{
+		Role:    llm.RoleAssistant,
+		Content: "Checking",
+		ToolCalls: []llm.ToolCall{
+			{ID: "call_0", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)},
+			{ID: "call_1", Name: "time", Arguments: json.RawMessage(`{}`)},
+		},
+	}, msg)
+}
+
+func TestToTools(t *testing.T) {
+	tools := toTools([]llm.Tool{{Name: "weather", Description: "Current weather"}})
+	assert.Equal(t, []tool{{Type: "function", Function: function{Name: "weather", Description: "Current weather"}}}, tools)
+}
+
+func TestResponseToLLM(t *testing.T) {
+	call := toolCall{}
+	call.Function.Name = "weather"
+	tests := []struct {
+		name   string
+		reason string
+		msg    message
+		want   string
+	}{
+		{"stop", "stop", message{Content: "Hi"}, llm.FinishStop},
+		{"stop with tool calls", "stop", message{ToolCalls: []toolCall{call}}, llm.FinishToolCalls},
+		{"length", "length", message{Content: "Hi"}, llm.FinishLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := chatResponse{Model: "llama3.1", DoneReason: tt.reason, PromptEvalCount: 3, EvalCount: 4}
+			reply := r.toLLM(tt.msg)
+			assert.Equal(t, tt.want, reply.FinishReason)
+			assert.Equal(t, llm.Usage{PromptTokens: 3, CompletionTokens: 4, TotalTokens: 7}, reply.Usage)
+			assert.Equal(t, "llama3.1", reply.Model)
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 // Compile-time check
 var _ llm.LLM = (*Ollama)(nil)
+var _ llm.ToolChatter = (*Ollama)(nil)
 
 type Ollama struct {
 	model      string
@@ -0,0 +1,72 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dshills/wiggle/llm"
+)
+
+// Compile-time check
+var _ llm.Streamer = (*Ollama)(nil)
+var _ llm.ToolStreamer = (*Ollama)(nil)
+
+// ChatStream streams the response to conv as it is generated. Ollama sends the
+// response as a JSON object per line, the last one with Done set.
+func (o *Ollama) ChatStream(ctx context.Context, conv llm.MessageList) (<-chan llm.StreamEvent, error) {
+	return o.ChatStreamWithTools(ctx, conv, nil)
+}
+
+// ChatStreamWithTools is ChatStream offering the tools to the model
+func (o *Ollama) ChatStreamWithTools(ctx context.Context, conv llm.MessageList, tools []llm.Tool) (<-chan llm.StreamEvent, error) {
+	start := time.Now()
+	oreq := chatRequest{
+		Stream:   true,
+		Messages: toMessages(conv),
+		Tools:    toTools(tools),
+		Options:  o.options,
+		Model:    o.model,
+	}
+	js, err := json.Marshal(&oreq)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.post(ctx, o.baseURL, bytes.NewReader(js))
+	if err != nil {
+		return nil, err
+	}
+	return llm.Stream(ctx, resp.Body, func(delta func(string)) (llm.Response, error) {
+		msg := message{Role: llm.RoleAssistant}
+		content := strings.Builder{}
+		dec := json.NewDecoder(resp.Body)
+		for {
+			chunk := chatResponse{}
+			if err := dec.Decode(&chunk); err != nil {
+				if errors.Is(err, io.EOF) {
+					err = fmt.Errorf("Ollama: ChatStream: %w", io.ErrUnexpectedEOF)
+				}
+				msg.Content = content.String()
+				return llm.Response{Message: msg.toLLM(), Latency: time.Since(start)}, err
+			}
+			if chunk.Error != "" {
+				msg.Content = content.String()
+				return llm.Response{Message: msg.toLLM(), Latency: time.Since(start)}, fmt.Errorf("Ollama: ChatStream: %s", chunk.Error)
+			}
+			content.WriteString(chunk.Message.Content)
+			msg.ToolCalls = append(msg.ToolCalls, chunk.Message.ToolCalls...)
+			delta(chunk.Message.Content)
+			if chunk.Done {
+				msg.Content = content.String()
+				reply := chunk.toLLM(msg)
+				reply.Latency = time.Since(start)
+				return reply, nil
+			}
+		}
+	}), nil
+}
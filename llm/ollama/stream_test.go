@@ -0,0 +1,121 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestServer replies to every request with body, sending the decoded request on reqs
+func newTestServer(t *testing.T, body string, reqs chan<- chatRequest) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+		req := chatRequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		reqs <- req
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+const textStream = `{"model":"llama3.1","message":{"role":"assistant","content":"Hello"},"done":false}
+{"model":"llama3.1","message":{"role":"assistant","content":" there"},"done":false}
+{"model":"llama3.1","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":5,"eval_count":2}
+`
+
+const toolStream = `{"model":"llama3.1","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"weather","arguments":{"city":"Paris"}}}]},"done":false}
+{"model":"llama3.1","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":30,"eval_count":9}
+`
+
+func TestChatStream(t *testing.T) {
+	reqs := make(chan chatRequest, 1)
+	srv := newTestServer(t, textStream, reqs)
+	o := New(srv.URL, "llama3.1", nil)
+
+	events, err := o.ChatStream(context.Background(), llm.MessageList{llm.SystemMsg("Be brief"), llm.UserMsg("Hi")})
+	assert.NoError(t, err)
+	deltas := []string{}
+	resp, err := llm.Collect(events, func(d string) { deltas = append(deltas, d) })
+	assert.NoError(t, err)
+
+	req := <-reqs
+	assert.True(t, req.Stream)
+	assert.Equal(t, "llama3.1", req.Model)
+	assert.Len(t, req.Messages, 2)
+
+	assert.Equal(t, []string{"Hello", " there"}, deltas)
+	assert.Equal(t, "Hello there", resp.Content)
+	assert.Equal(t, llm.RoleAssistant, resp.Role)
+	assert.Equal(t, llm.Usage{PromptTokens: 5, CompletionTokens: 2, TotalTokens: 7}, resp.Usage)
+	assert.Equal(t, llm.FinishStop, resp.FinishReason)
+	assert.Equal(t, "llama3.1", resp.Model)
+	assert.Positive(t, resp.Latency)
+}
+
+func TestChatStream_ToolCalls(t *testing.T) {
+	reqs := make(chan chatRequest, 1)
+	srv := newTestServer(t, toolStream, reqs)
+	o := New(srv.URL, "llama3.1", nil)
+
+	events, err := o.ChatStreamWithTools(context.Background(), llm.MessageList{llm.UserMsg("Weather?")}, []llm.Tool{{Name: "weather"}})
+	assert.NoError(t, err)
+	resp, err := llm.Collect(events, nil)
+	assert.NoError(t, err)
+
+	assert.Len(t, (<-reqs).Tools, 1)
+	assert.Equal(t, []llm.ToolCall{{ID: "call_0", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)}}, resp.ToolCalls)
+	assert.Equal(t, llm.FinishToolCalls, resp.FinishReason)
+}
+
+func TestChatStream_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{"error chunk", `{"model":"llama3.1","message":{"content":"Hel"},"done":false}` + "\n" + `{"error":"model crashed"}` + "\n", "Ollama: ChatStream: model crashed"},
+		{"early end", `{"model":"llama3.1","message":{"content":"Hel"},"done":false}` + "\n", "Ollama: ChatStream: unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, tt.body, make(chan chatRequest, 1))
+			o := New(srv.URL, "llama3.1", nil)
+
+			events, err := o.ChatStream(context.Background(), llm.MessageList{llm.UserMsg("Hi")})
+			assert.NoError(t, err)
+			resp, err := llm.Collect(events, nil)
+			assert.EqualError(t, err, tt.err)
+			assert.Equal(t, "Hel", resp.Content, "the text received before the error is kept")
+		})
+	}
+}
+
+func TestChat(t *testing.T) {
+	body := `{"model":"llama3.1","message":{"role":"assistant","content":"Hello"},"done":true,"done_reason":"length","prompt_eval_count":3,"eval_count":4}`
+	reqs := make(chan chatRequest, 1)
+	srv := newTestServer(t, body, reqs)
+	o := New(srv.URL, "llama3.1", nil)
+
+	resp, err := o.Chat(context.Background(), llm.MessageList{llm.UserMsg("Hi")})
+	assert.NoError(t, err)
+	assert.False(t, (<-reqs).Stream)
+	assert.Equal(t, "Hello", resp.Content)
+	assert.Equal(t, llm.FinishLength, resp.FinishReason)
+	assert.Equal(t, 7, resp.Usage.TotalTokens)
+}
+
+func TestChat_NoContent(t *testing.T) {
+	body := `{"model":"llama3.1","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}`
+	srv := newTestServer(t, body, make(chan chatRequest, 1))
+	o := New(srv.URL, "llama3.1", nil)
+
+	_, err := o.Chat(context.Background(), llm.MessageList{llm.UserMsg("Hi")})
+	assert.EqualError(t, err, "no content")
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/dshills/wiggle/llm"
 )
@@ -18,31 +19,45 @@ func (ai *OpenAI) GenerateResponse(info string, instruct string) (string, error)
 	return resp.Content, err
 }
 
-func (ai *OpenAI) Chat(ctx context.Context, msgs llm.MessageList) (llm.Message, error) {
-	js, err := ai.encodeRequest(msgs)
+func (ai *OpenAI) Chat(ctx context.Context, msgs llm.MessageList) (llm.Response, error) {
+	return ai.ChatWithTools(ctx, msgs, nil)
+}
+
+// ChatWithTools is Chat offering the tools to the model
+func (ai *OpenAI) ChatWithTools(ctx context.Context, msgs llm.MessageList, tools []llm.Tool) (llm.Response, error) {
+	start := time.Now()
+	js, err := ai.encodeRequest(msgs, tools, false)
 	if err != nil {
-		return llm.Message{}, err
+		return llm.Response{}, err
 	}
 	reader := bytes.NewReader(js)
 	resp, err := ai.send(ctx, ai.baseURL, reader)
 	if err != nil {
-		return llm.Message{}, err
+		return llm.Response{}, err
 	}
 	if resp == nil || len(resp.Choices) == 0 {
-		return llm.Message{}, fmt.Errorf("OpenAI: Chat: No data returned")
+		return llm.Response{}, fmt.Errorf("OpenAI: Chat: No data returned")
 	}
 
-	return resp.Choices[0].Message, nil
+	return llm.Response{
+		Message:      resp.Choices[0].Message.toLLM(),
+		Usage:        resp.Usage,
+		FinishReason: finishReason(resp.Choices[0].FinishReason),
+		Model:        resp.Model,
+		Latency:      time.Since(start),
+	}, nil
 }
 
-func (ai *OpenAI) encodeRequest(msgs llm.MessageList) ([]byte, error) {
+func (ai *OpenAI) encodeRequest(msgs llm.MessageList, tools []llm.Tool, stream bool) ([]byte, error) {
 	var js []byte
 	var err error
 	switch {
-	case ai.options != nil && len(ai.options.Tools) > 0:
+	case ai.options != nil && len(ai.options.Tools)+len(tools) > 0:
 		req := ai.options.asRequest()
-		req.Stream = false
-		req.Messages = msgs
+		req.Tools = append(append([]Tool{}, req.Tools...), toTools(tools)...)
+		req.Stream = stream
+		req.StreamOptions = streamOptionsFor(stream)
+		req.Messages = toMessages(msgs)
 		req.Model = ai.model
 		js, err = json.Marshal(&req)
 		if err != nil {
@@ -51,11 +66,12 @@ func (ai *OpenAI) encodeRequest(msgs llm.MessageList) ([]byte, error) {
 
 	case ai.options != nil:
 		req := chatRequest{
-			Stream:      false,
-			Messages:    msgs,
-			Model:       ai.model,
-			Temperature: ai.options.Temperature,
-			MaxTokens:   ai.options.MaxTokens,
+			Stream:        stream,
+			StreamOptions: streamOptionsFor(stream),
+			Messages:      toMessages(msgs),
+			Model:         ai.model,
+			Temperature:   ai.options.Temperature,
+			MaxTokens:     ai.options.MaxTokens,
 		}
 		js, err = json.Marshal(&req)
 		if err != nil {
@@ -64,9 +80,11 @@ func (ai *OpenAI) encodeRequest(msgs llm.MessageList) ([]byte, error) {
 
 	default:
 		req := chatRequest{
-			Stream:   false,
-			Messages: msgs,
-			Model:    ai.model,
+			Stream:        stream,
+			StreamOptions: streamOptionsFor(stream),
+			Messages:      toMessages(msgs),
+			Model:         ai.model,
+			Tools:         toTools(tools),
 		}
 		js, err = json.Marshal(&req)
 		if err != nil {
@@ -77,6 +95,26 @@ func (ai *OpenAI) encodeRequest(msgs llm.MessageList) ([]byte, error) {
 }
 
 func (ai *OpenAI) send(ctx context.Context, baseURL string, reader io.Reader) (*chatResponse, error) {
+	resp, err := ai.post(ctx, baseURL, reader)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	chatResp := chatResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&chatResp)
+	if err != nil {
+		return nil, err
+	}
+	if len(chatResp.Choices) == 0 {
+		return nil, fmt.Errorf("no data returned")
+	}
+
+	return &chatResp, nil
+}
+
+// post sends a chat request and returns the response for the caller to read and close
+func (ai *OpenAI) post(ctx context.Context, baseURL string, reader io.Reader) (*http.Response, error) {
 	const chatEP = "/v1/chat/completions"
 
 	ep, err := url.JoinPath(baseURL, chatEP)
@@ -96,23 +134,12 @@ func (ai *OpenAI) send(ctx context.Context, baseURL string, reader io.Reader) (*
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("OpenAI: Chat: %v %v\n%v", resp.StatusCode, resp.Status, string(body))
+		defer resp.Body.Close()
+		return nil, llm.NewHTTPError("OpenAI: Chat", resp)
 	}
-
-	chatResp := chatResponse{}
-	err = json.NewDecoder(resp.Body).Decode(&chatResp)
-	if err != nil {
-		return nil, err
-	}
-	if len(chatResp.Choices) == 0 {
-		return nil, fmt.Errorf("no data returned")
-	}
-
-	return &chatResp, nil
+	return resp, nil
 }
 
 type chatResponse struct {
@@ -123,13 +150,9 @@ type chatResponse struct {
 	SystemFingerprint string `json:"system_fingerprint"`
 	Choices           []struct {
 		Index        int         `json:"index"`
-		Message      llm.Message `json:"message"`
+		Message      message     `json:"message"`
 		Logprobs     interface{} `json:"logprobs"`
 		FinishReason string      `json:"finish_reason"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage llm.Usage `json:"usage"`
 }
@@ -0,0 +1,91 @@
+package openai
+
+import (
+	"encoding/json"
+
+	"github.com/dshills/wiggle/llm"
+)
+
+// message is a chat message in the OpenAI format
+type message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+type toolCall struct {
+	Index    int    `json:"index,omitempty"` // Identifies the call in the chunks of a streamed response
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"` // JSON object encoded as a string
+	} `json:"function"`
+}
+
+// toMessages converts the messages to the OpenAI format
+func toMessages(msgs llm.MessageList) []message {
+	messages := []message{}
+	for _, m := range msgs {
+		msg := message{Role: m.Role, Content: m.Content}
+		for _, c := range m.ToolCalls {
+			tc := toolCall{ID: c.ID, Type: "function"}
+			tc.Function.Name = c.Name
+			tc.Function.Arguments = string(c.Arguments)
+			msg.ToolCalls = append(msg.ToolCalls, tc)
+		}
+		if m.ToolResult != nil {
+			msg.Content = m.ToolResult.Content
+			msg.ToolCallID = m.ToolResult.CallID
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// toLLM converts a message returned by OpenAI
+func (m message) toLLM() llm.Message {
+	msg := llm.Message{Role: m.Role, Content: m.Content}
+	for _, tc := range m.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, llm.ToolCall{
+			ID:        tc.ID,
+			Name:      tc.Function.Name,
+			Arguments: arguments(tc.Function.Arguments),
+		})
+	}
+	return msg
+}
+
+// finishReason translates the reason OpenAI stopped generating a response
+func finishReason(reason string) string {
+	if reason == "function_call" {
+		return llm.FinishToolCalls
+	}
+	return reason
+}
+
+// arguments returns the arguments of a call as JSON, encoding arguments that are not
+// valid JSON as a string so the error shows when they are decoded
+func arguments(args string) json.RawMessage {
+	if args == "" {
+		return json.RawMessage("{}")
+	}
+	if !json.Valid([]byte(args)) {
+		data, _ := json.Marshal(args)
+		return data
+	}
+	return json.RawMessage(args)
+}
+
+// toTools converts the tools to the OpenAI format
+func toTools(tools []llm.Tool) []Tool {
+	out := []Tool{}
+	for _, t := range tools {
+		out = append(out, Tool{
+			Type:     "function",
+			Function: Function{Name: t.Name, Description: t.Description, Parameters: t.Parameters},
+		})
+	}
+	return out
+}
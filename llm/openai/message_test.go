@@ -0,0 +1,94 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToMessages(t *testing.T) {
+	call := llm.ToolCall{ID: "call_1", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)}
+	weatherCall := toolCall{ID: "call_1", Type: "function"}
+	weatherCall.Function.Name = "weather"
+	weatherCall.Function.Arguments = `{"city":"Paris"}`
+
+	msgs := toMessages(llm.MessageList{
+		llm.SystemMsg("Be brief"),
+		llm.UserMsg("Weather?"),
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{call}},
+		llm.ToolResultMsg(call, "21C"),
+		llm.ToolErrorMsg(call, errors.New("no station")),
+		llm.AssistantMsg("It is 21C"),
+	})
+	assert.Equal(t, []message{
+		{Role: "system", Content: "Be brief"},
+		{Role: "user", Content: "Weather?"},
+		{Role: "assistant", ToolCalls: []toolCall{weatherCall}},
+		{Role: "tool", Content: "21C", ToolCallID: "call_1"},
+		{Role: "tool", Content: "no station", ToolCallID: "call_1"},
+		{Role: "assistant", Content: "It is 21C"},
+	}, msgs)
+}
+
+func TestMessageToLLM(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want json.RawMessage
+	}{
+		{"object", `{"city":"Paris"}`, json.RawMessage(`{"city":"Paris"}`)},
+		{"empty", ``, json.RawMessage(`{}`)},
+		{"invalid", `{"city":`, json.RawMessage(`"{\"city\":"`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := toolCall{ID: "call_1"}
+			tc.Function.Name = "weather"
+			tc.Function.Arguments = tt.args
+			msg := message{Role: "assistant", Content: "Checking", ToolCalls: []toolCall{tc}}.toLLM()
+			assert.Equal(t, llm.Message{
+				Role:      llm.RoleAssistant,
+				Content:   "Checking",
+				ToolCalls: []llm.ToolCall{{ID: "call_1", Name: "weather", Arguments: tt.want}},
+			}, msg)
+		})
+	}
+}
+
+func TestToTools(t *testing.T) {
+	tools := toTools([]llm.Tool{{Name: "weather", Description: "Current weather"}})
+	assert.Equal(t, []Tool{{Type: "function", Function: Function{Name: "weather", Description: "Current weather"}}}, tools)
+}
+
+func TestAddToolCalls(t *testing.T) {
+	part := func(index int, id, name, args string) toolCall {
+		tc := toolCall{Index: index, ID: id}
+		tc.Function.Name = name
+		tc.Function.Arguments = args
+		return tc
+	}
+	calls := addToolCalls(nil, []toolCall{part(0, "call_1", "weather", "")})
+	calls = addToolCalls(calls, []toolCall{part(0, "", "", `{"city":`), part(1, "call_2", "time", `{}`)})
+	calls = addToolCalls(calls, []toolCall{part(0, "", "", `"Paris"}`)})
+
+	assert.Equal(t, []llm.ToolCall{
+		{ID: "call_1", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)},
+		{ID: "call_2", Name: "time", Arguments: json.RawMessage(`{}`)},
+	}, message{ToolCalls: calls}.toLLM().ToolCalls)
+}
+
+func TestFinishReason(t *testing.T) {
+	tests := map[string]string{
+		"stop":           llm.FinishStop,
+		"length":         llm.FinishLength,
+		"tool_calls":     llm.FinishToolCalls,
+		"function_call":  llm.FinishToolCalls,
+		"content_filter": llm.FinishContentFilter,
+	}
+	for reason, want := range tests {
+		assert.Equal(t, want, finishReason(reason), reason)
+	}
+}
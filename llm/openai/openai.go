@@ -12,6 +12,7 @@ import (
 
 // Compile-time check
 var _ llm.LLM = (*OpenAI)(nil)
+var _ llm.ToolChatter = (*OpenAI)(nil)
 
 type OpenAI struct {
 	baseURL string
@@ -1,6 +1,8 @@
 package openai
 
-import "github.com/dshills/wiggle/llm"
+import (
+	"github.com/dshills/wiggle/schema"
+)
 
 type Options struct {
 	Logprobs          bool   `json:"logprobs,omitempty"`
@@ -18,30 +20,9 @@ type Tool struct {
 }
 
 type Function struct {
-	Name        string    `json:"name,omitempty"`
-	Description string    `json:"description,omitempty"`
-	Parameters  Parameter `json:"parameters,omitempty"`
-}
-
-type Parameter struct {
-	Type       string     `json:"type,omitempty"`
-	Properties Properties `json:"properties,omitempty"`
-	Required   []string   `json:"required,omitempty"`
-}
-
-type Properties struct {
-	Location Location `json:"location,omitempty"`
-	Unit     Unit     `json:"unit,omitempty"`
-}
-
-type Location struct {
-	Type        string `json:"type,omitempty"`
-	Description string `json:"description,omitempty"`
-}
-
-type Unit struct {
-	Type string   `json:"type,omitempty"`
-	Enum []string `json:"enum,omitempty"`
+	Name        string        `json:"name,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Parameters  schema.Schema `json:"parameters,omitempty"`
 }
 
 func (o Options) asRequest() chatRequestWithTools {
@@ -51,16 +32,30 @@ func (o Options) asRequest() chatRequestWithTools {
 }
 
 type chatRequest struct {
-	Model       string        `json:"model,omitempty"`
-	Messages    []llm.Message `json:"messages,omitempty"`
-	Stream      bool          `json:"stream,omitempty"`
-	Temperature *int          `json:"temperature,omitempty"`
-	MaxTokens   *int          `json:"max_tokens,omitempty"`
+	Model         string         `json:"model,omitempty"`
+	Messages      []message      `json:"messages,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+	Temperature   *int           `json:"temperature,omitempty"`
+	MaxTokens     *int           `json:"max_tokens,omitempty"`
+	Tools         []Tool         `json:"tools,omitempty"`
 }
 
 type chatRequestWithTools struct {
 	Options
-	Model    string        `json:"model,omitempty"`
-	Messages []llm.Message `json:"messages,omitempty"`
-	Stream   bool          `json:"stream,omitempty"`
+	Model         string         `json:"model,omitempty"`
+	Messages      []message      `json:"messages,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"` // Send the usage in a last chunk without choices
+}
+
+func streamOptionsFor(stream bool) *streamOptions {
+	if !stream {
+		return nil
+	}
+	return &streamOptions{IncludeUsage: true}
 }
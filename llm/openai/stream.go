@@ -0,0 +1,101 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dshills/wiggle/llm"
+)
+
+// Compile-time check
+var _ llm.Streamer = (*OpenAI)(nil)
+var _ llm.ToolStreamer = (*OpenAI)(nil)
+
+// ChatStream streams the response to msgs as it is generated
+func (ai *OpenAI) ChatStream(ctx context.Context, msgs llm.MessageList) (<-chan llm.StreamEvent, error) {
+	return ai.ChatStreamWithTools(ctx, msgs, nil)
+}
+
+// ChatStreamWithTools is ChatStream offering the tools to the model
+func (ai *OpenAI) ChatStreamWithTools(ctx context.Context, msgs llm.MessageList, tools []llm.Tool) (<-chan llm.StreamEvent, error) {
+	start := time.Now()
+	js, err := ai.encodeRequest(msgs, tools, true)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ai.post(ctx, ai.baseURL, bytes.NewReader(js))
+	if err != nil {
+		return nil, err
+	}
+	return llm.Stream(ctx, resp.Body, func(delta func(string)) (llm.Response, error) {
+		msg := message{Role: llm.RoleAssistant}
+		reply := llm.Response{}
+		content := strings.Builder{}
+		err := llm.ReadSSE(resp.Body, func(_, data string) error {
+			if data == "[DONE]" {
+				return llm.ErrStopSSE
+			}
+			chunk := streamChunk{}
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return fmt.Errorf("OpenAI: ChatStream: %w", err)
+			}
+			if chunk.Usage != nil {
+				reply.Usage = *chunk.Usage
+			}
+			if chunk.Model != "" {
+				reply.Model = chunk.Model
+			}
+			for _, choice := range chunk.Choices {
+				if choice.Index == 0 {
+					content.WriteString(choice.Delta.Content)
+					delta(choice.Delta.Content)
+					msg.ToolCalls = addToolCalls(msg.ToolCalls, choice.Delta.ToolCalls)
+					if choice.FinishReason != "" {
+						reply.FinishReason = finishReason(choice.FinishReason)
+					}
+				}
+			}
+			return nil
+		})
+		msg.Content = content.String()
+		reply.Message = msg.toLLM()
+		reply.Latency = time.Since(start)
+		return reply, err
+	}), nil
+}
+
+// addToolCalls adds the parts of tool calls streamed in a chunk to the calls received so
+// far. The first part of a call has its ID and name, and the arguments are streamed in
+// pieces.
+func addToolCalls(calls []toolCall, parts []toolCall) []toolCall {
+	for _, p := range parts {
+		if p.Index >= len(calls) {
+			calls = append(calls, make([]toolCall, p.Index-len(calls)+1)...)
+		}
+		c := &calls[p.Index]
+		if p.ID != "" {
+			c.ID = p.ID
+		}
+		if p.Function.Name != "" {
+			c.Function.Name = p.Function.Name
+		}
+		c.Function.Arguments += p.Function.Arguments
+	}
+	return calls
+}
+
+// streamChunk is an event of a streamed chat response
+type streamChunk struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index        int     `json:"index"`
+		Delta        message `json:"delta"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *llm.Usage `json:"usage"`
+}
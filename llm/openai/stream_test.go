@@ -0,0 +1,122 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestServer replies to every request with body, sending the decoded request on reqs
+func newTestServer(t *testing.T, body string, reqs chan<- chatRequest) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		req := chatRequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		reqs <- req
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+const textStream = `data: {"id":"1","model":"gpt-4o-mini-2024-07-18","choices":[{"index":0,"delta":{"role":"assistant","content":""},"finish_reason":null}],"usage":null}
+
+data: {"id":"1","model":"gpt-4o-mini-2024-07-18","choices":[{"index":0,"delta":{"content":"Hello"},"finish_reason":null}],"usage":null}
+
+data: {"id":"1","model":"gpt-4o-mini-2024-07-18","choices":[{"index":0,"delta":{"content":" there"},"finish_reason":null}],"usage":null}
+
+data: {"id":"1","model":"gpt-4o-mini-2024-07-18","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":null}
+
+data: {"id":"1","model":"gpt-4o-mini-2024-07-18","choices":[],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}
+
+data: [DONE]
+
+`
+
+const toolStream = `data: {"id":"1","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":null,"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"weather","arguments":""}}]},"finish_reason":null}]}
+
+data: {"id":"1","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]},"finish_reason":null}]}
+
+data: {"id":"1","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]},"finish_reason":null}]}
+
+data: {"id":"1","model":"gpt-4o-mini","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}
+
+data: [DONE]
+
+`
+
+func TestChatStream(t *testing.T) {
+	reqs := make(chan chatRequest, 1)
+	srv := newTestServer(t, textStream, reqs)
+	ai := New(srv.URL, "gpt-4o-mini", "key", nil).(llm.Streamer)
+
+	events, err := ai.ChatStream(context.Background(), llm.MessageList{llm.SystemMsg("Be brief"), llm.UserMsg("Hi")})
+	assert.NoError(t, err)
+	deltas := []string{}
+	resp, err := llm.Collect(events, func(d string) { deltas = append(deltas, d) })
+	assert.NoError(t, err)
+
+	req := <-reqs
+	assert.True(t, req.Stream)
+	assert.Equal(t, &streamOptions{IncludeUsage: true}, req.StreamOptions)
+	assert.Len(t, req.Messages, 2)
+
+	assert.Equal(t, []string{"Hello", " there"}, deltas)
+	assert.Equal(t, "Hello there", resp.Content)
+	assert.Equal(t, llm.RoleAssistant, resp.Role)
+	assert.Equal(t, llm.Usage{PromptTokens: 5, CompletionTokens: 2, TotalTokens: 7}, resp.Usage)
+	assert.Equal(t, llm.FinishStop, resp.FinishReason)
+	assert.Equal(t, "gpt-4o-mini-2024-07-18", resp.Model)
+	assert.Positive(t, resp.Latency)
+}
+
+func TestChatStream_ToolCalls(t *testing.T) {
+	reqs := make(chan chatRequest, 1)
+	srv := newTestServer(t, toolStream, reqs)
+	ai := New(srv.URL, "gpt-4o-mini", "key", nil).(llm.ToolStreamer)
+
+	events, err := ai.ChatStreamWithTools(context.Background(), llm.MessageList{llm.UserMsg("Weather?")}, []llm.Tool{{Name: "weather"}})
+	assert.NoError(t, err)
+	resp, err := llm.Collect(events, nil)
+	assert.NoError(t, err)
+
+	assert.Len(t, (<-reqs).Tools, 1)
+	assert.Equal(t, []llm.ToolCall{{ID: "call_1", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)}}, resp.ToolCalls)
+	assert.Equal(t, llm.FinishToolCalls, resp.FinishReason)
+}
+
+func TestChatStream_Error(t *testing.T) {
+	srv := newTestServer(t, "data: {not json}\n\n", make(chan chatRequest, 1))
+	ai := New(srv.URL, "gpt-4o-mini", "key", nil).(llm.Streamer)
+
+	events, err := ai.ChatStream(context.Background(), llm.MessageList{llm.UserMsg("Hi")})
+	assert.NoError(t, err)
+	_, err = llm.Collect(events, nil)
+	assert.ErrorContains(t, err, "OpenAI: ChatStream:")
+}
+
+func TestChat(t *testing.T) {
+	body := `{"id":"1","model":"gpt-4o-mini-2024-07-18","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"length"}],
+		"usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7}}`
+	reqs := make(chan chatRequest, 1)
+	srv := newTestServer(t, body, reqs)
+	temp := 1
+	ai := New(srv.URL, "gpt-4o-mini", "key", &Options{Temperature: &temp})
+
+	resp, err := ai.Chat(context.Background(), llm.MessageList{llm.UserMsg("Hi")})
+	assert.NoError(t, err)
+	req := <-reqs
+	assert.False(t, req.Stream)
+	assert.Nil(t, req.StreamOptions)
+	assert.Equal(t, &temp, req.Temperature)
+	assert.Equal(t, "Hello", resp.Content)
+	assert.Equal(t, llm.FinishLength, resp.FinishReason)
+	assert.Equal(t, 7, resp.Usage.TotalTokens)
+}
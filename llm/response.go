@@ -0,0 +1,23 @@
+package llm
+
+import "time"
+
+// Reasons a model stopped generating a response. Providers translate their own reasons
+// to these; reasons without an equivalent are passed through unchanged.
+const (
+	FinishStop          = "stop"           // The model finished its response or hit a stop sequence
+	FinishLength        = "length"         // The response reached the maximum number of tokens
+	FinishToolCalls     = "tool_calls"     // The model stopped to call tools
+	FinishContentFilter = "content_filter" // The response was withheld by a safety filter
+)
+
+// Response is the response to a chat request: the message returned by the model and
+// what the provider reported about generating it. It embeds the Message so its content
+// and tool calls can be used directly.
+type Response struct {
+	Message
+	Usage        Usage         `json:"usage"`
+	FinishReason string        `json:"finish_reason,omitempty"` // One of the Finish reasons, or the provider's own
+	Model        string        `json:"model,omitempty"`         // Model that generated the response, as reported by the provider
+	Latency      time.Duration `json:"latency,omitempty"`       // Time from sending the request until the response was complete
+}
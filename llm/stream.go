@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Usage is the number of tokens used by a chat request
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// StreamEvent is part of a streamed chat response. Events carry the text added to the
+// response in Delta as it arrives. The last event sent has Done set and carries the
+// complete Response, or Err if the stream failed.
+type StreamEvent struct {
+	Delta    string
+	Done     bool
+	Response Response
+	Err      error
+}
+
+// Streamer is implemented by LLMs that can stream chat responses. ChatStream returns an
+// error if the request fails before the response starts; later errors are sent on the
+// channel. The channel is closed after the last event. Cancel ctx to stop reading the
+// response early.
+type Streamer interface {
+	ChatStream(ctx context.Context, msgs MessageList) (<-chan StreamEvent, error)
+}
+
+// StreamChat streams the response of lm if it implements Streamer. Otherwise it calls
+// lm.Chat and sends the complete response as a single delta.
+func StreamChat(ctx context.Context, lm LLM, msgs MessageList) (<-chan StreamEvent, error) {
+	if s, ok := lm.(Streamer); ok {
+		return s.ChatStream(ctx, msgs)
+	}
+	resp, err := lm.Chat(ctx, msgs)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan StreamEvent, 2)
+	ch <- StreamEvent{Delta: resp.Content}
+	ch <- StreamEvent{Done: true, Response: resp}
+	close(ch)
+	return ch, nil
+}
+
+// Collect reads a stream to the end, calling fn with each delta if fn is not nil, and
+// returns the complete response
+func Collect(events <-chan StreamEvent, fn func(delta string)) (Response, error) {
+	for ev := range events {
+		switch {
+		case ev.Err != nil:
+			return ev.Response, ev.Err
+		case ev.Done:
+			return ev.Response, nil
+		case ev.Delta != "" && fn != nil:
+			fn(ev.Delta)
+		}
+	}
+	return Response{}, errors.New("stream ended without a response")
+}
+
+// Stream is used by providers to stream a response body. It calls read in a goroutine
+// and returns the channel the events are sent on. read parses the body, calling delta
+// with each piece of text as it arrives, and returns the complete response.
+// The body is closed once read returns and the last event sent, with the error read
+// returned if any.
+func Stream(ctx context.Context, body io.ReadCloser, read func(delta func(string)) (Response, error)) <-chan StreamEvent {
+	ch := make(chan StreamEvent, 16)
+	send := func(ev StreamEvent) bool {
+		select {
+		case ch <- ev:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	go func() {
+		defer close(ch)
+		defer body.Close()
+		cancelled := false
+		resp, err := read(func(s string) {
+			if !cancelled && s != "" {
+				cancelled = !send(StreamEvent{Delta: s})
+			}
+		})
+		send(StreamEvent{Done: true, Response: resp, Err: err})
+	}()
+	return ch
+}
+
+// ErrStopSSE is returned by the function passed to ReadSSE to stop reading without an error
+var ErrStopSSE = errors.New("stop reading server-sent events")
+
+// ReadSSE reads server-sent events from r, calling fn with the type and data of each
+// event. The type is empty for events without one. Reading stops at the end of r, when
+// fn returns ErrStopSSE, or with the first other error fn returns.
+func ReadSSE(r io.Reader, fn func(event, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	event := ""
+	data := []string{}
+	dispatch := func() error {
+		defer func() { event, data = "", data[:0] }()
+		if len(data) == 0 {
+			return nil
+		}
+		return fn(event, strings.Join(data, "\n"))
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if err := dispatch(); err != nil {
+				return stopSSE(err)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // Comment
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return stopSSE(dispatch())
+}
+
+func stopSSE(err error) error {
+	if errors.Is(err, ErrStopSSE) {
+		return nil
+	}
+	return err
+}
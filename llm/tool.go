@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dshills/wiggle/schema"
+)
+
+// Tool describes a function the model can ask to call. Parameters describes the JSON
+// object the model passes as the arguments of a call.
+type Tool struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Parameters  schema.Schema `json:"parameters"`
+}
+
+// ToolCall is a call of a tool requested by the model in an assistant message
+type ToolCall struct {
+	ID        string          `json:"id"`        // Identifies the call, generated for providers that do not return one
+	Name      string          `json:"name"`      // Name of the tool
+	Arguments json.RawMessage `json:"arguments"` // Arguments as a JSON object
+}
+
+// DecodeArguments decodes the arguments of the call into v
+func (c ToolCall) DecodeArguments(v any) error {
+	args := c.Arguments
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("tool %s: arguments: %w", c.Name, err)
+	}
+	return nil
+}
+
+// ToolResult is the result of a tool call sent back to the model in a message with RoleTool
+type ToolResult struct {
+	CallID  string `json:"call_id"`            // ID of the ToolCall
+	Name    string `json:"name"`               // Name of the tool
+	Content string `json:"content"`            // Result of the call, text or JSON
+	IsError bool   `json:"is_error,omitempty"` // Content describes why the call failed
+}
+
+// ToolResultMsg returns the message answering call with the result of the tool
+func ToolResultMsg(call ToolCall, content string) Message {
+	return Message{Role: RoleTool, ToolResult: &ToolResult{CallID: call.ID, Name: call.Name, Content: content}}
+}
+
+// ToolErrorMsg returns the message telling the model that call failed with err
+func ToolErrorMsg(call ToolCall, err error) Message {
+	return Message{Role: RoleTool, ToolResult: &ToolResult{CallID: call.ID, Name: call.Name, Content: err.Error(), IsError: true}}
+}
+
+// ToolChatter is implemented by LLMs that can offer tools to the model. ChatWithTools
+// is Chat with the tools offered in the request; providers translate them to their
+// native format.
+type ToolChatter interface {
+	ChatWithTools(ctx context.Context, msgs MessageList, tools []Tool) (Response, error)
+}
+
+// ToolStreamer is implemented by LLMs that can offer tools to the model in a streamed
+// chat. ChatStreamWithTools is Streamer.ChatStream with the tools offered in the request.
+type ToolStreamer interface {
+	ChatStreamWithTools(ctx context.Context, msgs MessageList, tools []Tool) (<-chan StreamEvent, error)
+}
+
+// ErrToolsNotSupported is returned when tools are offered to an LLM that cannot take them
+var ErrToolsNotSupported = errors.New("tools are not supported")
+
+// ChatWithTools sends msgs to lm offering the tools to the model. Without tools it calls
+// lm.Chat. It returns ErrToolsNotSupported if lm does not implement ToolChatter.
+func ChatWithTools(ctx context.Context, lm LLM, msgs MessageList, tools []Tool) (Response, error) {
+	if len(tools) == 0 {
+		return lm.Chat(ctx, msgs)
+	}
+	tc, ok := lm.(ToolChatter)
+	if !ok {
+		return Response{}, fmt.Errorf("%T: %w", lm, ErrToolsNotSupported)
+	}
+	return tc.ChatWithTools(ctx, msgs, tools)
+}
+
+// CallID returns the ID of the i-th call of a response, for providers that do not identify calls
+func CallID(i int) string {
+	return fmt.Sprintf("call_%d", i)
+}
@@ -0,0 +1,275 @@
+package nlib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/node"
+	"github.com/dshills/wiggle/schema"
+)
+
+// Compile-time check to ensure AgentNode implements the node.Node interface
+var _ node.Node = (*AgentNode)(nil)
+
+// DefaultAgentMaxSteps is the number of LLM requests an AgentNode makes for a signal when
+// no budget is set
+const DefaultAgentMaxSteps = 10
+
+// Status and metadata keys of the steps recorded by an AgentNode
+const (
+	StatusAgentStep = "agent-step"  // Status of the history entries recording the steps
+	MetaAgentStep   = "agent.step"  // JSON encoded AgentStep, one value per step
+	MetaAgentSteps  = "agent.steps" // Number of LLM requests made for the signal
+)
+
+// ErrAgentMaxSteps is returned when an AgentNode has made its maximum number of LLM
+// requests for a signal without getting a final answer
+var ErrAgentMaxSteps = errors.New("agent: maximum steps reached without an answer")
+
+// AgentTool is a Go function an AgentNode offers to the model as a tool. Call receives the
+// arguments of the call as a JSON object and returns the result sent back to the model.
+type AgentTool struct {
+	llm.Tool
+	Call func(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+// NewAgentTool creates an AgentTool calling fn with the arguments decoded into a value of
+// type A, usually a struct. The parameters of the tool are described by schema.FromType(A).
+// Results that are not strings are sent to the model encoded as JSON.
+func NewAgentTool[A, R any](name, description string, fn func(ctx context.Context, args A) (R, error)) AgentTool {
+	return AgentTool{
+		Tool: llm.Tool{
+			Name:        name,
+			Description: description,
+			Parameters:  schema.FromType(reflect.TypeOf((*A)(nil)).Elem()),
+		},
+		Call: func(ctx context.Context, raw json.RawMessage) (string, error) {
+			var args A
+			if err := (llm.ToolCall{Name: name, Arguments: raw}).DecodeArguments(&args); err != nil {
+				return "", err
+			}
+			result, err := fn(ctx, args)
+			if err != nil {
+				return "", err
+			}
+			if s, ok := any(result).(string); ok {
+				return s, nil
+			}
+			data, err := json.Marshal(result)
+			if err != nil {
+				return "", fmt.Errorf("tool %s: result: %w", name, err)
+			}
+			return string(data), nil
+		},
+	}
+}
+
+// AgentStep records a step of an AgentNode: a tool call the model requested, or the final
+// answer when Tool is empty
+type AgentStep struct {
+	Step      int             `json:"step"` // Number of the LLM request the step belongs to, from 1
+	NodeID    string          `json:"node_id"`
+	Tool      string          `json:"tool,omitempty"`
+	CallID    string          `json:"call_id,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Result    string          `json:"result"`
+	Err       string          `json:"err,omitempty"`
+	Duration  time.Duration   `json:"duration"`
+}
+
+// AgentSteps returns the steps recorded in the metadata of a signal by the AgentNodes it
+// passed through
+func AgentSteps(sig node.Signal) ([]AgentStep, error) {
+	steps := []AgentStep{}
+	for _, m := range sig.Meta.Values(MetaAgentStep) {
+		step := AgentStep{}
+		if err := json.Unmarshal([]byte(m.Value), &step); err != nil {
+			return nil, fmt.Errorf("agent step: %w", err)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// AgentNode answers the task of a signal with an LLM that can call Go functions as tools.
+// It sends the task to the LLM, runs the tools the model asks for, sends their results
+// back and repeats until the model answers without calling a tool. The answer becomes
+// the signal's Result. The node fails the signal if the model has not answered after
+// the maximum number of steps.
+//
+// Every tool call and the answer are recorded as an AgentStep, both in the history, as
+// signals with StatusAgentStep whose parent is the processed signal, and in the outgoing
+// signal's metadata, where AgentSteps reads them. The metadata also describes the last
+// LLM response, with the tokens of every request counted, see SetLLMResponse.
+type AgentNode struct {
+	EmptyNode
+	lm       llm.LLM
+	tools    []AgentTool
+	maxSteps int
+	system   string
+}
+
+// NewAgentNode creates an AgentNode offering the tools to the LLM. maxSteps limits the
+// number of LLM requests made for a signal; zero uses DefaultAgentMaxSteps.
+func NewAgentNode(lm llm.LLM, tools []AgentTool, maxSteps int, mgr node.StateManager, options node.Options) *AgentNode {
+	n := AgentNode{lm: lm, tools: tools, maxSteps: maxSteps}
+	if n.maxSteps <= 0 {
+		n.maxSteps = DefaultAgentMaxSteps
+	}
+	n.SetOptions(options)
+	n.SetStateManager(mgr)
+	n.MakeInputCh()
+	n.SetProcessFunc(n.processSignal)
+
+	return &n
+}
+
+// AddTool adds tools offered to the model. It must be called before the node is started.
+func (n *AgentNode) AddTool(tools ...AgentTool) {
+	n.tools = append(n.tools, tools...)
+}
+
+// SetSystemPrompt sets instructions sent to the LLM as a system message before the task,
+// for example when to use which tool
+func (n *AgentNode) SetSystemPrompt(prompt string) {
+	n.system = prompt
+}
+
+func (n *AgentNode) processSignal(sig node.Signal) {
+	var err error
+	ctx, done := n.BeginSignal(sig)
+	defer done()
+
+	sig, err = n.PreProcessSignal(ctx, sig)
+	if err != nil {
+		n.Fail(sig, err)
+		return
+	}
+
+	sig.Status = StatusInProcess
+	sig = n.applyGuidance(ctx, sig)
+
+	sig, err = n.run(ctx, sig)
+	if err != nil {
+		n.Fail(sig, err)
+		return
+	}
+	sig.Status = StatusSuccess
+
+	sig, err = n.PostProcessSignal(ctx, sig)
+	if err != nil {
+		n.Fail(sig, err)
+		return
+	}
+
+	if err := n.SendToConnected(ctx, sig); err != nil {
+		n.Fail(sig, err)
+		return
+	}
+}
+
+// run is the reasoning loop. The steps taken are added to the signal's metadata whether
+// or not the loop succeeds.
+func (n *AgentNode) run(ctx context.Context, sig node.Signal) (node.Signal, error) {
+	if sig.Task == nil {
+		return sig, ErrNoTask
+	}
+	tools := make(map[string]AgentTool)
+	defs := []llm.Tool{}
+	for _, t := range n.tools {
+		tools[t.Name] = t
+		defs = append(defs, t.Tool)
+	}
+	msgs := llm.MessageList{}
+	if n.system != "" {
+		msgs = append(msgs, llm.SystemMsg(n.system))
+	}
+	msgs = append(msgs, llm.UserMsg(sig.Task.String()))
+
+	for step := 1; step <= n.maxSteps; step++ {
+		sig.Meta = sig.Meta.SetInt(MetaAgentSteps, step)
+
+		var resp llm.Response
+		start := time.Now()
+		out, err := n.RunWithErrorGuidance(ctx, sig, func(s node.Signal) (node.Signal, error) {
+			var err error
+			resp, err = ChatWithTools(ctx, n.lm, msgs, defs)
+			return s, err
+		})
+		if err != nil {
+			return sig, err
+		}
+		if _, ignored := out.Meta.Get(MetaErrorIgnored); ignored {
+			return out, nil // Passed through without an answer
+		}
+		sig = SetLLMResponse(sig, resp)
+		msg := resp.Message
+		msgs = append(msgs, msg)
+
+		if len(msg.ToolCalls) == 0 {
+			sig = n.recordStep(sig, AgentStep{Step: step, Result: msg.Content, Duration: time.Since(start)})
+			sig.Result = &Carrier{TextData: msg.Content}
+			return sig, nil
+		}
+
+		for _, call := range msg.ToolCalls {
+			result, err := n.callTool(ctx, tools, call)
+			st := AgentStep{Step: step, Tool: call.Name, CallID: call.ID, Arguments: call.Arguments, Result: result, Duration: time.Since(start)}
+			if err != nil {
+				st.Err = err.Error()
+				msgs = append(msgs, llm.ToolErrorMsg(call, err))
+				n.LogSignal(node.LevelWarn, sig, "Tool failed", "tool", call.Name, node.LogKeyError, err)
+			} else {
+				msgs = append(msgs, llm.ToolResultMsg(call, result))
+				n.LogSignal(node.LevelDebug, sig, "Tool called", "tool", call.Name)
+			}
+			sig = n.recordStep(sig, st)
+			start = time.Now()
+		}
+	}
+	return sig, fmt.Errorf("%w (%d)", ErrAgentMaxSteps, n.maxSteps)
+}
+
+func (n *AgentNode) callTool(ctx context.Context, tools map[string]AgentTool, call llm.ToolCall) (result string, err error) {
+	_, span := StartSpan(ctx, "agent.tool")
+	span.SetAttr("tool.name", call.Name)
+	defer func() { span.End(err) }()
+
+	tool, ok := tools[call.Name]
+	if !ok || tool.Call == nil {
+		return "", fmt.Errorf("unknown tool %s", call.Name)
+	}
+	return tool.Call(ctx, call.Arguments)
+}
+
+// recordStep adds the step to the history and to the signal's metadata
+func (n *AgentNode) recordStep(sig node.Signal, st AgentStep) node.Signal {
+	st.NodeID = n.ID()
+	m, err := node.JSONMeta(MetaAgentStep, st)
+	if err != nil {
+		n.LogSignal(node.LevelWarn, sig, "Agent step not recorded", node.LogKeyError, err)
+		return sig
+	}
+	sig.Meta = sig.Meta.Set(m.WithRule(node.MetaAccumulate))
+
+	hx := node.Signal{
+		ID:        NewSignalID(),
+		ParentIDs: []string{sig.ID},
+		NodeID:    n.ID(),
+		RunID:     sig.RunID,
+		Path:      sig.Path,
+		Status:    StatusAgentStep,
+		Task:      &Carrier{TextData: string(st.Arguments)},
+		Result:    &Carrier{TextData: st.Result},
+		Err:       st.Err,
+		Meta:      node.Metadata{m},
+		Created:   time.Now(),
+	}
+	n.StateManager().AddHistory(hx)
+	return sig
+}
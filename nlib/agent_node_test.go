@@ -0,0 +1,141 @@
+package nlib_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/nmock"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type weatherArgs struct {
+	City string `json:"city" description:"Name of the city"`
+}
+
+func weatherTool() nlib.AgentTool {
+	return nlib.NewAgentTool("weather", "Current weather of a city", func(_ context.Context, args weatherArgs) (map[string]any, error) {
+		if args.City == "" {
+			return nil, errors.New("missing city")
+		}
+		return map[string]any{"city": args.City, "temp": 21}, nil
+	})
+}
+
+// newScriptedLLM returns the replies in order, one per request
+func newScriptedLLM(replies ...llm.Message) *nmock.MockLLM {
+	lm := new(nmock.MockLLM)
+	lm.On("Model").Return("mock")
+	for _, r := range replies {
+		lm.On("ChatWithTools", mock.Anything, mock.Anything, mock.Anything).Return(r, nil).Once()
+	}
+	return lm
+}
+
+func toolCallMsg(name, args string) llm.Message {
+	return llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: llm.CallID(0), Name: name, Arguments: json.RawMessage(args)}}}
+}
+
+func TestNewAgentTool(t *testing.T) {
+	tool := weatherTool()
+	assert.Equal(t, "weather", tool.Name)
+	assert.Equal(t, []string{"city"}, tool.Parameters.Required)
+	assert.Equal(t, "Name of the city", tool.Parameters.Properties["city"].Description)
+
+	result, err := tool.Call(context.Background(), json.RawMessage(`{"city":"Paris"}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"city":"Paris","temp":21}`, result)
+
+	_, err = tool.Call(context.Background(), json.RawMessage(`{}`))
+	assert.EqualError(t, err, "missing city")
+}
+
+func TestAgentNode(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	lm := newScriptedLLM(
+		toolCallMsg("weather", `{"city":"Paris"}`),
+		toolCallMsg("forecast", `{}`),
+		llm.AssistantMsg("It is 21 degrees in Paris"),
+	)
+	agent := nlib.NewAgentNode(lm, []nlib.AgentTool{weatherTool()}, 0, mgr, node.Options{ID: "agent"})
+
+	sig, err := nlib.Run(context.Background(), agent, node.Signal{Task: nlib.NewTextCarrier("Weather in Paris?")})
+	assert.NoError(t, err)
+	assert.Equal(t, "It is 21 degrees in Paris", sig.Result.String())
+
+	// The tools are offered and their results sent back to the model
+	lm.AssertNumberOfCalls(t, "ChatWithTools", 3)
+	last := lm.Calls[len(lm.Calls)-1]
+	assert.Len(t, last.Arguments.Get(2).([]llm.Tool), 1)
+	msgs := last.Arguments.Get(1).(llm.MessageList)
+	assert.Len(t, msgs, 5)
+	assert.Equal(t, llm.RoleTool, msgs[2].Role)
+	assert.JSONEq(t, `{"city":"Paris","temp":21}`, msgs[2].ToolResult.Content)
+	assert.True(t, msgs[4].ToolResult.IsError)
+
+	steps, err := nlib.AgentSteps(sig)
+	assert.NoError(t, err)
+	assert.Len(t, steps, 3)
+	assert.Equal(t, "weather", steps[0].Tool)
+	assert.Equal(t, "forecast", steps[1].Tool)
+	assert.Equal(t, "unknown tool forecast", steps[1].Err)
+	assert.Equal(t, 3, steps[2].Step)
+	assert.Equal(t, "agent", steps[2].NodeID)
+	count, _ := sig.Meta.GetInt(nlib.MetaAgentSteps)
+	assert.Equal(t, 3, count)
+
+	hx := 0
+	for _, h := range mgr.FilterRunHistory(sig.RunID) {
+		if h.Status == nlib.StatusAgentStep {
+			hx++
+			assert.Equal(t, []string{sig.ID}, h.ParentIDs)
+		}
+	}
+	assert.Equal(t, 3, hx)
+}
+
+func TestAgentNode_MaxSteps(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	lm := newScriptedLLM(toolCallMsg("weather", `{"city":"Paris"}`), toolCallMsg("weather", `{"city":"Rome"}`))
+	agent := nlib.NewAgentNode(lm, nil, 2, mgr, node.Options{ID: "agent"})
+	agent.AddTool(weatherTool())
+
+	_, err := nlib.Run(context.Background(), agent, node.Signal{Task: nlib.NewTextCarrier("Weather?")})
+	// The run reports the failure of the node as text
+	assert.ErrorContains(t, err, nlib.ErrAgentMaxSteps.Error()+" (2)")
+	lm.AssertNumberOfCalls(t, "ChatWithTools", 2)
+}
+
+func TestAgentNode_ToolsNotSupported(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	lm := struct{ llm.LLM }{newReplyLLM("HELLO", nil)} // Hides ChatWithTools
+	agent := nlib.NewAgentNode(lm, []nlib.AgentTool{weatherTool()}, 0, mgr, node.Options{ID: "agent"})
+
+	_, err := nlib.Run(context.Background(), agent, node.Signal{Task: nlib.NewTextCarrier("Weather?")})
+	assert.ErrorContains(t, err, llm.ErrToolsNotSupported.Error())
+
+	// Without tools the agent only needs Chat
+	agent = nlib.NewAgentNode(lm, nil, 0, mgr, node.Options{ID: "agent"})
+	sig, err := nlib.Run(context.Background(), agent, node.Signal{Task: nlib.NewTextCarrier("Weather?")})
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO", sig.Result.String())
+}
+
+func TestAgentNode_IgnoredError(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	lm := newReplyLLM("", errors.New("unavailable"))
+	agent := nlib.NewAgentNode(lm, []nlib.AgentTool{weatherTool()}, 0, mgr, node.Options{ID: "agent", ErrorGuidance: nlib.NewIgnoreErrorGuidance()})
+	agent.Connect(nlib.NewAINode(newReplyLLM("HELLO", nil), mgr, node.Options{ID: "next"}))
+
+	sig, err := nlib.Run(context.Background(), agent, node.Signal{Task: nlib.NewTextCarrier("Weather?")})
+	assert.NoError(t, err)
+	assert.Equal(t, "Weather?", sig.Task.String(), "the agent passes its task on instead of an empty answer")
+	steps, err := nlib.AgentSteps(sig)
+	assert.NoError(t, err)
+	assert.Empty(t, steps)
+}
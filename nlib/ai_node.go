@@ -2,7 +2,8 @@ package nlib
 
 import (
 	"context"
-	"fmt"
+	"io"
+	"sync"
 	"time"
 
 	"github.com/dshills/wiggle/llm"
@@ -16,46 +17,83 @@ var _ node.Node = (*AINode)(nil)
 // AINode represents a node that uses a large language model (LLM) to process signals.
 // It embeds EmptyNode for base functionality and integrates with the LLM through the lm field.
 type AINode struct {
-	EmptyNode         // Provides base node functionality like logging, state management, etc.
-	lm        llm.LLM // The large language model (LLM) used for processing the node's signals
+	EmptyNode            // Provides base node functionality like logging, state management, etc.
+	lm        llm.LLM    // The large language model (LLM) used for processing the node's signals
+	stream    StreamFunc // Optional function receiving the response as it is generated
+	system    string     // Optional system prompt sent before the task
+}
+
+// StreamFunc receives the text of an LLM response as it is generated. sig is the signal
+// the response is for. Nodes processing several signals at once call it concurrently.
+type StreamFunc func(sig node.Signal, delta string)
+
+// StreamWriter returns a StreamFunc writing the text to w, for example the writer of an
+// OutputStringNode or os.Stdout. Writes are serialized, but the responses to signals
+// processed at the same time are interleaved.
+func StreamWriter(w io.Writer) StreamFunc {
+	mu := sync.Mutex{}
+	return func(_ node.Signal, delta string) {
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = io.WriteString(w, delta)
+	}
 }
 
 // NewAINode creates a new AINode with the specified LLM, state manager, and options.
 // It sets up the node by configuring options, state management, and input channel.
-// A goroutine is started to listen for incoming signals and process them using the LLM.
-func NewAINode(lm llm.LLM, sm node.StateManager, options node.Options) node.Node {
+// The node processes incoming signals using the LLM once it has been started; use
+// SetStreamFunc to stream its responses as they are generated.
+func NewAINode(lm llm.LLM, sm node.StateManager, options node.Options) *AINode {
 	n := AINode{lm: lm} // Initialize the AINode with the provided LLM
 	n.SetOptions(options)
 	n.SetStateManager(sm)
 	n.MakeInputCh()
-
-	// Start a goroutine to listen for signals or handle termination events
-	go func() {
-		for {
-			select {
-			case sig := <-n.InputCh():
-				n.LogInfo("Received Signal")
-				n.processSignal(sig) // Process the received signal
-			case <-n.StateManager().Register():
-				n.LogInfo("Received Done")
-				return // Terminate the goroutine when done
-			}
-		}
-	}()
+	n.SetProcessFunc(n.processSignal)
 
 	return &n
 }
 
+// SetStreamFunc streams the LLM responses to fn as they are generated. LLMs implementing
+// llm.Streamer stream the text as it arrives; other LLMs pass the whole response to fn once
+// they have returned it. The complete response is still set as the signal's Result once it
+// has arrived. A retried request streams its response again.
+func (n *AINode) SetStreamFunc(fn StreamFunc) {
+	n.stream = fn
+}
+
+// SetSystemPrompt sets instructions sent to the LLM as a system message before each task,
+// for example the persona or output format the node's responses should follow. The task
+// stays the user message, so guidance does not need to repeat the instructions.
+func (n *AINode) SetSystemPrompt(prompt string) {
+	n.system = prompt
+}
+
+// Messages returns the messages sent to the LLM for the signal: the system prompt, if one
+// is set, followed by the signal's task as the user message. A signal without a task
+// sends an empty user message.
+func (n *AINode) Messages(sig node.Signal) llm.MessageList {
+	msgs := llm.MessageList{}
+	if n.system != "" {
+		msgs = append(msgs, llm.SystemMsg(n.system))
+	}
+	task := ""
+	if sig.Task != nil {
+		task = sig.Task.String()
+	}
+	return append(msgs, llm.UserMsg(task))
+}
+
 // processSignal handles the signal processing for the AINode. It preprocesses the signal,
 // sends it to the LLM for processing, and handles the response. If any error occurs during
 // processing, the signal is marked as failed. The function also logs the total time taken to process the signal.
 func (n *AINode) processSignal(sig node.Signal) {
 	start := time.Now()
 	var err error
-	var ctx = context.TODO()
+	ctx, done := n.BeginSignal(sig)
+	defer done()
 
 	// Preprocess the signal before sending it to the LLM
-	sig, err = n.PreProcessSignal(sig)
+	sig, err = n.PreProcessSignal(ctx, sig)
 	if err != nil {
 		n.Fail(sig, err)
 		return
@@ -64,24 +102,14 @@ func (n *AINode) processSignal(sig node.Signal) {
 	sig.Status = StatusInProcess // Set signal status to in process
 
 	// Optionally generate guidance (modify the signal) before sending to the LLM
-	if guide := n.Guidance(); guide != nil {
-		context := ""
-		if ctxmgr := n.stateMgr.ContextManager(); ctxmgr != nil {
-			data, err := ctxmgr.GetContext(n.ID())
-			if err == nil && data != nil {
-				context = data.String()
-			}
-		}
-		sig, err = guide.Generate(sig, context)
-		if err != nil {
-			n.LogErr(err) // Log error in guidance generation
-		}
-	}
+	sig = n.applyGuidance(ctx, sig)
 
-	n.LogInfo(fmt.Sprintf("Sending to llm %s", n.lm.Model())) // Log the LLM model being used
+	n.LogSignal(node.LevelDebug, sig, "Sending to llm", "model", n.lm.Model()) // Log the LLM model being used
 
-	// Call the LLM to process the signal
-	sig, err = n.CallLLM(ctx, sig)
+	// Call the LLM to process the signal, retrying or ignoring errors per the ErrorGuidance
+	sig, err = n.RunWithErrorGuidance(ctx, sig, func(s node.Signal) (node.Signal, error) {
+		return n.CallLLM(ctx, s)
+	})
 	if err != nil {
 		n.Fail(sig, err) // Mark the signal as failed
 		return
@@ -89,7 +117,7 @@ func (n *AINode) processSignal(sig node.Signal) {
 	sig.Status = StatusSuccess // Mark the signal as successful after LLM processing
 
 	// Postprocess the signal after successful LLM interaction
-	sig, err = n.PostProcessSignal(sig)
+	sig, err = n.PostProcessSignal(ctx, sig)
 	if err != nil {
 		n.Fail(sig, err) // Mark the signal as failed if postprocessing fails
 		return
@@ -102,24 +130,37 @@ func (n *AINode) processSignal(sig node.Signal) {
 	}
 
 	// Log the total time taken for processing the signal
-	n.LogInfo(fmt.Sprintf("%v completed in %v", n.lm.Model(), time.Since(start)))
+	n.LogSignal(node.LevelInfo, sig, "LLM completed", "model", n.lm.Model(), "duration", time.Since(start))
 }
 
 // CallLLM sends the signal data to the LLM for processing and returns the modified signal.
-// It creates a message list from the signal's task data and sends it to the LLM via its Chat method.
-// If successful, the response is stored in the signal's Result field.
+// It creates a message list from the system prompt and the signal's task data and sends it to
+// the LLM via its Chat method, or streams the response when a StreamFunc is set.
+// If successful, the response is stored in the signal's Result field and its usage, finish
+// reason, model and latency in the signal's metadata, see SetLLMResponse.
 func (n *AINode) CallLLM(ctx context.Context, sig node.Signal) (node.Signal, error) {
-	// Create a message list with the signal's task data as the user message
-	msgList := llm.MessageList{llm.UserMsg(sig.Task.String())}
+	if sig.Task == nil {
+		return sig, ErrNoTask
+	}
+
+	// Create a message list with the system prompt and the signal's task data as the user message
+	msgList := n.Messages(sig)
 
 	// Call the LLM to process the message list and return a response
-	msg, err := n.lm.Chat(ctx, msgList)
+	var resp llm.Response
+	var err error
+	if n.stream != nil {
+		resp, err = ChatStream(ctx, n.lm, msgList, func(delta string) { n.stream(sig, delta) })
+	} else {
+		resp, err = Chat(ctx, n.lm, msgList)
+	}
 	if err != nil {
 		return sig, err // Return the signal and error if the LLM call fails
 	}
 
 	// Set the LLM's response as the result in the signal
-	sig.Result = &Carrier{TextData: msg.Content}
+	sig.Result = &Carrier{TextData: resp.Content}
+	sig = SetLLMResponse(sig, resp)
 
 	return sig, nil // Return the signal with the LLM's response
 }
@@ -0,0 +1,28 @@
+package nlib_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAINode_SystemPrompt(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	lm := newReplyLLM("Bonjour", nil)
+	ai := nlib.NewAINode(lm, mgr, node.Options{ID: "ai"})
+	ai.SetSystemPrompt("Answer in French.")
+
+	sig, err := nlib.Run(context.Background(), ai, node.Signal{Task: nlib.NewTextCarrier("Hello")})
+	assert.NoError(t, err)
+	assert.Equal(t, "Bonjour", sig.Result.String())
+	lm.AssertCalled(t, "Chat", mock.Anything, llm.MessageList{llm.SystemMsg("Answer in French."), llm.UserMsg("Hello")})
+
+	// Without a system prompt only the task is sent
+	ai.SetSystemPrompt("")
+	assert.Equal(t, llm.MessageList{llm.UserMsg("Hello")}, ai.Messages(sig))
+}
@@ -1,9 +1,6 @@
 package nlib
 
 import (
-	"context"
-	"fmt"
-
 	"github.com/dshills/wiggle/node"
 )
 
@@ -19,26 +16,13 @@ type SimpleBranchNode struct {
 }
 
 // NewSimpleBranchNode creates a new SimpleBranchNode with the given logger, state manager, and name.
-// It initializes the node and starts a goroutine to listen for incoming signals.
+// It initializes the node, which processes incoming signals once it has been started.
 func NewSimpleBranchNode(mgr node.StateManager, options node.Options) *SimpleBranchNode {
 	n := SimpleBranchNode{}
 	n.SetOptions(options)
 	n.SetStateManager(mgr)
 	n.MakeInputCh()
-
-	// Goroutine to listen for incoming signals and process them.
-	go func() {
-		for {
-			select {
-			case sig := <-n.InputCh():
-				n.LogInfo("Received Signal")
-				n.ProcessSignal(sig)
-			case <-n.StateManager().Register():
-				n.LogInfo("Received Done")
-				return
-			}
-		}
-	}()
+	n.SetProcessFunc(n.ProcessSignal)
 
 	return &n
 }
@@ -48,33 +32,33 @@ func NewSimpleBranchNode(mgr node.StateManager, options node.Options) *SimpleBra
 // If no conditions are met, the signal is sent to the connected nodes.
 func (n *SimpleBranchNode) ProcessSignal(sig node.Signal) {
 	var err error
-	sig, err = n.PreProcessSignal(sig) // Run pre-processing hooks on the signal.
+	ctx, done := n.BeginSignal(sig)
+	defer done()
+
+	sig, err = n.PreProcessSignal(ctx, sig) // Run pre-processing hooks on the signal.
 	if err != nil {
 		n.Fail(sig, err)
 		return
 	}
 
-	// No specific processing here, but post-processing is handled next.
-	sig, err = n.PostProcessSignal(sig)
+	// No specific processing here, the signal is complete once the hooks have run.
+	sig.Status = StatusSuccess
+	sig, err = n.PostProcessSignal(ctx, sig)
 	if err != nil {
 		n.Fail(sig, err)
 		return
 	}
 
-	sig.Status = StatusInProcess
-
 	// Iterate over the conditions to find a match.
 	for _, cond := range n.conditions {
 		if cond.ConditionFn(sig) {
-			n.LogInfo(fmt.Sprintf("Sending to %s", cond.Target.ID()))
-			newSig := NewSignalFromSignal(cond.Target.ID(), n.ID(), sig)
-			cond.Target.InputCh() <- newSig
+			if err := n.SendToNode(ctx, cond.Target, sig); err != nil {
+				n.Fail(sig, err)
+			}
 			return
 		}
 	}
-	sig.Status = StatusSuccess
-
-	if err := n.SendToConnected(context.TODO(), sig); err != nil {
+	if err := n.SendToConnected(ctx, sig); err != nil {
 		n.Fail(sig, err)
 		return
 	}
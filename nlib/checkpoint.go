@@ -0,0 +1,145 @@
+package nlib
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dshills/wiggle/node"
+)
+
+// Compile-time check to ensure FileCheckpointStore implements the node.CheckpointStore interface
+var _ node.CheckpointStore = (*FileCheckpointStore)(nil)
+
+// FileCheckpointStore keeps the checkpoints of each run in a JSON-lines file named
+// <runID>.jsonl in a directory. Each line holds one signal.
+type FileCheckpointStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileCheckpointStore creates a FileCheckpointStore writing to dir, creating the directory if needed
+func NewFileCheckpointStore(dir string) (*FileCheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("checkpoint store: %w", err)
+	}
+	return &FileCheckpointStore{dir: dir}, nil
+}
+
+// Save appends the signal to the file of its run
+func (s *FileCheckpointStore) Save(sig node.Signal) error {
+	path, err := s.path(sig.RunID)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(sig)
+	if err != nil {
+		return fmt.Errorf("checkpoint store: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("checkpoint store: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("checkpoint store: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("checkpoint store: %w", err)
+	}
+	return f.Close()
+}
+
+// Load reads the checkpoints of a run. A run without a file has no checkpoints.
+// A partially written last line, left by a process that exited while saving, is ignored.
+func (s *FileCheckpointStore) Load(runID string) ([]node.Signal, error) {
+	path, err := s.path(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint store: %w", err)
+	}
+	defer f.Close()
+
+	sigs := []node.Signal{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		sig := node.Signal{}
+		if err := json.Unmarshal(scanner.Bytes(), &sig); err != nil {
+			break
+		}
+		sigs = append(sigs, sig)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("checkpoint store: %w", err)
+	}
+	return sigs, nil
+}
+
+// Runs returns the IDs of the runs with a checkpoint file
+func (s *FileCheckpointStore) Runs() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint store: %w", err)
+	}
+	runs := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && filepath.Ext(e.Name()) == ".jsonl" {
+			runs = append(runs, strings.TrimSuffix(e.Name(), ".jsonl"))
+		}
+	}
+	sort.Strings(runs)
+	return runs, nil
+}
+
+// Remove deletes the file of a run
+func (s *FileCheckpointStore) Remove(runID string) error {
+	path, err := s.path(runID)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("checkpoint store: %w", err)
+	}
+	return nil
+}
+
+// path returns the file of a run, rejecting run IDs that are not plain file names
+func (s *FileCheckpointStore) path(runID string) (string, error) {
+	if runID == "" || runID != filepath.Base(runID) || strings.ContainsAny(runID, `/\`) || runID == "." || runID == ".." {
+		return "", fmt.Errorf("checkpoint store: invalid run ID %q", runID)
+	}
+	return filepath.Join(s.dir, runID+".jsonl"), nil
+}
+
+// checkpointStore returns the CheckpointStore of the StateManager, nil if it does not checkpoint runs
+func checkpointStore(sm node.StateManager) node.CheckpointStore {
+	if cm, ok := sm.(node.CheckpointStateManager); ok {
+		return cm.CheckpointStore()
+	}
+	return nil
+}
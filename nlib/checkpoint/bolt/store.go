@@ -0,0 +1,128 @@
+// Package bolt provides a node.CheckpointStore kept in an embedded bbolt database.
+// It is a separate package so programs that do not use it do not depend on bbolt.
+package bolt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dshills/wiggle/node"
+	"go.etcd.io/bbolt"
+)
+
+// Compile-time check to ensure CheckpointStore implements the node.CheckpointStore interface
+var _ node.CheckpointStore = (*CheckpointStore)(nil)
+
+// checkpointBucket holds a nested bucket for each run, keyed by save order
+var checkpointBucket = []byte("checkpoints")
+
+// CheckpointStore keeps checkpoints in an embedded bbolt key-value database file.
+// It is safe for concurrent use but the file can only be opened by one process at a time.
+type CheckpointStore struct {
+	db *bbolt.DB
+}
+
+// NewCheckpointStore opens or creates the database at path. Close it when done.
+func NewCheckpointStore(path string) (*CheckpointStore, error) {
+	db, err := bbolt.Open(path, 0o644, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint store: %w", err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(checkpointBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("checkpoint store: %w", err)
+	}
+	return &CheckpointStore{db: db}, nil
+}
+
+// Close closes the database
+func (s *CheckpointStore) Close() error {
+	return s.db.Close()
+}
+
+// Save stores the signal after the checkpoints already saved for its run
+func (s *CheckpointStore) Save(sig node.Signal) error {
+	if sig.RunID == "" {
+		return fmt.Errorf("checkpoint store: signal has no run ID")
+	}
+	value, err := json.Marshal(sig)
+	if err != nil {
+		return fmt.Errorf("checkpoint store: %w", err)
+	}
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		run, err := tx.Bucket(checkpointBucket).CreateBucketIfNotExists([]byte(sig.RunID))
+		if err != nil {
+			return err
+		}
+		seq, err := run.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		return run.Put(key, value)
+	})
+	if err != nil {
+		return fmt.Errorf("checkpoint store: %w", err)
+	}
+	return nil
+}
+
+// Load returns the checkpoints of a run in the order they were saved
+func (s *CheckpointStore) Load(runID string) ([]node.Signal, error) {
+	sigs := []node.Signal{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		run := tx.Bucket(checkpointBucket).Bucket([]byte(runID))
+		if run == nil {
+			return nil
+		}
+		return run.ForEach(func(_, value []byte) error {
+			sig := node.Signal{}
+			if err := json.Unmarshal(value, &sig); err != nil {
+				return err
+			}
+			sigs = append(sigs, sig)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint store: %w", err)
+	}
+	return sigs, nil
+}
+
+// Runs returns the IDs of the runs with checkpoints
+func (s *CheckpointStore) Runs() ([]string, error) {
+	runs := []string{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(checkpointBucket).ForEach(func(key, _ []byte) error {
+			runs = append(runs, string(key))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint store: %w", err)
+	}
+	return runs, nil
+}
+
+// Remove drops the checkpoints of a run
+func (s *CheckpointStore) Remove(runID string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		err := tx.Bucket(checkpointBucket).DeleteBucket([]byte(runID))
+		if err == bbolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("checkpoint store: %w", err)
+	}
+	return nil
+}
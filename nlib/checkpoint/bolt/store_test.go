@@ -0,0 +1,45 @@
+package bolt_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/nlib/checkpoint/bolt"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpointStore(t *testing.T) {
+	store, err := bolt.NewCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	for i, id := range []string{"a", "b", "c"} {
+		sig := node.Signal{RunID: "run-1", NodeID: id, Status: nlib.StatusSuccess, Result: nlib.NewTextCarrier(fmt.Sprint(i))}
+		assert.NoError(t, store.Save(sig))
+	}
+	assert.NoError(t, store.Save(node.Signal{RunID: "run-2", NodeID: "a"}))
+
+	sigs, err := store.Load("run-1")
+	assert.NoError(t, err)
+	if assert.Len(t, sigs, 3) {
+		assert.Equal(t, "c", sigs[2].NodeID)
+		assert.Equal(t, "2", sigs[2].Result.String())
+	}
+	runs, err := store.Runs()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"run-1", "run-2"}, runs)
+
+	assert.NoError(t, store.Remove("run-1"))
+	assert.NoError(t, store.Remove("missing"))
+	sigs, err = store.Load("run-1")
+	assert.NoError(t, err)
+	assert.Empty(t, sigs)
+	runs, err = store.Runs()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"run-2"}, runs)
+
+	assert.ErrorContains(t, store.Save(node.Signal{}), "signal has no run ID")
+}
@@ -0,0 +1,182 @@
+package nlib_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileCheckpointStore(t *testing.T) {
+	store, err := nlib.NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints"))
+	assert.NoError(t, err)
+
+	for i, id := range []string{"a", "b", "c"} {
+		sig := node.Signal{RunID: "run-1", NodeID: id, Status: nlib.StatusSuccess, Result: nlib.NewTextCarrier(fmt.Sprint(i))}
+		assert.NoError(t, store.Save(sig))
+	}
+	assert.NoError(t, store.Save(node.Signal{RunID: "run-2", NodeID: "a"}))
+
+	sigs, err := store.Load("run-1")
+	assert.NoError(t, err)
+	if assert.Len(t, sigs, 3) {
+		assert.Equal(t, "c", sigs[2].NodeID)
+		assert.Equal(t, "2", sigs[2].Result.String())
+	}
+	runs, err := store.Runs()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"run-1", "run-2"}, runs)
+
+	assert.NoError(t, store.Remove("run-1"))
+	assert.NoError(t, store.Remove("missing"))
+	sigs, err = store.Load("run-1")
+	assert.NoError(t, err)
+	assert.Empty(t, sigs)
+	runs, err = store.Runs()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"run-2"}, runs)
+
+	assert.ErrorContains(t, store.Save(node.Signal{RunID: "../escape"}), "invalid run ID")
+}
+
+// newCountingNode returns a node that applies fn to its task, counting its calls.
+// It fails while fail is set.
+func newCountingNode(mgr node.StateManager, id string, fn func(string) string, calls *atomic.Int32, fail *atomic.Bool) node.Node {
+	after := func(sig node.Signal) (node.Signal, error) {
+		calls.Add(1)
+		if fail != nil && fail.Load() {
+			return sig, fmt.Errorf("%s crashed", id)
+		}
+		sig.Result = nlib.NewTextCarrier(fn(sig.Task.String()))
+		return sig, nil
+	}
+	return nlib.NewSimpleBranchNode(mgr, node.Options{ID: id, Hooks: nlib.NewSimpleNodeHooks(nil, after)})
+}
+
+func TestResume_Chain(t *testing.T) {
+	store, err := nlib.NewFileCheckpointStore(t.TempDir())
+	assert.NoError(t, err)
+	var upperCalls, exclaimCalls atomic.Int32
+	crash := &atomic.Bool{}
+	crash.Store(true)
+
+	// Each graph stands for a process using the same checkpoint store
+	newChain := func() node.Node {
+		mgr := nlib.NewSimpleStateManager(nil)
+		mgr.SetCheckpointStore(store)
+		upper := newCountingNode(mgr, "upper", strings.ToUpper, &upperCalls, nil)
+		exclaim := newCountingNode(mgr, "exclaim", func(s string) string { return s + "!" }, &exclaimCalls, crash)
+		out := newTransformNode(mgr, "out", strings.TrimSpace)
+		upper.Connect(exclaim)
+		exclaim.Connect(out)
+		return upper
+	}
+
+	sig, err := nlib.Run(context.Background(), newChain(), node.Signal{Task: nlib.NewTextCarrier("hello")})
+	assert.ErrorContains(t, err, "exclaim crashed")
+	runID := sig.RunID
+	runs, _ := store.Runs()
+	assert.Equal(t, []string{runID}, runs)
+
+	crash.Store(false)
+	sig, err = nlib.Resume(context.Background(), newChain(), runID)
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO!", sig.Result.String())
+	assert.Equal(t, runID, sig.RunID)
+	assert.Equal(t, int32(1), upperCalls.Load())
+	assert.Equal(t, int32(2), exclaimCalls.Load())
+
+	// A successful run drops its checkpoints
+	runs, _ = store.Runs()
+	assert.Empty(t, runs)
+	_, err = nlib.Resume(context.Background(), newChain(), runID)
+	assert.ErrorContains(t, err, "no checkpoints")
+}
+
+func TestResume_Join(t *testing.T) {
+	store, err := nlib.NewFileCheckpointStore(t.TempDir())
+	assert.NoError(t, err)
+	mgr := nlib.NewSimpleStateManager(nil)
+	mgr.SetCheckpointStore(store)
+	src, join := newDiamond(mgr)
+	join.SetUpstream("upper", "lower")
+
+	// The run was interrupted after upper completed but before lower did
+	assert.NoError(t, store.Save(node.Signal{RunID: "r1", NodeID: "src", Status: nlib.StatusSuccess, Task: nlib.NewTextCarrier(" Hi "), Result: nlib.NewTextCarrier("Hi")}))
+	assert.NoError(t, store.Save(node.Signal{RunID: "r1", NodeID: "upper", FromNodeID: "src", Status: nlib.StatusSuccess, Task: nlib.NewTextCarrier("Hi"), Result: nlib.NewTextCarrier("HI")}))
+
+	sig, err := nlib.Resume(context.Background(), src, "r1")
+	assert.NoError(t, err)
+	assert.Equal(t, "join", sig.NodeID)
+	assert.Equal(t, "HI\nhi", sig.Result.String())
+}
+
+func TestResume_PartitionerReusesPartitions(t *testing.T) {
+	store, err := nlib.NewFileCheckpointStore(t.TempDir())
+	assert.NoError(t, err)
+	mgr := nlib.NewSimpleStateManager(nil)
+	mgr.SetCheckpointStore(store)
+
+	var workerCalls atomic.Int32
+	factory := func(count int) []node.Node {
+		nodes := []node.Node{}
+		for i := 0; i < count; i++ {
+			nodes = append(nodes, newCountingNode(mgr, fmt.Sprintf("worker-%d", i), strings.ToUpper, &workerCalls, nil))
+		}
+		return nodes
+	}
+	split := func(s string) ([]string, error) { return strings.Split(s, ","), nil }
+	join := func(parts []string) (string, error) { return strings.Join(parts, "|"), nil }
+	src := newTransformNode(mgr, "src", strings.TrimSpace)
+	part := nlib.NewSimplePartitionerNode(split, join, factory, mgr, node.Options{ID: "part"})
+	src.Connect(part)
+
+	// The first and last of the three partitions completed before the run was interrupted
+	save := func(sig node.Signal) {
+		sig.RunID, sig.Status = "r1", nlib.StatusSuccess
+		assert.NoError(t, store.Save(sig))
+	}
+	save(node.Signal{NodeID: "src", Task: nlib.NewTextCarrier("a,b,c"), Result: nlib.NewTextCarrier("a,b,c")})
+	save(node.Signal{NodeID: "old-worker-0", FromNodeID: "part", Task: nlib.NewTextCarrier("a"), Result: nlib.NewTextCarrier("A")})
+	save(node.Signal{NodeID: "old-worker-2", FromNodeID: "part", Task: nlib.NewTextCarrier("c"), Result: nlib.NewTextCarrier("C")})
+
+	sig, err := nlib.Resume(context.Background(), src, "r1")
+	assert.NoError(t, err)
+	assert.Equal(t, "part", sig.NodeID)
+	assert.Equal(t, "A|B|C", sig.Result.String(), "the results are in partition order")
+	assert.Equal(t, int32(1), workerCalls.Load())
+}
+
+func TestCheckpoint_BranchAndLoopNodes(t *testing.T) {
+	store, err := nlib.NewFileCheckpointStore(t.TempDir())
+	assert.NoError(t, err)
+	mgr := nlib.NewSimpleStateManager(nil)
+	mgr.SetCheckpointStore(store)
+
+	// branch -(cond)-> loop -> broken, the loop ends at once
+	var calls atomic.Int32
+	crash := &atomic.Bool{}
+	crash.Store(true)
+	branch := newTransformNode(mgr, "branch", strings.ToUpper).(*nlib.SimpleBranchNode)
+	loop := nlib.NewSimpleLoopNode(branch, func(node.Signal) bool { return true }, mgr, node.Options{ID: "loop"})
+	branch.AddConditional(node.BranchCondition{Target: loop, ConditionFn: func(node.Signal) bool { return true }})
+	loop.Connect(newCountingNode(mgr, "broken", strings.TrimSpace, &calls, crash))
+
+	// The failure after the branch and the loop leaves their checkpoints in place
+	sig, err := nlib.Run(context.Background(), branch, node.Signal{Task: nlib.NewTextCarrier("hi")})
+	assert.ErrorContains(t, err, "broken crashed")
+
+	saved, err := store.Load(sig.RunID)
+	assert.NoError(t, err)
+	ids := []string{}
+	for _, s := range saved {
+		ids = append(ids, s.NodeID)
+	}
+	assert.Equal(t, []string{"branch", "loop"}, ids)
+}
@@ -2,6 +2,7 @@ package nlib
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/dshills/wiggle/node"
@@ -47,3 +48,21 @@ func (c *SimpleContextManager) GetContext(id string) (node.DataCarrier, error) {
 	}
 	return data, nil // Return the found context data
 }
+
+// RunContextKey returns the key used to store context for a single run.
+// Context set with this key is only visible to signals of that run.
+func RunContextKey(runID, key string) string {
+	return runID + "/" + key
+}
+
+// RemoveRun removes all context stored for the run with the provided ID.
+func (c *SimpleContextManager) RemoveRun(runID string) {
+	c.m.Lock() // Acquire a write lock to safely modify the context map
+	defer c.m.Unlock()
+	prefix := RunContextKey(runID, "")
+	for key := range c.context {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.context, key)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package nlib
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dshills/wiggle/node"
+)
+
+// Compile-time check to ensure SimpleCoordinator implements the node.Coordinator interface
+var _ node.Coordinator = (*SimpleCoordinator)(nil)
+
+// SimpleCoordinator is a basic implementation of the node.Coordinator interface.
+// It tracks the number of in-flight signals per node, allows callers to block until
+// a set of nodes has drained, and cancels the in-flight operations of a run when its
+// deadline expires. Each run, identified by the RunID of its signals, has its own
+// context and deadline so runs through the same graph do not affect each other.
+type SimpleCoordinator struct {
+	mu        sync.Mutex
+	inFlight  map[string]int             // NodeID to number of signals being processed
+	completed map[string]int             // NodeID to number of signals processed
+	runs      map[string]*coordinatedRun // Runs by RunID
+	timeout   time.Duration              // Deadline of each run set by CancelOnTimeout
+	changed   chan struct{}              // Closed and replaced whenever the counts change
+}
+
+// coordinatedRun holds the context handed to the nodes processing the signals of a run
+type coordinatedRun struct {
+	ctx      context.Context
+	cancel   context.CancelCauseFunc
+	timer    *time.Timer    // Timer set by CancelOnTimeout
+	inFlight map[string]int // NodeID to number of signals of the run being processed
+	stopped  []string       // IDs of the nodes processing signals of the run when it was cancelled
+	stopWait func() bool    // Stops watching the run's context
+}
+
+// NewSimpleCoordinator creates and returns a new instance of SimpleCoordinator.
+func NewSimpleCoordinator() *SimpleCoordinator {
+	return &SimpleCoordinator{
+		inFlight:  make(map[string]int),
+		completed: make(map[string]int),
+		runs:      make(map[string]*coordinatedRun),
+		changed:   make(chan struct{}),
+	}
+}
+
+// Begin marks the signal as in-flight at sig.NodeID and returns the context of the signal's run.
+func (c *SimpleCoordinator) Begin(sig node.Signal) context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	run := c.run(sig)
+	c.inFlight[sig.NodeID]++
+	run.inFlight[sig.NodeID]++
+	c.notify()
+	return run.ctx
+}
+
+// End marks the signal as processed at sig.NodeID.
+func (c *SimpleCoordinator) End(sig node.Signal) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inFlight[sig.NodeID] > 0 {
+		c.inFlight[sig.NodeID]--
+	}
+	if run, ok := c.runs[sig.RunID]; ok && run.inFlight[sig.NodeID] > 0 {
+		run.inFlight[sig.NodeID]--
+	}
+	c.completed[sig.NodeID]++
+	c.notify()
+}
+
+// InFlight returns the number of signals currently being processed by the node with the given ID.
+func (c *SimpleCoordinator) InFlight(nodeID string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inFlight[nodeID]
+}
+
+// WaitForCompletion blocks until each of the nodes has processed at least one signal
+// and has no signals in flight. If no nodes are given it waits until every tracked node
+// has drained. An error is returned if a run is cancelled while it has signals in flight
+// at the nodes, until Reset is called or the run finishes.
+func (c *SimpleCoordinator) WaitForCompletion(nodes ...node.Node) error {
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.ID()
+	}
+
+	for {
+		c.mu.Lock()
+		done := c.drained(ids)
+		err := c.cancelled(ids)
+		changed := c.changed
+		c.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("WaitForCompletion: %w", err)
+		}
+		if done {
+			return nil
+		}
+		<-changed
+	}
+}
+
+// CancelOnTimeout cancels the in-flight operations of each run once the duration has
+// elapsed since its first signal began. Runs already in progress get the duration from
+// now. Calling it again replaces the previous deadline, Reset removes it.
+func (c *SimpleCoordinator) CancelOnTimeout(duration time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timeout = duration
+	for _, run := range c.runs {
+		c.startTimer(run)
+	}
+}
+
+// Cancel immediately cancels the in-flight operations of every run.
+func (c *SimpleCoordinator) Cancel() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, run := range c.runs {
+		c.cancelRun(run, context.Canceled)
+	}
+	c.notify()
+}
+
+// CancelRun immediately cancels the in-flight operations of the run.
+func (c *SimpleCoordinator) CancelRun(runID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if run, ok := c.runs[runID]; ok {
+		c.cancelRun(run, context.Canceled)
+		c.notify()
+	}
+}
+
+// Reset clears the tracked counts and runs and removes the deadline set by
+// CancelOnTimeout. Signals beginning afterwards get a new context for their run, so a
+// run that was cancelled or timed out does not affect them.
+func (c *SimpleCoordinator) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, run := range c.runs {
+		run.release()
+	}
+	c.runs = make(map[string]*coordinatedRun)
+	c.timeout = 0
+	c.inFlight = make(map[string]int)
+	c.completed = make(map[string]int)
+	c.notify()
+}
+
+// run returns the run the signal belongs to, creating it if needed. The run is dropped
+// once the signal's context is done, which Run and StartRun do when the run finishes.
+// The caller must hold the lock.
+func (c *SimpleCoordinator) run(sig node.Signal) *coordinatedRun {
+	if run, ok := c.runs[sig.RunID]; ok {
+		return run
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	run := &coordinatedRun{ctx: ctx, cancel: cancel, inFlight: make(map[string]int)}
+	c.runs[sig.RunID] = run
+	c.startTimer(run)
+	runID := sig.RunID
+	run.stopWait = context.AfterFunc(sig.Context(), func() { c.removeRun(runID, run) })
+	return run
+}
+
+// removeRun drops a run that has finished
+func (c *SimpleCoordinator) removeRun(runID string, run *coordinatedRun) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.runs[runID] == run {
+		delete(c.runs, runID)
+		run.release()
+	}
+}
+
+// startTimer (re)starts the deadline of the run if one is set. The caller must hold the lock.
+func (c *SimpleCoordinator) startTimer(run *coordinatedRun) {
+	if run.timer != nil {
+		run.timer.Stop()
+		run.timer = nil
+	}
+	if c.timeout <= 0 {
+		return
+	}
+	duration := c.timeout
+	run.timer = time.AfterFunc(duration, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.cancelRun(run, fmt.Errorf("coordinator timeout after %v: %w", duration, context.DeadlineExceeded))
+		c.notify()
+	})
+}
+
+// cancelRun cancels the context of the run, recording the nodes it was in flight at.
+// The caller must hold the lock.
+func (c *SimpleCoordinator) cancelRun(run *coordinatedRun, cause error) {
+	if run.ctx.Err() != nil {
+		return
+	}
+	for id, count := range run.inFlight {
+		if count > 0 {
+			run.stopped = append(run.stopped, id)
+		}
+	}
+	run.cancel(cause)
+}
+
+// release stops the timer of the run and stops watching its context
+func (run *coordinatedRun) release() {
+	if run.timer != nil {
+		run.timer.Stop()
+	}
+	if run.stopWait != nil {
+		run.stopWait()
+	}
+}
+
+// cancelled returns the cause of the cancellation of a run that had signals in flight at
+// the nodes, or at any node if no IDs are given. The caller must hold the lock.
+func (c *SimpleCoordinator) cancelled(ids []string) error {
+	for _, run := range c.runs {
+		for _, id := range run.stopped {
+			if len(ids) == 0 || slices.Contains(ids, id) {
+				return context.Cause(run.ctx)
+			}
+		}
+	}
+	return nil
+}
+
+// drained reports whether the nodes have completed work and have nothing in flight.
+// The caller must hold the lock.
+func (c *SimpleCoordinator) drained(ids []string) bool {
+	if len(ids) == 0 {
+		if len(c.completed) == 0 {
+			return false
+		}
+		for _, count := range c.inFlight {
+			if count > 0 {
+				return false
+			}
+		}
+		return true
+	}
+	for _, id := range ids {
+		if c.completed[id] == 0 || c.inFlight[id] > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// notify wakes any goroutines blocked in WaitForCompletion. The caller must hold the lock.
+func (c *SimpleCoordinator) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
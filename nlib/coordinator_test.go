@@ -0,0 +1,138 @@
+package nlib_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/nmock"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSimpleCoordinator_WaitForCompletion(t *testing.T) {
+	coord := nlib.NewSimpleCoordinator()
+	n := newCollectorNode("node1")
+	sig := node.Signal{NodeID: n.ID()}
+
+	coord.Begin(sig)
+	assert.Equal(t, 1, coord.InFlight(n.ID()))
+
+	errCh := make(chan error)
+	go func() { errCh <- coord.WaitForCompletion(n) }()
+
+	select {
+	case <-errCh:
+		t.Fatal("WaitForCompletion returned while signal in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	coord.End(sig)
+	select {
+	case err := <-errCh:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("WaitForCompletion did not return after node drained")
+	}
+	assert.Equal(t, 0, coord.InFlight(n.ID()))
+}
+
+func TestSimpleCoordinator_CancelOnTimeout(t *testing.T) {
+	coord := nlib.NewSimpleCoordinator()
+	n := newCollectorNode("node1")
+	ctx := coord.Begin(node.Signal{NodeID: n.ID()})
+
+	coord.CancelOnTimeout(10 * time.Millisecond)
+	err := coord.WaitForCompletion(n)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	select {
+	case <-ctx.Done():
+	default:
+		t.Fatal("in-flight context was not cancelled")
+	}
+}
+
+func TestSimpleCoordinator_CancelsStalledLLM(t *testing.T) {
+	lm := new(nmock.MockLLM)
+	lm.On("Model").Return("mock")
+	lm.On("Chat", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() }).
+		Return(llm.Message{}, context.Canceled)
+
+	coord := nlib.NewSimpleCoordinator()
+	mgr := nlib.NewSimpleStateManager(nil)
+	mgr.SetCoordinator(coord)
+	aiNode := nlib.NewAINode(lm, mgr, node.Options{ID: "ai"})
+	startGraph(t, aiNode)
+
+	coord.CancelOnTimeout(20 * time.Millisecond)
+	aiNode.InputCh() <- node.Signal{NodeID: aiNode.ID(), Task: nlib.NewTextCarrier("stall")}
+
+	err := coord.WaitForCompletion(aiNode)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The stalled call is cancelled and the node drains
+	assert.Eventually(t, func() bool { return coord.InFlight(aiNode.ID()) == 0 }, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestSimpleCoordinator_ResetAfterTimeout(t *testing.T) {
+	coord := nlib.NewSimpleCoordinator()
+	n := newCollectorNode("node1")
+	sig := node.Signal{NodeID: n.ID()}
+
+	old := coord.Begin(sig)
+	coord.CancelOnTimeout(10 * time.Millisecond)
+	assert.ErrorIs(t, coord.WaitForCompletion(n), context.DeadlineExceeded)
+
+	// The next run gets a fresh context and is not cancelled by the expired timeout
+	coord.Reset()
+	ctx := coord.Begin(sig)
+	assert.NoError(t, ctx.Err())
+	assert.Error(t, old.Err())
+	coord.End(sig)
+	assert.NoError(t, coord.WaitForCompletion(n))
+
+	// A deadline set for a run does not outlive it
+	coord.CancelOnTimeout(10 * time.Millisecond)
+	coord.Reset()
+	ctx = coord.Begin(sig)
+	time.Sleep(30 * time.Millisecond)
+	assert.NoError(t, ctx.Err())
+	coord.End(sig)
+	assert.NoError(t, coord.WaitForCompletion(n))
+}
+
+func TestSimpleCoordinator_RunsAreIndependent(t *testing.T) {
+	coord := nlib.NewSimpleCoordinator()
+	n := newCollectorNode("node1")
+	coord.CancelOnTimeout(50 * time.Millisecond)
+
+	// Each run's deadline starts with its first signal
+	first := coord.Begin(node.Signal{NodeID: n.ID(), RunID: "run-1"})
+	time.Sleep(30 * time.Millisecond)
+	second := coord.Begin(node.Signal{NodeID: n.ID(), RunID: "run-2"})
+	<-first.Done()
+	assert.ErrorIs(t, context.Cause(first), context.DeadlineExceeded)
+	assert.NoError(t, second.Err())
+
+	// Cancelling a run leaves the others running
+	third := coord.Begin(node.Signal{NodeID: n.ID(), RunID: "run-3"})
+	coord.CancelRun("run-2")
+	assert.Error(t, second.Err())
+	assert.NoError(t, third.Err())
+
+	// A run is released once the context of its signals is done
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := node.Signal{NodeID: n.ID(), RunID: "run-4"}.WithContext(ctx)
+	fourth := coord.Begin(sig)
+	coord.End(sig)
+	cancel()
+	time.Sleep(20 * time.Millisecond) // Let the coordinator drop the run
+	again := coord.Begin(sig)
+	assert.NotSame(t, fourth, again)
+	coord.End(sig)
+}
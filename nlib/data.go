@@ -1,18 +1,36 @@
 package nlib
 
 import (
+	"encoding/json"
+
 	"github.com/dshills/wiggle/node"
 )
 
 // Ensure that StringData implements the node.DataCarrier interface
 var _ node.DataCarrier = (*Carrier)(nil)
 
+// Carrier is the DataCarrier used throughout nlib. It encodes to and decodes from
+// the same JSON as a serialized node.Signal Task or Result.
 type Carrier struct {
-	TextData   string
-	JSONData   []byte
-	VectorData [][]float32
-	URLData    []string
-	Base64Data []string
+	TextData   string      `json:"text,omitempty"`
+	JSONData   []byte      `json:"json,omitempty"`
+	VectorData [][]float32 `json:"vector,omitempty"`
+	URLData    []string    `json:"image_urls,omitempty"`
+	Base64Data []string    `json:"base64,omitempty"`
+}
+
+func init() {
+	node.DecodeCarrier = DecodeCarrier
+}
+
+// DecodeCarrier decodes a Carrier from JSON. It is used to decode the Task
+// and Result of serialized signals.
+func DecodeCarrier(data []byte) (node.DataCarrier, error) {
+	c := &Carrier{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
 func NewTextCarrier(txt string) *Carrier {
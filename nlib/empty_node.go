@@ -2,7 +2,9 @@ package nlib
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -15,8 +17,25 @@ const (
 	StatusFail      = "fail"
 )
 
-// Compile-time check that EmptyNode implements the node.Node interface
+// ErrNoTask is returned by nodes that need a task when a signal has none
+var ErrNoTask = errors.New("signal has no task")
+
+// MetaErrorIgnored is set on a signal passed through after its error was ignored by the
+// node's ErrorGuidance. It holds the error and is not passed on to the next node.
+const MetaErrorIgnored = "error.ignored"
+
+// ErrInputFull is returned when a signal is sent to a node using the
+// node.BackpressureError policy whose input buffer is full
+var ErrInputFull = errors.New("input buffer full")
+
+// backpressurer is implemented by nodes that choose a node.BackpressurePolicy
+type backpressurer interface {
+	Backpressure() node.BackpressurePolicy
+}
+
+// Compile-time check that EmptyNode implements the node.Node and node.Lifecycle interfaces
 var _ node.Node = (*EmptyNode)(nil)
+var _ node.Lifecycle = (*EmptyNode)(nil)
 
 // EmptyNode is a boilerplate implementation of the node.Node interface
 type EmptyNode struct {
@@ -26,8 +45,20 @@ type EmptyNode struct {
 	hooks    node.Hooks
 	stateMgr node.StateManager
 	errGuide node.ErrorGuidance
+	timeout  time.Duration
 	mu       sync.RWMutex
 	inputCh  chan node.Signal
+	bufSize  int                     // Capacity of the input channel
+	pressure node.BackpressurePolicy // What senders do when the input channel is full
+	workers  int                     // Number of signals processed at once
+	ordered  bool                    // Send output in the order signals were received
+	serial   bool                    // Always process one signal at a time, set by nodes that are not safe to run concurrently
+
+	lifeMu    sync.Mutex         // Guards the lifecycle fields below
+	processFn func(node.Signal)  // Called for each received signal while running
+	runCtx    context.Context    // Context of the running goroutine
+	stop      context.CancelFunc // Cancels runCtx
+	stopped   chan struct{}      // Closed when the goroutine exits
 }
 
 // Connect attaches nodes to the EmptyNode
@@ -37,6 +68,15 @@ func (n *EmptyNode) Connect(nn ...node.Node) {
 	n.nodes = append(n.nodes, nn...)
 }
 
+// Disconnect detaches nodes from the EmptyNode
+func (n *EmptyNode) Disconnect(nn ...node.Node) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.nodes = slices.DeleteFunc(n.nodes, func(c node.Node) bool {
+		return slices.Contains(nn, c)
+	})
+}
+
 // ID returns the ID of the EmptyNode
 func (n *EmptyNode) ID() string {
 	return n.id
@@ -60,6 +100,11 @@ func (n *EmptyNode) SetOptions(options node.Options) {
 	n.hooks = options.Hooks
 	n.guide = options.Guidance
 	n.errGuide = options.ErrorGuidance
+	n.timeout = options.Timeout
+	n.bufSize = options.BufferSize
+	n.pressure = options.Backpressure
+	n.workers = options.Concurrency
+	n.ordered = options.Ordered
 	n.id = options.ID
 	if n.id == "" {
 		var err error
@@ -76,6 +121,236 @@ func (n *EmptyNode) SetStateManager(mgr node.StateManager) {
 	n.stateMgr = mgr
 }
 
+// SetProcessFunc sets the function called for each signal received while the node is running.
+// Nodes built on EmptyNode set this in their constructor.
+func (n *EmptyNode) SetProcessFunc(fn func(node.Signal)) {
+	n.lifeMu.Lock()
+	defer n.lifeMu.Unlock()
+	n.processFn = fn
+}
+
+// Start launches the goroutines that process signals received on the input channel.
+// It returns immediately. Processing continues until ctx is cancelled or Stop is called,
+// after which the node can be started again.
+// With Options.Concurrency greater than one that many signals are processed at once and
+// may be sent on in any order, unless Options.Ordered is set, in which case each signal
+// waits for the signals received before it to finish before sending.
+// Calling Start on a running node, or a node without a process function, does nothing.
+func (n *EmptyNode) Start(ctx context.Context) {
+	n.lifeMu.Lock()
+	defer n.lifeMu.Unlock()
+	if n.processFn == nil || n.stopped != nil {
+		return
+	}
+
+	n.runCtx, n.stop = context.WithCancel(ctx)
+	n.stopped = make(chan struct{})
+	runCtx, stop, process, stopped := n.runCtx, n.stop, n.processFn, n.stopped
+
+	workers := n.workers
+	if workers < 1 || n.serial {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	if n.ordered && workers > 1 {
+		work := make(chan orderedSignal)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.dispatchOrdered(runCtx, work)
+		}()
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				n.processOrdered(runCtx, work, process)
+			}()
+		}
+	} else {
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				n.processInput(runCtx, process)
+			}()
+		}
+	}
+
+	// Once the workers exit, because of Stop or because ctx was cancelled, the node is no
+	// longer running and can be started again
+	go func() {
+		wg.Wait()
+		n.lifeMu.Lock()
+		if n.stopped == stopped {
+			n.runCtx, n.stop, n.stopped = nil, nil, nil
+		}
+		n.lifeMu.Unlock()
+		stop()
+		close(stopped)
+	}()
+}
+
+// processInput processes signals from the input channel until runCtx is done
+func (n *EmptyNode) processInput(runCtx context.Context, process func(node.Signal)) {
+	for {
+		select {
+		case sig := <-n.InputCh():
+			sig, finish := n.receiveSignal(sig)
+			process(sig)
+			finish()
+			ConsumeSignal(sig)
+		case <-runCtx.Done():
+			n.LogDebug("Received Done")
+			return
+		}
+	}
+}
+
+// receiveSignal prepares a signal received on the input channel for processing. It stamps
+// the signal, records it, and when tracing or metrics are on adds the node's span and
+// metrics to its context. The returned function is called once the signal has been processed.
+func (n *EmptyNode) receiveSignal(sig node.Signal) (node.Signal, func()) {
+	sig = StampSignal(StampRunID(sig), n.ID())
+	sig.Started = time.Now()
+	n.LogSignal(node.LevelDebug, sig, "Received Signal")
+
+	metrics, exp := stateMetrics(n.stateMgr), spanExporter(n.stateMgr)
+	if metrics != nil || exp != nil {
+		scope := &signalScope{base: sig.Context(), metrics: metrics}
+		sig = sig.WithContext(context.WithValue(sig.Context(), signalScopeKey{}, scope))
+	}
+	sig, span := n.traceSignal(sig, exp)
+	labels := map[string]string{"node": n.ID()}
+	setGauge(metrics, MetricQueueDepth, labels, float64(len(n.InputCh())))
+	recordSignal(EventEnter, n.ID(), sig)
+
+	return sig, func() {
+		span.End(nil)
+		addCounter(metrics, MetricSignalsProcessed, labels, 1)
+		observeHistogram(metrics, MetricSignalDuration, labels, time.Since(sig.Started).Seconds())
+	}
+}
+
+// signalScopeKey is the context key of the signalScope of a signal being processed
+type signalScopeKey struct{}
+
+// signalScope is added by a node to the context of a signal it processes
+type signalScope struct {
+	base    context.Context // Signal context before the node added to it
+	metrics node.Metrics    // Metrics of the node's StateManager
+}
+
+// unscoped returns the context of a signal without what a node added to it while processing
+// the signal, so the signals a node sends on do not carry the spans of the nodes they passed
+// through
+func unscoped(ctx context.Context) context.Context {
+	if scope, ok := ctx.Value(signalScopeKey{}).(*signalScope); ok {
+		return scope.base
+	}
+	return ctx
+}
+
+// metricsFrom returns the metrics of the node processing the signal the context belongs to
+func metricsFrom(ctx context.Context) node.Metrics {
+	if scope, ok := ctx.Value(signalScopeKey{}).(*signalScope); ok {
+		return scope.metrics
+	}
+	return nil
+}
+
+// orderedSignal is a signal handed to a worker along with its turn to send
+type orderedSignal struct {
+	sig  node.Signal
+	turn *sendTurn
+}
+
+// sendTurn orders the sends of a node processing signals concurrently. Sends for the
+// signal wait until prev is closed, done is closed once the signal has been processed.
+type sendTurn struct {
+	owner *EmptyNode
+	prev  <-chan struct{}
+	done  chan struct{}
+}
+
+// sendTurnKey is the context key of the signal's sendTurn
+type sendTurnKey struct{}
+
+// dispatchOrdered reads the input channel and hands each signal to a worker with its
+// turn, chaining every turn to the one before it
+func (n *EmptyNode) dispatchOrdered(runCtx context.Context, work chan<- orderedSignal) {
+	prev := make(chan struct{})
+	close(prev)
+	for {
+		select {
+		case sig := <-n.InputCh():
+			turn := &sendTurn{owner: n, prev: prev, done: make(chan struct{})}
+			prev = turn.done
+			select {
+			case work <- orderedSignal{sig: sig, turn: turn}:
+			case <-runCtx.Done():
+				ConsumeSignal(sig)
+				n.LogDebug("Received Done")
+				return
+			}
+		case <-runCtx.Done():
+			n.LogDebug("Received Done")
+			return
+		}
+	}
+}
+
+// processOrdered processes the signals handed out by dispatchOrdered until runCtx is done
+func (n *EmptyNode) processOrdered(runCtx context.Context, work <-chan orderedSignal, process func(node.Signal)) {
+	for {
+		select {
+		case w := <-work:
+			sig, finish := n.receiveSignal(w.sig)
+			process(sig.WithContext(context.WithValue(sig.Context(), sendTurnKey{}, w.turn)))
+			close(w.turn.done)
+			finish()
+			ConsumeSignal(w.sig)
+		case <-runCtx.Done():
+			return
+		}
+	}
+}
+
+// awaitTurn blocks an ordered node's send until the signals it received earlier have been processed
+func (n *EmptyNode) awaitTurn(ctx context.Context, sig node.Signal) error {
+	turn, ok := sig.Context().Value(sendTurnKey{}).(*sendTurn)
+	if !ok || turn.owner != n {
+		return nil
+	}
+	select {
+	case <-turn.prev:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop terminates the node's goroutine, aborting any in-flight processing,
+// and blocks until it has exited. The node can be started again afterwards.
+func (n *EmptyNode) Stop() {
+	n.lifeMu.Lock()
+	stop, stopped := n.stop, n.stopped
+	n.runCtx, n.stop, n.stopped = nil, nil, nil
+	n.lifeMu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	stop()
+	<-stopped
+}
+
+// Running reports whether the node's goroutine has been started and has not exited.
+func (n *EmptyNode) Running() bool {
+	n.lifeMu.Lock()
+	defer n.lifeMu.Unlock()
+	return n.stopped != nil
+}
+
 // Helper functions
 
 func (n *EmptyNode) Guidance() node.Guidance {
@@ -92,13 +367,24 @@ func (n *EmptyNode) StateManager() node.StateManager {
 	return n.stateMgr
 }
 
+// MakeInputCh creates the input channel using the BufferSize from the node's Options
 func (n *EmptyNode) MakeInputCh() {
-	n.inputCh = make(chan node.Signal)
+	n.inputCh = make(chan node.Signal, n.bufSize)
+}
+
+// Backpressure returns the policy senders apply when the input channel is full
+func (n *EmptyNode) Backpressure() node.BackpressurePolicy {
+	if n.pressure == "" {
+		return node.BackpressureBlock
+	}
+	return n.pressure
 }
 
 // Return connected nodes
 func (n *EmptyNode) Nodes() []node.Node {
-	return n.nodes
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return append([]node.Node{}, n.nodes...)
 }
 
 // RunBeforeHook executes the before-action hooks for the signal
@@ -119,40 +405,139 @@ func (n *EmptyNode) RunAfterHook(sig node.Signal) (node.Signal, error) {
 
 // LogErr logs an error message using the logger
 func (n *EmptyNode) LogErr(err error) {
-	n.log("error", n.id, err.Error())
+	n.LogAt(node.LevelError, err.Error())
 }
 
 // LogInfo logs an informational message using the logger
 func (n *EmptyNode) LogInfo(msg string) {
-	n.log("info", n.id, msg)
+	n.LogAt(node.LevelInfo, msg)
 }
 
 // LogDebug logs a debug message using the logger
 func (n *EmptyNode) LogDebug(msg string) {
-	n.log("debug", n.id, msg)
+	n.LogAt(node.LevelDebug, msg)
+}
+
+// LogAt logs an entry with the node's ID followed by the fields using the logger
+func (n *EmptyNode) LogAt(level node.Level, msg string, fields ...any) {
+	if n.stateMgr == nil {
+		return
+	}
+	logAt(n.stateMgr, level, msg, append([]any{node.LogKeyNodeID, n.id}, fields...)...)
+}
+
+// LogSignal logs an entry about a signal with the node's ID, the signal's run and ID and
+// the fields using the logger
+func (n *EmptyNode) LogSignal(level node.Level, sig node.Signal, msg string, fields ...any) {
+	n.LogAt(level, msg, append([]any{node.LogKeyRunID, sig.RunID, node.LogKeySignalID, sig.ID}, fields...)...)
+}
+
+// ErrorGuidance returns the ErrorGuidance associated with the EmptyNode
+func (n *EmptyNode) ErrorGuidance() node.ErrorGuidance {
+	return n.errGuide
+}
+
+// RunWithErrorGuidance calls fn with the signal, consulting the node's ErrorGuidance when fn fails.
+// Depending on the guidance the call is retried with backoff, the error is ignored and the
+// original signal is passed through, or the error is returned so the node can fail.
+// A signal passed through carries its task as its Result if it has none, so the next
+// node has a task, and the error in MetaErrorIgnored so the caller can tell it was skipped.
+// Without ErrorGuidance fn is called once and its error returned.
+func (n *EmptyNode) RunWithErrorGuidance(ctx context.Context, sig node.Signal, fn node.HookFn) (node.Signal, error) {
+	for attempt := 0; ; attempt++ {
+		out, err := fn(sig)
+		if err == nil || n.errGuide == nil {
+			return out, err
+		}
+
+		switch n.errGuide.Action(err) {
+		case node.ErrGuideNotAnError:
+			return out, nil
+		case node.ErrGuideIgnore:
+			n.LogSignal(node.LevelWarn, sig, "Ignoring error", node.LogKeyError, err)
+			if sig.Result == nil {
+				sig.Result = sig.Task
+			}
+			sig.Meta = sig.Meta.Set(node.StringMeta(MetaErrorIgnored, err.Error()).WithRule(node.MetaDrop))
+			return sig, nil
+		case node.ErrGuideRetry:
+			if attempt >= n.errGuide.Retries() {
+				return out, fmt.Errorf("failed after %d retries: %w", attempt, err)
+			}
+			delay := errorBackoff(n.errGuide, attempt+1)
+			n.LogSignal(node.LevelWarn, sig, "Retrying after error", "delay", delay, "attempt", attempt+1, node.LogKeyError, err)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return out, err
+			}
+		default:
+			return out, err
+		}
+	}
 }
 
-// log handles the actual logging of messages with a specified severity
-func (n *EmptyNode) log(severity, id, msg string) {
-	n.stateMgr.Log(fmt.Sprintf("{ \"severity\": %q, \"id\": %q, \"msg\": %q }", severity, id, msg))
+// applyGuidance generates the prompt for the LLM with the node's Guidance, if it has one,
+// including the context stored for the node. The signal is returned unchanged if the
+// guidance fails.
+func (n *EmptyNode) applyGuidance(ctx context.Context, sig node.Signal) node.Signal {
+	guide := n.Guidance()
+	if guide == nil {
+		return sig
+	}
+	context := ""
+	if ctxmgr := n.stateMgr.ContextManager(); ctxmgr != nil {
+		// Context for this run takes precedence over context shared by all runs
+		data, err := ctxmgr.GetContext(RunContextKey(sig.RunID, n.ID()))
+		if err != nil || data == nil {
+			data, err = ctxmgr.GetContext(n.ID())
+		}
+		if err == nil && data != nil {
+			context = data.String()
+		}
+	}
+	_, span := StartSpan(ctx, "guidance")
+	guided, err := guide.Generate(sig, context)
+	span.End(err)
+	if err != nil {
+		n.LogSignal(node.LevelWarn, sig, "Guidance failed", node.LogKeyError, err) // Log error in guidance generation
+		return sig
+	}
+	return guided
 }
 
 func (n *EmptyNode) Fail(sig node.Signal, err error) {
-	n.LogErr(err)
+	n.LogSignal(node.LevelError, sig, "Signal failed", node.LogKeyError, err)
 	sig.Err = err.Error()
 	sig.Status = StatusFail
+	sig.Finished = time.Now()
+	failSpan(n.ID(), sig, err)
+	addCounter(metricsFrom(sig.Context()), MetricSignalsFailed, map[string]string{"node": n.ID()}, 1)
+	recordSignal(EventFail, n.ID(), sig)
 	n.StateManager().UpdateState(sig)
-	n.StateManager().Complete()
+	reportFailure(sig)
 }
 
-func (n *EmptyNode) PreProcessSignal(sig node.Signal) (node.Signal, error) {
+// PreProcessSignal waits for the node's rate limit and runs the before-action hooks.
+// ctx is the context returned by BeginSignal, so the wait and any hook retries end when
+// the node times out, is stopped or the run is cancelled.
+func (n *EmptyNode) PreProcessSignal(ctx context.Context, sig node.Signal) (node.Signal, error) {
 	if resMgr := n.stateMgr.ResourceManager(); resMgr != nil {
 		// Rate limiting check with exponential backoff
+		metrics := metricsFrom(sig.Context())
+		labels := map[string]string{"node": n.ID()}
 		for retries := 0; retries < 3; retries++ {
 			if err := resMgr.RateLimit(sig); err == nil {
 				break
 			}
-			time.Sleep(time.Duration(retries*retries) * time.Second) // Exponential backoff
+			start := time.Now()
+			select {
+			case <-time.After(time.Duration(retries*retries) * time.Second): // Exponential backoff
+			case <-ctx.Done():
+				return sig, fmt.Errorf("rate limit wait cancelled: %w", context.Cause(ctx))
+			}
+			addCounter(metrics, MetricRateLimitWaits, labels, 1)
+			observeHistogram(metrics, MetricRateLimitWait, labels, time.Since(start).Seconds())
 		}
 		if err := resMgr.RateLimit(sig); err != nil {
 			return sig, fmt.Errorf("exceeded rate limit, could not recover")
@@ -160,39 +545,168 @@ func (n *EmptyNode) PreProcessSignal(sig node.Signal) (node.Signal, error) {
 	}
 
 	// Run any registered before-action hooks
-	return n.RunBeforeHook(sig)
+	_, span := StartSpan(ctx, "pre-hook")
+	sig, err := n.RunWithErrorGuidance(ctx, sig, n.RunBeforeHook)
+	span.End(err)
+	return sig, err
 }
 
-func (n *EmptyNode) PostProcessSignal(sig node.Signal) (node.Signal, error) {
+// PostProcessSignal runs the after-action hooks and saves the state of the processed
+// signal. ctx is the context returned by BeginSignal.
+func (n *EmptyNode) PostProcessSignal(ctx context.Context, sig node.Signal) (node.Signal, error) {
 	// Run any registered after-action hooks
-	sig, err := n.RunAfterHook(sig)
+	_, span := StartSpan(ctx, "post-hook")
+	sig, err := n.RunWithErrorGuidance(ctx, sig, n.RunAfterHook)
+	span.End(err)
 	if err != nil {
 		return sig, err
 	}
 
 	// Update the state of the signal after processing
+	sig.Finished = time.Now()
 	n.stateMgr.UpdateState(sig)
 
 	return sig, nil
 }
 
-// SendToConnected sends a signal to all connected nodes using the provided context for timeout control
+// BeginSignal returns the context to use while processing the signal along with a function
+// to call when processing has finished. The context is derived from the signal's context and
+// is cancelled when the run is cancelled, the node is stopped, the node's Timeout expires, or the
+// Coordinator, if one is set, times out. The signal is tracked as in-flight with the Coordinator
+// until done is called.
+func (n *EmptyNode) BeginSignal(sig node.Signal) (ctx context.Context, done func()) {
+	ctx, cancel := context.WithCancelCause(context.WithValue(sig.Context(), nodeIDKey{}, n.ID()))
+	done = func() { cancel(nil) }
+
+	n.lifeMu.Lock()
+	runCtx := n.runCtx
+	n.lifeMu.Unlock()
+	if runCtx != nil {
+		stop := context.AfterFunc(runCtx, func() { cancel(context.Cause(runCtx)) })
+		prev := done
+		done = func() { stop(); prev() }
+	}
+
+	if n.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, n.timeout)
+		prev := done
+		done = func() { cancelTimeout(); prev() }
+	}
+
+	if coord := n.stateMgr.Coordinator(); coord != nil {
+		sig.NodeID = n.ID()
+		coordCtx := coord.Begin(sig)
+		stop := context.AfterFunc(coordCtx, func() { cancel(context.Cause(coordCtx)) })
+		prev := done
+		done = func() { stop(); prev(); coord.End(sig) }
+	}
+
+	return ctx, done
+}
+
+// nodeIDKey is the context key of the ID of the node processing a signal
+type nodeIDKey struct{}
+
+// nodeIDFrom returns the ID of the node processing the signal the context was created for by BeginSignal
+func nodeIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(nodeIDKey{}).(string)
+	return id
+}
+
+// SendToConnected sends a signal to all connected nodes using the provided context for timeout control.
+// The connected nodes are sent to concurrently so a slow node does not hold up its siblings.
+// It returns once every send has finished, joining the errors of any that failed.
 func (n *EmptyNode) SendToConnected(ctx context.Context, sig node.Signal) error {
-	n.mu.RLock()
-	defer n.mu.RUnlock()
+	nodes := n.Nodes()
+	if len(nodes) == 1 {
+		return n.SendToNode(ctx, nodes[0], sig)
+	}
 
-	for _, conNode := range n.nodes {
-		n.LogInfo(fmt.Sprintf("Sending to %s", conNode.ID()))
-		newSig := NewSignalFromSignal(conNode.ID(), n.ID(), sig)
+	errs := make([]error, len(nodes))
+	var wg sync.WaitGroup
+	for i, conNode := range nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = n.SendToNode(ctx, conNode, sig)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
 
+// SendToNode sends a signal to a single node using the provided context for timeout control.
+// When the target's input buffer is full the target's backpressure policy decides whether
+// to wait, drop the oldest buffered signal, or return ErrInputFull.
+func (n *EmptyNode) SendToNode(ctx context.Context, target node.Node, sig node.Signal) (err error) {
+	_, span := StartSpan(ctx, "send")
+	span.SetAttr("send.target", target.ID())
+	defer func() { span.End(err) }()
+
+	if err := n.awaitTurn(ctx, sig); err != nil {
+		err = fmt.Errorf("context timeout or cancellation while sending signal to node %s: %v", target.ID(), err)
+		n.LogSignal(node.LevelError, sig, "Send failed", node.LogKeyError, err)
+		return err
+	}
+
+	n.LogSignal(node.LevelDebug, sig, "Sending Signal", "target", target.ID())
+	newSig := NewSignalFromSignal(target.ID(), n.ID(), sig)
+
+	policy := node.BackpressureBlock
+	if bp, ok := target.(backpressurer); ok {
+		policy = bp.Backpressure()
+	}
+	inCh := target.InputCh()
+
+	recordSignal(EventLeave, n.ID(), newSig)
+	DispatchSignal(newSig)
+	for cap(inCh) > 0 && policy != node.BackpressureBlock {
 		select {
-		case <-ctx.Done():
-			fmt.Println("ctx.Done")
-			err := fmt.Errorf("context timeout or cancellation while sending signal to node %s: %v", conNode.ID(), ctx.Err())
-			n.LogErr(err)
+		case inCh <- newSig:
+			setGauge(metricsFrom(ctx), MetricQueueDepth, map[string]string{"node": target.ID()}, float64(len(inCh)))
+			return nil
+		default:
+		}
+
+		switch policy {
+		case node.BackpressureError:
+			ConsumeSignal(newSig)
+			err := fmt.Errorf("sending signal to node %s: %w", target.ID(), ErrInputFull)
+			n.LogSignal(node.LevelError, sig, "Send failed", node.LogKeyError, err)
 			return err
-		case conNode.InputCh() <- newSig:
+		case node.BackpressureDropOldest:
+			select {
+			case old := <-inCh:
+				n.drop(target, old)
+			default:
+			}
+		default:
+			policy = node.BackpressureBlock
 		}
 	}
+
+	select {
+	case <-ctx.Done():
+		ConsumeSignal(newSig)
+		err := fmt.Errorf("context timeout or cancellation while sending signal to node %s: %v", target.ID(), ctx.Err())
+		n.LogSignal(node.LevelError, sig, "Send failed", node.LogKeyError, err)
+		return err
+	case inCh <- newSig:
+	}
+	setGauge(metricsFrom(ctx), MetricQueueDepth, map[string]string{"node": target.ID()}, float64(len(inCh)))
 	return nil
 }
+
+// drop records a signal removed from a full input buffer as failed and ends its tracking
+func (n *EmptyNode) drop(target node.Node, sig node.Signal) {
+	err := fmt.Errorf("signal dropped, input buffer of node %s is full", target.ID())
+	n.LogSignal(node.LevelWarn, sig, "Signal dropped", node.LogKeyError, err)
+	sig.Err = err.Error()
+	sig.Status = StatusFail
+	sig.Finished = time.Now()
+	recordSignal(EventFail, n.ID(), sig)
+	n.StateManager().UpdateState(sig)
+	reportFailure(sig)
+	ConsumeSignal(sig)
+}
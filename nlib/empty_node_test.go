@@ -58,7 +58,7 @@ func TestEmptyNode_PreProcessSignal_Success(t *testing.T) {
 	node.SetStateManager(mockStateMgr)
 
 	signal := createTestSignal("test-node")
-	preProcessedSignal, err := node.PreProcessSignal(signal)
+	preProcessedSignal, err := node.PreProcessSignal(context.Background(), signal)
 
 	assert.NoError(t, err)
 	assert.Equal(t, "in-process", preProcessedSignal.Status)
@@ -75,7 +75,7 @@ func TestEmptyNode_PreProcessSignal_ExceedsRateLimit(t *testing.T) {
 	node.SetStateManager(mockStateMgr)
 
 	signal := createTestSignal("test-node")
-	_, err := node.PreProcessSignal(signal)
+	_, err := node.PreProcessSignal(context.Background(), signal)
 
 	assert.Error(t, err)
 	if err != nil {
@@ -83,6 +83,46 @@ func TestEmptyNode_PreProcessSignal_ExceedsRateLimit(t *testing.T) {
 	}
 }
 
+func TestEmptyNode_PreProcessSignal_RateLimitCancelled(t *testing.T) {
+	mockResMgr := new(nmock.MockResourceManager)
+	mockResMgr.On("RateLimit", mock.Anything).Return(errors.New("rate limit exceeded"))
+
+	mockStateMgr := new(nmock.MockStateManager)
+	mockStateMgr.On("ResourceManager").Return(mockResMgr)
+
+	node := &nlib.EmptyNode{}
+	node.SetStateManager(mockStateMgr)
+
+	// The signal's own context is never cancelled, the wait ends with the processing context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	_, err := node.PreProcessSignal(ctx, createTestSignal("test-node"))
+
+	assert.ErrorContains(t, err, "rate limit wait cancelled: context canceled")
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestEmptyNode_PreProcessSignal_TimeoutEndsHookRetry(t *testing.T) {
+	failing := func(sig node.Signal) (node.Signal, error) { return sig, errors.New("transient") }
+	n := &nlib.EmptyNode{}
+	n.SetStateManager(nlib.NewSimpleStateManager(nil))
+	n.SetOptions(node.Options{
+		Timeout:       20 * time.Millisecond,
+		Hooks:         nlib.NewSimpleNodeHooks(failing, nil),
+		ErrorGuidance: nlib.NewRetryErrorGuidance(3, time.Hour),
+	})
+
+	sig := createTestSignal("test-node")
+	ctx, done := n.BeginSignal(sig)
+	defer done()
+	start := time.Now()
+	_, err := n.PreProcessSignal(ctx, sig)
+
+	assert.EqualError(t, err, "transient")
+	assert.Less(t, time.Since(start), time.Second, "the node timeout ends the retry backoff")
+}
+
 func TestEmptyNode_PostProcessSignal_Success(t *testing.T) {
 	mockStateMgr := new(nmock.MockStateManager)
 	mockStateMgr.On("UpdateState", mock.Anything).Return()
@@ -91,16 +131,18 @@ func TestEmptyNode_PostProcessSignal_Success(t *testing.T) {
 	node.SetStateManager(mockStateMgr)
 
 	signal := createTestSignal("test-node")
-	postProcessedSignal, err := node.PostProcessSignal(signal)
+	postProcessedSignal, err := node.PostProcessSignal(context.Background(), signal)
 
 	assert.NoError(t, err)
 	assert.Equal(t, StatusInProcess, postProcessedSignal.Status)
+	assert.False(t, postProcessedSignal.Finished.IsZero())
+	signal.Finished = postProcessedSignal.Finished
 	mockStateMgr.AssertCalled(t, "UpdateState", signal)
 }
 
 func TestEmptyNode_SendToConnected_Success(t *testing.T) {
 	mockStateMgr := new(nmock.MockStateManager)
-	mockStateMgr.On("Log", mock.Anything).Return()
+	mockStateMgr.On("LogAt", mock.Anything, mock.Anything, mock.Anything).Return()
 
 	childNode := &nlib.EmptyNode{}
 	childNode.MakeInputCh()
@@ -125,7 +167,7 @@ func TestEmptyNode_SendToConnected_Success(t *testing.T) {
 
 func TestEmptyNode_SendToConnected_ContextTimeout(t *testing.T) {
 	mockStateMgr := new(nmock.MockStateManager)
-	mockStateMgr.On("Log", mock.Anything).Return()
+	mockStateMgr.On("LogAt", mock.Anything, mock.Anything, mock.Anything).Return()
 
 	childNode := &nlib.EmptyNode{}
 	childNode.MakeInputCh()
@@ -146,6 +188,147 @@ func TestEmptyNode_SendToConnected_ContextTimeout(t *testing.T) {
 	assert.Contains(t, err.Error(), "context timeout or cancellation")
 }
 
+func TestEmptyNode_SendToConnected_SlowSiblingDoesNotBlock(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	slow := nlib.NewOutputStringNode(nil, mgr, node.Options{ID: "slow"})
+	fast := nlib.NewOutputStringNode(nil, mgr, node.Options{ID: "fast"})
+
+	n := &nlib.EmptyNode{}
+	n.SetStateManager(mgr)
+	n.Connect(slow, fast)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- n.SendToConnected(ctx, createTestSignal("parent-node")) }()
+
+	// Nothing reads from slow, fast still receives the signal
+	select {
+	case sig := <-fast.InputCh():
+		assert.Equal(t, "fast", sig.NodeID)
+	case <-time.After(time.Second):
+		t.Fatal("fast node did not receive the signal")
+	}
+	cancel()
+	err := <-errCh
+	assert.ErrorContains(t, err, "sending signal to node slow")
+}
+
+func TestEmptyNode_SendToNode_BufferSize(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	target := nlib.NewOutputStringNode(nil, mgr, node.Options{ID: "target", BufferSize: 2})
+	n := &nlib.EmptyNode{}
+	n.SetStateManager(mgr)
+
+	// Sends complete without a receiver until the buffer is full
+	assert.NoError(t, n.SendToNode(context.Background(), target, createTestSignal("a")))
+	assert.NoError(t, n.SendToNode(context.Background(), target, createTestSignal("b")))
+	assert.Equal(t, 2, len(target.InputCh()))
+	assert.Equal(t, node.BackpressureBlock, target.Backpressure())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorContains(t, n.SendToNode(ctx, target, createTestSignal("c")), "context timeout or cancellation")
+}
+
+func TestEmptyNode_SendToNode_BackpressureDropOldest(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	mgr.SetHistoryManager(nlib.NewSimpleHistoryManager())
+	target := nlib.NewOutputStringNode(nil, mgr, node.Options{ID: "target", BufferSize: 1, Backpressure: node.BackpressureDropOldest})
+	n := &nlib.EmptyNode{}
+	n.SetStateManager(mgr)
+
+	first := createTestSignal("")
+	first.Result = nlib.NewTextCarrier("first")
+	second := createTestSignal("")
+	second.Result = nlib.NewTextCarrier("second")
+	assert.NoError(t, n.SendToNode(context.Background(), target, first))
+	assert.NoError(t, n.SendToNode(context.Background(), target, second))
+
+	assert.Equal(t, 1, len(target.InputCh()))
+	assert.Equal(t, "second", (<-target.InputCh()).Task.String())
+
+	// The dropped signal is recorded as failed
+	state := mgr.GetState(node.Signal{NodeID: "target"})
+	assert.Equal(t, StatusFail, state.Status)
+	assert.Equal(t, 1, state.Failures)
+	if hx := mgr.FilterHistory("target"); assert.Len(t, hx, 1) {
+		assert.Equal(t, "first", hx[0].Task.String())
+		assert.Contains(t, hx[0].Err, "signal dropped")
+	}
+}
+
+func TestEmptyNode_SendToNode_BackpressureError(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	target := nlib.NewOutputStringNode(nil, mgr, node.Options{ID: "target", BufferSize: 1, Backpressure: node.BackpressureError})
+	n := &nlib.EmptyNode{}
+	n.SetStateManager(mgr)
+
+	assert.NoError(t, n.SendToNode(context.Background(), target, createTestSignal("a")))
+	err := n.SendToNode(context.Background(), target, createTestSignal("b"))
+	assert.ErrorIs(t, err, nlib.ErrInputFull)
+	assert.Equal(t, 1, len(target.InputCh()))
+}
+
+// newSleepNode returns a node that sleeps for the duration in the task before passing it on
+func newSleepNode(mgr node.StateManager, options node.Options) node.Node {
+	after := func(sig node.Signal) (node.Signal, error) {
+		d, err := time.ParseDuration(sig.Task.String())
+		if err != nil {
+			return sig, err
+		}
+		time.Sleep(d)
+		sig.Result = sig.Task
+		return sig, nil
+	}
+	options.Hooks = nlib.NewSimpleNodeHooks(nil, after)
+	return nlib.NewSimpleBranchNode(mgr, options)
+}
+
+// sendSleeps sends a signal for each duration to the node and returns the tasks received by out in order
+func sendSleeps(t *testing.T, n node.Node, out node.Node, durations ...string) []string {
+	t.Helper()
+	for _, d := range durations {
+		n.InputCh() <- node.Signal{Task: nlib.NewTextCarrier(d)}
+	}
+	received := []string{}
+	for range durations {
+		select {
+		case sig := <-out.InputCh():
+			received = append(received, sig.Task.String())
+		case <-time.After(2 * time.Second):
+			t.Fatal("signal not received")
+		}
+	}
+	return received
+}
+
+func TestEmptyNode_Concurrency(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	n := newSleepNode(mgr, node.Options{ID: "sleep", Concurrency: 4})
+	out := newCollectorNode("out")
+	n.Connect(out)
+	startGraph(t, n)
+
+	start := time.Now()
+	received := sendSleeps(t, n, out, "200ms", "200ms", "200ms", "200ms")
+	assert.Len(t, received, 4)
+	assert.Less(t, time.Since(start), 600*time.Millisecond)
+}
+
+func TestEmptyNode_ConcurrencyOrdered(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	n := newSleepNode(mgr, node.Options{ID: "sleep", Concurrency: 4, Ordered: true})
+	out := newCollectorNode("out")
+	n.Connect(out)
+	startGraph(t, n)
+
+	start := time.Now()
+	received := sendSleeps(t, n, out, "300ms", "200ms", "100ms", "0s")
+	assert.Equal(t, []string{"300ms", "200ms", "100ms", "0s"}, received)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
 func TestEmptyNode_RunBeforeHook(t *testing.T) {
 	mockHooks := new(nmock.MockHooks)
 	signal := createTestSignal("test-node")
@@ -177,8 +360,7 @@ func TestEmptyNode_RunAfterHook(t *testing.T) {
 func TestEmptyNode_Fail(t *testing.T) {
 	mockStateMgr := new(nmock.MockStateManager)
 	mockStateMgr.On("UpdateState", mock.Anything).Return()
-	mockStateMgr.On("Complete").Return()
-	mockStateMgr.On("Log", mock.Anything).Return()
+	mockStateMgr.On("LogAt", mock.Anything, mock.Anything, mock.Anything).Return()
 
 	n := &nlib.EmptyNode{}
 	n.MakeInputCh()
@@ -192,6 +374,11 @@ func TestEmptyNode_Fail(t *testing.T) {
 	signal.Status = StatusFail
 
 	assert.Equal(t, StatusFail, signal.Status)
-	mockStateMgr.AssertCalled(t, "UpdateState", signal)
-	mockStateMgr.AssertCalled(t, "Complete")
+	mockStateMgr.AssertCalled(t, "UpdateState", mock.MatchedBy(func(sig node.Signal) bool {
+		finished := !sig.Finished.IsZero()
+		sig.Finished = signal.Finished
+		return finished && assert.ObjectsAreEqual(signal, sig)
+	}))
+	// A failure only ends its own run, other runs keep going
+	mockStateMgr.AssertNotCalled(t, "Complete")
 }
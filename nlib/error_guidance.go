@@ -0,0 +1,131 @@
+package nlib
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/node"
+)
+
+// Compile-time checks
+var _ node.ErrorGuidance = (*HTTPErrorGuidance)(nil)
+var _ node.ErrorGuidance = (*RetryErrorGuidance)(nil)
+var _ node.ErrorGuidance = (*IgnoreErrorGuidance)(nil)
+
+// ErrorBackoff can be implemented by a node.ErrorGuidance to control the delay before
+// each retry. attempt starts at 1 for the first retry.
+type ErrorBackoff interface {
+	Backoff(attempt int) time.Duration
+}
+
+// errorBackoff returns the delay before a retry. Guidance implementing ErrorBackoff
+// decides the delay, otherwise the delay grows quadratically in seconds.
+func errorBackoff(guide node.ErrorGuidance, attempt int) time.Duration {
+	if b, ok := guide.(ErrorBackoff); ok {
+		return b.Backoff(attempt)
+	}
+	return time.Duration(attempt*attempt) * time.Second
+}
+
+// exponentialBackoff doubles the base delay for every attempt
+func exponentialBackoff(base time.Duration, attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	return base * time.Duration(1<<(attempt-1))
+}
+
+// HTTPErrorGuidance retries errors that are likely to be transient, such as
+// HTTP 429 (rate limited), HTTP 5xx and network timeouts. Other HTTP 4xx
+// errors, cancellations and unknown errors fail the node.
+type HTTPErrorGuidance struct {
+	retries   int
+	baseDelay time.Duration
+}
+
+// NewHTTPErrorGuidance returns an HTTPErrorGuidance that retries up to retries times.
+// The delay between retries starts at baseDelay and doubles on each attempt.
+func NewHTTPErrorGuidance(retries int, baseDelay time.Duration) *HTTPErrorGuidance {
+	return &HTTPErrorGuidance{retries: retries, baseDelay: baseDelay}
+}
+
+func (g *HTTPErrorGuidance) Retries() int {
+	return g.retries
+}
+
+func (g *HTTPErrorGuidance) Backoff(attempt int) time.Duration {
+	return exponentialBackoff(g.baseDelay, attempt)
+}
+
+func (g *HTTPErrorGuidance) Action(err error) node.ErrGuide {
+	if err == nil {
+		return node.ErrGuideNotAnError
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return node.ErrGuideFail
+	}
+	var httpErr *llm.HTTPError
+	if errors.As(err, &httpErr) {
+		switch {
+		case httpErr.StatusCode == http.StatusTooManyRequests, httpErr.StatusCode >= 500:
+			return node.ErrGuideRetry
+		default:
+			return node.ErrGuideFail
+		}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return node.ErrGuideRetry
+	}
+	return node.ErrGuideFail
+}
+
+// RetryErrorGuidance retries every error up to a fixed number of times.
+type RetryErrorGuidance struct {
+	retries   int
+	baseDelay time.Duration
+}
+
+// NewRetryErrorGuidance returns a RetryErrorGuidance that retries up to retries times.
+// The delay between retries starts at baseDelay and doubles on each attempt.
+func NewRetryErrorGuidance(retries int, baseDelay time.Duration) *RetryErrorGuidance {
+	return &RetryErrorGuidance{retries: retries, baseDelay: baseDelay}
+}
+
+func (g *RetryErrorGuidance) Retries() int {
+	return g.retries
+}
+
+func (g *RetryErrorGuidance) Backoff(attempt int) time.Duration {
+	return exponentialBackoff(g.baseDelay, attempt)
+}
+
+func (g *RetryErrorGuidance) Action(err error) node.ErrGuide {
+	if err == nil {
+		return node.ErrGuideNotAnError
+	}
+	return node.ErrGuideRetry
+}
+
+// IgnoreErrorGuidance ignores every error, passing the signal through unchanged.
+type IgnoreErrorGuidance struct{}
+
+// NewIgnoreErrorGuidance returns an IgnoreErrorGuidance
+func NewIgnoreErrorGuidance() *IgnoreErrorGuidance {
+	return &IgnoreErrorGuidance{}
+}
+
+func (g *IgnoreErrorGuidance) Retries() int {
+	return 0
+}
+
+func (g *IgnoreErrorGuidance) Action(err error) node.ErrGuide {
+	if err == nil {
+		return node.ErrGuideNotAnError
+	}
+	return node.ErrGuideIgnore
+}
@@ -0,0 +1,128 @@
+package nlib_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/nmock"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHTTPErrorGuidance_Action(t *testing.T) {
+	guide := nlib.NewHTTPErrorGuidance(3, time.Millisecond)
+
+	assert.Equal(t, node.ErrGuideNotAnError, guide.Action(nil))
+	assert.Equal(t, node.ErrGuideRetry, guide.Action(&llm.HTTPError{StatusCode: 429}))
+	assert.Equal(t, node.ErrGuideRetry, guide.Action(&llm.HTTPError{StatusCode: 503}))
+	assert.Equal(t, node.ErrGuideFail, guide.Action(&llm.HTTPError{StatusCode: 400}))
+	assert.Equal(t, node.ErrGuideFail, guide.Action(context.Canceled))
+	assert.Equal(t, node.ErrGuideFail, guide.Action(errors.New("unknown")))
+	assert.Equal(t, 3, guide.Retries())
+	assert.Equal(t, 4*time.Millisecond, guide.Backoff(3))
+}
+
+func TestEmptyNode_RunWithErrorGuidance_Retry(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	n := &nlib.EmptyNode{}
+	n.SetStateManager(mgr)
+	n.SetOptions(node.Options{ErrorGuidance: nlib.NewRetryErrorGuidance(2, time.Millisecond)})
+
+	// failFirst returns a function failing its first n calls, counting them in calls
+	calls := 0
+	failFirst := func(n int) node.HookFn {
+		calls = 0
+		return func(sig node.Signal) (node.Signal, error) {
+			calls++
+			if calls <= n {
+				return sig, errors.New("transient")
+			}
+			sig.Status = StatusSuccess
+			return sig, nil
+		}
+	}
+
+	// Two failures are within the two retries
+	sig, err := n.RunWithErrorGuidance(context.Background(), createTestSignal("test-node"), failFirst(2))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls, "the first call and two retries")
+	assert.Equal(t, StatusSuccess, sig.Status)
+
+	// A third failure exhausts the retries
+	sig, err = n.RunWithErrorGuidance(context.Background(), createTestSignal("test-node"), failFirst(3))
+	assert.ErrorContains(t, err, "failed after 2 retries: transient")
+	assert.Equal(t, 3, calls, "the first call and two retries")
+	assert.Equal(t, StatusInProcess, sig.Status)
+}
+
+func TestEmptyNode_RunWithErrorGuidance_Ignore(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	n := &nlib.EmptyNode{}
+	n.SetStateManager(mgr)
+	n.SetOptions(node.Options{ErrorGuidance: nlib.NewIgnoreErrorGuidance()})
+
+	in := createTestSignal("test-node")
+	fn := func(sig node.Signal) (node.Signal, error) {
+		sig.Status = StatusFail
+		return sig, errors.New("ignored")
+	}
+	in.Task = nlib.NewTextCarrier("task")
+	out, err := n.RunWithErrorGuidance(context.Background(), in, fn)
+	assert.NoError(t, err)
+	assert.Equal(t, in.Status, out.Status)
+	assert.Equal(t, in.Task, out.Result, "the task is passed through as the result")
+	ignored, _ := out.Meta.GetString(nlib.MetaErrorIgnored)
+	assert.Equal(t, "ignored", ignored)
+}
+
+func TestAINode_IgnoredErrorDownstream(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	failing := newReplyLLM("", errors.New("unavailable"))
+	first := nlib.NewAINode(failing, mgr, node.Options{ID: "first", ErrorGuidance: nlib.NewIgnoreErrorGuidance()})
+	second := nlib.NewAINode(failing, mgr, node.Options{ID: "second", ErrorGuidance: nlib.NewIgnoreErrorGuidance()})
+	first.Connect(second)
+
+	sig, err := nlib.Run(context.Background(), first, node.Signal{Task: nlib.NewTextCarrier("hello")})
+	assert.NoError(t, err)
+	assert.Equal(t, "second", sig.NodeID)
+	assert.Equal(t, "hello", sig.Task.String(), "the skipped node passes its task on")
+	assert.Equal(t, "hello", sig.Result.String())
+	failing.AssertNumberOfCalls(t, "Chat", 2)
+	failing.AssertCalled(t, "Chat", mock.Anything, llm.MessageList{llm.UserMsg("hello")})
+}
+
+func TestAINode_NoTask(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	ai := nlib.NewAINode(newReplyLLM("HELLO", nil), mgr, node.Options{ID: "ai"})
+	_, err := nlib.Run(context.Background(), ai, node.Signal{})
+	assert.ErrorContains(t, err, nlib.ErrNoTask.Error())
+}
+
+func TestAINode_RetriesRateLimitedLLM(t *testing.T) {
+	lm := new(nmock.MockLLM)
+	lm.On("Model").Return("mock")
+	lm.On("Chat", mock.Anything, mock.Anything).Return(llm.Message{}, &llm.HTTPError{StatusCode: 429}).Once()
+	lm.On("Chat", mock.Anything, mock.Anything).Return(llm.Message{Role: llm.RoleAssistant, Content: "answer"}, nil).Once()
+
+	mgr := nlib.NewSimpleStateManager(nil)
+	options := node.Options{ID: "ai", ErrorGuidance: nlib.NewHTTPErrorGuidance(2, time.Millisecond)}
+	aiNode := nlib.NewAINode(lm, mgr, options)
+	out := newCollectorNode("out")
+	aiNode.Connect(out)
+	startGraph(t, aiNode)
+
+	aiNode.InputCh() <- node.Signal{NodeID: aiNode.ID(), Task: nlib.NewTextCarrier("question")}
+
+	select {
+	case sig := <-out.InputCh():
+		assert.Equal(t, "answer", sig.Task.String())
+	case <-time.After(2 * time.Second):
+		t.Fatal("Signal was not sent from AI node")
+	}
+	lm.AssertNumberOfCalls(t, "Chat", 2)
+}
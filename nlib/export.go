@@ -0,0 +1,292 @@
+package nlib
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/dshills/wiggle/node"
+)
+
+// ExportOptions controls how a graph is exported
+type ExportOptions struct {
+	StateManager node.StateManager // When set, each node is annotated with its state
+	RunID        string            // Annotate with the state of this run instead of the aggregate state
+}
+
+// exportEdge is a connection between two nodes in an exported graph
+type exportEdge struct {
+	from, to node.Node
+	label    string
+	back     bool // Loop back-edges and set final edges are drawn dashed
+}
+
+// exportGraph is the topology gathered from an entry node
+type exportGraph struct {
+	nodes []node.Node
+	edges []exportEdge
+	owner map[node.Node]*SimpleSetNode // Innermost set node containing each node
+	sets  []*SimpleSetNode
+	opts  ExportOptions
+}
+
+// newExportGraph walks the graph from the entry node. Set node collectors are hidden
+// and replaced by an edge from the final node back to the set node.
+func newExportGraph(entry node.Node, opts ExportOptions) *exportGraph {
+	g := &exportGraph{owner: make(map[node.Node]*SimpleSetNode), opts: opts}
+
+	collectors := make(map[node.Node]*SimpleSetNode)
+	Walk(func(n node.Node) {
+		if set, ok := n.(*SimpleSetNode); ok {
+			collectors[set.collector] = set
+		}
+	}, entry)
+
+	Walk(func(n node.Node) {
+		if _, ok := collectors[n]; ok {
+			return
+		}
+		g.nodes = append(g.nodes, n)
+		g.edges = append(g.edges, exportEdges(n, collectors)...)
+		if set, ok := n.(*SimpleSetNode); ok {
+			g.sets = append(g.sets, set)
+		}
+	}, entry)
+
+	// Sets found later in the walk are nested deeper, so they claim their nodes first
+	for i := len(g.sets) - 1; i >= 0; i-- {
+		set := g.sets[i]
+		if set.startNode == nil {
+			continue
+		}
+		Walk(func(n node.Node) {
+			if _, ok := g.owner[n]; !ok && n != set.collector {
+				g.owner[n] = set
+			}
+		}, set.startNode)
+	}
+	return g
+}
+
+// exportEdges returns the labelled edges leaving a node
+func exportEdges(n node.Node, collectors map[node.Node]*SimpleSetNode) []exportEdge {
+	edges := []exportEdge{}
+	connLabel := ""
+	switch t := n.(type) {
+	case node.BranchNode:
+		for i, cond := range t.Conditions() {
+			if cond.Target != nil {
+				edges = append(edges, exportEdge{from: n, to: cond.Target, label: fmt.Sprintf("cond %d", i)})
+			}
+		}
+		if len(t.Conditions()) > 0 {
+			connLabel = "default"
+		}
+	case *SimpleLoopNode:
+		if t.startNode != nil {
+			edges = append(edges, exportEdge{from: n, to: t.startNode, label: "loop", back: true})
+		}
+	case *SimpleSetNode:
+		if t.startNode != nil {
+			edges = append(edges, exportEdge{from: n, to: t.startNode, label: "start"})
+		}
+	}
+
+	if con, ok := n.(interface{ Nodes() []node.Node }); ok {
+		for _, c := range con.Nodes() {
+			if c == nil {
+				continue
+			}
+			if set, ok := collectors[c]; ok {
+				edges = append(edges, exportEdge{from: n, to: set, label: "final", back: true})
+				continue
+			}
+			edges = append(edges, exportEdge{from: n, to: c, label: connLabel})
+		}
+	}
+	return edges
+}
+
+// nodeType returns the name of the node's concrete type
+func nodeType(n node.Node) string {
+	t := reflect.TypeOf(n)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// state returns the node's state if the graph is annotated
+func (g *exportGraph) state(n node.Node) (node.State, bool) {
+	if g.opts.StateManager == nil {
+		return node.State{}, false
+	}
+	return g.opts.StateManager.GetState(node.Signal{NodeID: n.ID(), RunID: g.opts.RunID}), true
+}
+
+// labelLines returns the lines of a node's label
+func (g *exportGraph) labelLines(n node.Node) []string {
+	lines := []string{n.ID(), nodeType(n)}
+	if st, ok := g.state(n); ok {
+		lines = append(lines, fmt.Sprintf("%s completed=%d failures=%d", st.Status, st.Completed, st.Failures))
+	}
+	return lines
+}
+
+// childSets returns the set nodes directly owned by set, or the top level sets when set is nil
+func (g *exportGraph) childSets(set *SimpleSetNode) []*SimpleSetNode {
+	sets := []*SimpleSetNode{}
+	for _, s := range g.sets {
+		if g.owner[s] == set {
+			sets = append(sets, s)
+		}
+	}
+	return sets
+}
+
+// ownedNodes returns the nodes directly inside set, or the top level nodes when set is nil
+func (g *exportGraph) ownedNodes(set *SimpleSetNode) []node.Node {
+	nodes := []node.Node{}
+	for _, n := range g.nodes {
+		if g.owner[n] == set {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// stateClass maps a node status to the class used to color it
+func stateClass(status string) string {
+	switch status {
+	case StatusSuccess:
+		return "success"
+	case StatusFail:
+		return "fail"
+	case StatusInProcess:
+		return "inprocess"
+	}
+	return ""
+}
+
+// ExportDOT returns the graph reachable from the entry node in Graphviz DOT format.
+// Nodes are labelled with their ID and type, branch edges with the condition index and
+// loop back-edges are dashed. The internals of set nodes are drawn as clusters.
+func ExportDOT(entry node.Node, opts ExportOptions) string {
+	g := newExportGraph(entry, opts)
+	ids := make(map[node.Node]string)
+	for i, n := range g.nodes {
+		ids[n] = fmt.Sprintf("n%d", i)
+	}
+	colors := map[string]string{"success": "palegreen", "fail": "lightcoral", "inprocess": "lightyellow"}
+
+	var b strings.Builder
+	b.WriteString("digraph wiggle {\n")
+	b.WriteString("\tnode [shape=box];\n")
+
+	var writeNodes func(set *SimpleSetNode, indent string)
+	writeNodes = func(set *SimpleSetNode, indent string) {
+		for _, n := range g.ownedNodes(set) {
+			attrs := fmt.Sprintf("label=%s", dotQuote(strings.Join(g.labelLines(n), "\n")))
+			if st, ok := g.state(n); ok {
+				if color, ok := colors[stateClass(st.Status)]; ok {
+					attrs += fmt.Sprintf(", style=filled, fillcolor=%s", color)
+				}
+			}
+			fmt.Fprintf(&b, "%s%s [%s];\n", indent, ids[n], attrs)
+		}
+		for _, s := range g.childSets(set) {
+			fmt.Fprintf(&b, "%ssubgraph cluster_%s {\n", indent, ids[s])
+			fmt.Fprintf(&b, "%s\tlabel=%s;\n", indent, dotQuote(s.ID()))
+			writeNodes(s, indent+"\t")
+			fmt.Fprintf(&b, "%s}\n", indent)
+		}
+	}
+	writeNodes(nil, "\t")
+
+	for _, e := range g.edges {
+		attrs := []string{}
+		if e.label != "" {
+			attrs = append(attrs, "label="+dotQuote(e.label))
+		}
+		if e.back {
+			attrs = append(attrs, "style=dashed")
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, "\t%s -> %s [%s];\n", ids[e.from], ids[e.to], strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(&b, "\t%s -> %s;\n", ids[e.from], ids[e.to])
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// ExportMermaid returns the graph reachable from the entry node as a Mermaid flowchart.
+// Nodes are labelled with their ID and type, branch edges with the condition index and
+// loop back-edges are dotted. The internals of set nodes are drawn as subgraphs.
+func ExportMermaid(entry node.Node, opts ExportOptions) string {
+	g := newExportGraph(entry, opts)
+	ids := make(map[node.Node]string)
+	for i, n := range g.nodes {
+		ids[n] = fmt.Sprintf("n%d", i)
+	}
+
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+
+	classes := make(map[string][]string)
+	var writeNodes func(set *SimpleSetNode, indent string)
+	writeNodes = func(set *SimpleSetNode, indent string) {
+		for _, n := range g.ownedNodes(set) {
+			fmt.Fprintf(&b, "%s%s[%s]\n", indent, ids[n], mermaidQuote(strings.Join(g.labelLines(n), "<br/>")))
+			if st, ok := g.state(n); ok {
+				if class := stateClass(st.Status); class != "" {
+					classes[class] = append(classes[class], ids[n])
+				}
+			}
+		}
+		for _, s := range g.childSets(set) {
+			fmt.Fprintf(&b, "%ssubgraph %s_set [%s]\n", indent, ids[s], mermaidQuote(s.ID()))
+			writeNodes(s, indent+"\t")
+			fmt.Fprintf(&b, "%send\n", indent)
+		}
+	}
+	writeNodes(nil, "\t")
+
+	for _, e := range g.edges {
+		arrow := "-->"
+		if e.back {
+			arrow = "-.->"
+		}
+		if e.label != "" {
+			fmt.Fprintf(&b, "\t%s %s|%s| %s\n", ids[e.from], arrow, mermaidQuote(e.label), ids[e.to])
+		} else {
+			fmt.Fprintf(&b, "\t%s %s %s\n", ids[e.from], arrow, ids[e.to])
+		}
+	}
+
+	if len(classes) > 0 {
+		fills := map[string]string{"success": "#98fb98", "fail": "#f08080", "inprocess": "#ffffe0"}
+		for _, class := range []string{"success", "fail", "inprocess"} {
+			if len(classes[class]) == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, "\tclassDef %s fill:%s\n", class, fills[class])
+			fmt.Fprintf(&b, "\tclass %s %s\n", strings.Join(classes[class], ","), class)
+		}
+	}
+	return b.String()
+}
+
+// dotQuote returns s as a quoted DOT string
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// mermaidQuote returns s as a quoted Mermaid label
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
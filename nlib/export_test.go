@@ -0,0 +1,75 @@
+package nlib_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+)
+
+// newExportGraph builds: branch -(cond 0)-> set{upper -> exclaim} -> loop -(loop)-> branch, branch -(default)-> out
+// The walk numbers them branch n0, out n1, set n2, loop n3, upper n4, exclaim n5
+func newExportGraph(mgr node.StateManager) node.Node {
+	upper := newTransformNode(mgr, "upper", strings.ToUpper)
+	exclaim := newTransformNode(mgr, "exclaim", func(s string) string { return s + "!" })
+	upper.Connect(exclaim)
+	set := nlib.NewSimpleSetNode(upper, exclaim, mgr, node.Options{ID: "set"})
+
+	branch := newTransformNode(mgr, "branch", strings.TrimSpace).(*nlib.SimpleBranchNode)
+	loop := nlib.NewSimpleLoopNode(branch, func(node.Signal) bool { return true }, mgr, node.Options{ID: "loop"})
+	out := newTransformNode(mgr, "out", strings.TrimSpace)
+	branch.AddConditional(node.BranchCondition{Target: set, ConditionFn: func(node.Signal) bool { return true }})
+	branch.Connect(out)
+	set.Connect(loop)
+	return branch
+}
+
+func TestExportDOT(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	dot := nlib.ExportDOT(newExportGraph(mgr), nlib.ExportOptions{})
+
+	assert.True(t, strings.HasPrefix(dot, "digraph wiggle {\n"))
+	assert.Contains(t, dot, `n0 [label="branch\nSimpleBranchNode"];`)
+	assert.Contains(t, dot, `n0 -> n2 [label="cond 0"];`)
+	assert.Contains(t, dot, `n0 -> n1 [label="default"];`)
+	assert.Contains(t, dot, `n2 -> n4 [label="start"];`)
+	assert.Contains(t, dot, `n3 -> n0 [label="loop", style=dashed];`)
+	assert.Contains(t, dot, "subgraph cluster_n2 {\n\t\tlabel=\"set\";\n\t\tn4")
+	assert.Contains(t, dot, `n5 -> n2 [label="final", style=dashed];`)
+	assert.NotContains(t, dot, "collector")
+}
+
+func TestExportMermaid(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	mmd := nlib.ExportMermaid(newExportGraph(mgr), nlib.ExportOptions{})
+
+	assert.True(t, strings.HasPrefix(mmd, "flowchart TD\n"))
+	assert.Contains(t, mmd, `n0["branch<br/>SimpleBranchNode"]`)
+	assert.Contains(t, mmd, `n0 -->|"cond 0"| n2`)
+	assert.Contains(t, mmd, `n3 -.->|"loop"| n0`)
+	assert.Contains(t, mmd, `subgraph n2_set ["set"]`)
+	assert.NotContains(t, mmd, "collector")
+}
+
+func TestExport_StateAnnotation(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	upper := newTransformNode(mgr, "upper", strings.ToUpper)
+	broken := nlib.NewSimpleBranchNode(mgr, node.Options{ID: "broken", Hooks: nlib.NewSimpleNodeHooks(func(sig node.Signal) (node.Signal, error) {
+		return sig, assert.AnError
+	}, nil)})
+	upper.Connect(broken)
+
+	sig, _ := nlib.Run(context.Background(), upper, node.Signal{Task: nlib.NewTextCarrier("hi")})
+
+	opts := nlib.ExportOptions{StateManager: mgr, RunID: sig.RunID}
+	dot := nlib.ExportDOT(upper, opts)
+	assert.Contains(t, dot, `n0 [label="upper\nSimpleBranchNode\nsuccess completed=1 failures=0", style=filled, fillcolor=palegreen];`)
+	assert.Contains(t, dot, `n1 [label="broken\nSimpleBranchNode\nfail completed=1 failures=1", style=filled, fillcolor=lightcoral];`)
+
+	mmd := nlib.ExportMermaid(upper, opts)
+	assert.Contains(t, mmd, "class n0 success")
+	assert.Contains(t, mmd, "class n1 fail")
+}
@@ -24,3 +24,26 @@ func GenerateAINodeFactory(lm llm.LLM, mgr node.StateManager, prefix string, opt
 		return nodes
 	}
 }
+
+// SetBuilderFn builds a sub-graph for a SimpleSetNode. It receives the ID of the
+// set being built and returns the start and final nodes of the sub-graph.
+type SetBuilderFn func(setID string) (start node.Node, final node.Node)
+
+// GenerateSetNodeFactory will return a node.Factory function that creates SimpleSetNodes on-demand.
+// Each set is populated by calling build. Used with PartitionerNode to process each chunk with a
+// multi-node sub-flow.
+func GenerateSetNodeFactory(build SetBuilderFn, mgr node.StateManager, prefix string, options node.Options) node.Factory {
+	return func(count int) []node.Node {
+		nodes := []node.Node{}
+		for i := 0; i < count; i++ {
+			suffix, err := GenerateUUID()
+			if err != nil {
+				suffix = strconv.Itoa(i)
+			}
+			options.ID = fmt.Sprintf("%s-%s", prefix, suffix)
+			start, final := build(options.ID)
+			nodes = append(nodes, NewSimpleSetNode(start, final, mgr, options))
+		}
+		return nodes
+	}
+}
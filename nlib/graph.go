@@ -0,0 +1,110 @@
+package nlib
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dshills/wiggle/node"
+)
+
+// Successors returns the nodes a node can send signals to. In addition to the
+// connected nodes it includes branch targets and the start node of loops and sets.
+func Successors(n node.Node) []node.Node {
+	var next []node.Node
+	if con, ok := n.(interface{ Nodes() []node.Node }); ok {
+		next = append(next, con.Nodes()...)
+	}
+	if branch, ok := n.(node.BranchNode); ok {
+		for _, cond := range branch.Conditions() {
+			if cond.Target != nil {
+				next = append(next, cond.Target)
+			}
+		}
+	}
+	if start, ok := n.(interface{ StartNode() node.Node }); ok && start.StartNode() != nil {
+		next = append(next, start.StartNode())
+	}
+	return next
+}
+
+// Walk calls fn once for every node reachable from the entry nodes,
+// in breadth-first order.
+func Walk(fn func(node.Node), entries ...node.Node) {
+	visited := make(map[node.Node]bool)
+	queue := []node.Node{}
+	for _, n := range entries {
+		if n != nil && !visited[n] {
+			visited[n] = true
+			queue = append(queue, n)
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		fn(n)
+		for _, next := range Successors(n) {
+			if next != nil && !visited[next] {
+				visited[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+}
+
+// Graph manages the lifecycle of every node reachable from its entry nodes.
+// Starting the graph starts each node implementing node.Lifecycle and stopping it
+// terminates them, waiting until all of their goroutines have exited.
+type Graph struct {
+	entries []node.Node
+	mu      sync.Mutex
+	started map[node.Lifecycle]bool
+}
+
+// NewGraph creates a Graph from the entry nodes. Nodes are discovered when the graph is started.
+func NewGraph(entries ...node.Node) *Graph {
+	return &Graph{entries: entries}
+}
+
+// Entries returns the entry nodes of the graph
+func (g *Graph) Entries() []node.Node {
+	return g.entries
+}
+
+// Nodes returns every node reachable from the entry nodes
+func (g *Graph) Nodes() []node.Node {
+	nodes := []node.Node{}
+	Walk(func(n node.Node) { nodes = append(nodes, n) }, g.entries...)
+	return nodes
+}
+
+// Start starts every node in the graph. Nodes stop when ctx is cancelled or Stop is called.
+// Calling Start on a running graph starts any nodes connected since it was started, and
+// restarts nodes that stopped because the context they were started with was cancelled.
+// Nodes that are already running are left to whoever started them.
+func (g *Graph) Start(ctx context.Context) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, n := range g.Nodes() {
+		r, reports := n.(interface{ Running() bool })
+		if reports && r.Running() {
+			continue
+		}
+		if lc, ok := n.(node.Lifecycle); ok && (reports || !g.started[lc]) {
+			if g.started == nil {
+				g.started = make(map[node.Lifecycle]bool)
+			}
+			lc.Start(ctx)
+			g.started[lc] = true
+		}
+	}
+}
+
+// Stop stops every node started by the graph and blocks until they have exited.
+func (g *Graph) Stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for lc := range g.started {
+		lc.Stop()
+	}
+	g.started = nil
+}
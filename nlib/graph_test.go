@@ -0,0 +1,116 @@
+package nlib_test
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+)
+
+// startGraph starts every node reachable from the entries and stops them when the test ends
+func startGraph(t *testing.T, entries ...node.Node) *nlib.Graph {
+	t.Helper()
+	g := nlib.NewGraph(entries...)
+	g.Start(context.Background())
+	t.Cleanup(g.Stop)
+	return g
+}
+
+func TestWalk_VisitsBranchAndLoopTargets(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	first := newTransformNode(mgr, "first", strings.ToUpper)
+	target := newTransformNode(mgr, "target", strings.ToUpper)
+	branch := nlib.NewSimpleBranchNode(mgr, node.Options{ID: "branch"})
+	branch.AddConditional(node.BranchCondition{Target: target, ConditionFn: func(node.Signal) bool { return true }})
+	loop := nlib.NewSimpleLoopNode(first, nil, mgr, node.Options{ID: "loop"})
+	first.Connect(branch)
+	branch.Connect(loop)
+
+	ids := []string{}
+	nlib.Walk(func(n node.Node) { ids = append(ids, n.ID()) }, first)
+	assert.ElementsMatch(t, []string{"first", "branch", "target", "loop"}, ids)
+}
+
+func TestGraph_StartStop(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	upper := newTransformNode(mgr, "upper", strings.ToUpper)
+	out := newCollectorNode("out")
+	upper.Connect(out)
+
+	g := nlib.NewGraph(upper)
+	g.Start(context.Background())
+	assert.True(t, upper.(*nlib.SimpleBranchNode).Running())
+
+	upper.InputCh() <- node.Signal{NodeID: upper.ID(), Task: nlib.NewTextCarrier("hi")}
+	select {
+	case sig := <-out.InputCh():
+		assert.Equal(t, "HI", sig.Task.String())
+	case <-time.After(2 * time.Second):
+		t.Fatal("Signal was not processed")
+	}
+
+	g.Stop()
+	assert.False(t, upper.(*nlib.SimpleBranchNode).Running())
+
+	select {
+	case upper.InputCh() <- node.Signal{NodeID: upper.ID()}:
+		t.Fatal("Stopped node received a signal")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestGraph_RestartAfterCancel(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	upper := newTransformNode(mgr, "upper", strings.ToUpper)
+	exclaim := newTransformNode(mgr, "exclaim", func(s string) string { return s + "!" })
+	upper.Connect(exclaim)
+	set := nlib.NewSimpleSetNode(upper, exclaim, mgr, node.Options{ID: "set"})
+	out := newCollectorNode("out")
+	set.Connect(out)
+
+	g := nlib.NewGraph(set)
+	ctx, cancel := context.WithCancel(context.Background())
+	g.Start(ctx)
+	t.Cleanup(g.Stop)
+	assert.True(t, set.Running())
+	assert.True(t, upper.(*nlib.SimpleBranchNode).Running())
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		return !set.Running() && !upper.(*nlib.SimpleBranchNode).Running()
+	}, 2*time.Second, time.Millisecond, "nodes stop when the start context is cancelled")
+
+	g.Start(context.Background())
+	assert.True(t, set.Running())
+	assert.True(t, upper.(*nlib.SimpleBranchNode).Running(), "the set restarts its sub-graph")
+
+	set.InputCh() <- node.Signal{NodeID: set.ID(), Task: nlib.NewTextCarrier("hi")}
+	select {
+	case sig := <-out.InputCh():
+		assert.Equal(t, "HI!", sig.Task.String())
+	case <-time.After(2 * time.Second):
+		t.Fatal("Signal was not processed after the restart")
+	}
+}
+
+func TestGraph_NoGoroutineLeak(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 200; i++ {
+		a := newTransformNode(mgr, "a", strings.ToUpper)
+		b := newTransformNode(mgr, "b", strings.ToLower)
+		set := nlib.NewSimpleSetNode(a, b, mgr, node.Options{ID: "set"})
+		a.Connect(b)
+		g := nlib.NewGraph(set)
+		g.Start(context.Background())
+		g.Stop()
+	}
+
+	assert.Eventually(t, func() bool { return runtime.NumGoroutine() <= before+2 }, 2*time.Second, 10*time.Millisecond)
+}
@@ -22,6 +22,7 @@ type SimpleGuidance struct {
 	OutputFormat   string         // The desired format for the output
 	Tone           string         // The tone the LLM should use in the response
 	Schema         *schema.Schema // Optional JSON schema for defining output format
+	Template       string         // Optional text/template used instead of BasicTemplate, executed with BasicTemplateData
 }
 
 // NewSimpleGuidance creates a new instance of SimpleGuidance with default values.
@@ -33,11 +34,16 @@ func NewSimpleGuidance() *SimpleGuidance {
 // Generate constructs a new signal by creating a prompt from the guidance metadata.
 // It retrieves additional context from the signal, if available, and includes that in the prompt.
 // If no context is found, the prompt is generated without it. The generated prompt is assigned
-// to the signal's Task and returned for further processing.
+// to the signal's Task and returned for further processing. The template can read the
+// signal's metadata through the Meta and MetaAll fields of BasicTemplateData.
 func (g *SimpleGuidance) Generate(sig node.Signal, context string) (node.Signal, error) {
 	var err error
 	tmpl := template.New("basic").Funcs(template.FuncMap{"add": AddFn})
-	tmpl, err = tmpl.Parse(BasicTemplate)
+	text := BasicTemplate
+	if g.Template != "" {
+		text = g.Template
+	}
+	tmpl, err = tmpl.Parse(text)
 	if err != nil {
 		return sig, err
 	}
@@ -45,6 +51,11 @@ func (g *SimpleGuidance) Generate(sig node.Signal, context string) (node.Signal,
 	if err != nil {
 		return sig, err
 	}
+	data.Meta = sig.Meta.Map()
+	data.MetaAll = make(map[string][]any)
+	for _, m := range sig.Meta {
+		data.MetaAll[m.Key] = append(data.MetaAll[m.Key], m.Any())
+	}
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, data); err != nil {
 		return sig, err
@@ -12,6 +12,8 @@ type BasicTemplateData struct {
 	Context        string
 	Input          string
 	Schema         string
+	Meta           map[string]any   // Latest value of each metadata key of the signal, such as {{ .Meta.language }}
+	MetaAll        map[string][]any // Every value of each metadata key of the signal
 }
 
 // AddFn is used by the BasicTemplate to make a numbered list
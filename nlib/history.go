@@ -1,6 +1,10 @@
 package nlib
 
-import "github.com/dshills/wiggle/node"
+import (
+	"sync"
+
+	"github.com/dshills/wiggle/node"
+)
 
 // Compile-time check to ensure SimpleHistoryManager implements the node.HistoryManager interface.
 var _ node.HistoryManager = (*SimpleHistoryManager)(nil)
@@ -9,6 +13,7 @@ var _ node.HistoryManager = (*SimpleHistoryManager)(nil)
 // It stores a list of signals, keeping track of the signal history as they are processed by nodes.
 type SimpleHistoryManager struct {
 	signals []node.Signal // Slice to store the history of signals
+	m       sync.RWMutex  // Mutex to ensure thread-safe access to the history
 }
 
 // NewSimpleHistoryManager initializes and returns a new instance of SimpleHistoryManager.
@@ -18,6 +23,8 @@ func NewSimpleHistoryManager() *SimpleHistoryManager {
 
 // AddHistory appends the given signal to the list of signal history.
 func (hx *SimpleHistoryManager) AddHistory(sig node.Signal) {
+	hx.m.Lock()
+	defer hx.m.Unlock()
 	hx.signals = append(hx.signals, sig) // Add the signal to the history slice
 }
 
@@ -28,12 +35,16 @@ func (hx *SimpleHistoryManager) CompressHistory() error {
 
 // GetHistory returns the full list of signals in the history.
 func (hx *SimpleHistoryManager) GetHistory() []node.Signal {
-	return hx.signals // Return the complete signal history
+	hx.m.RLock()
+	defer hx.m.RUnlock()
+	return append([]node.Signal{}, hx.signals...) // Return a copy of the complete signal history
 }
 
 // GetHistoryByID returns the signals that match the provided Node ID.
 // It filters through the history and collects signals with the specified ID.
 func (hx *SimpleHistoryManager) Filter(nodeid string) []node.Signal {
+	hx.m.RLock()
+	defer hx.m.RUnlock()
 	sigList := []node.Signal{}
 	for _, sig := range hx.signals {
 		if sig.NodeID == nodeid {
@@ -42,3 +53,29 @@ func (hx *SimpleHistoryManager) Filter(nodeid string) []node.Signal {
 	}
 	return sigList
 }
+
+// FilterRun returns the signals that belong to the run with the provided ID.
+func (hx *SimpleHistoryManager) FilterRun(runID string) []node.Signal {
+	hx.m.RLock()
+	defer hx.m.RUnlock()
+	sigList := []node.Signal{}
+	for _, sig := range hx.signals {
+		if sig.RunID == runID {
+			sigList = append(sigList, sig)
+		}
+	}
+	return sigList
+}
+
+// RemoveRun drops the signals that belong to the run with the provided ID.
+func (hx *SimpleHistoryManager) RemoveRun(runID string) {
+	hx.m.Lock()
+	defer hx.m.Unlock()
+	kept := hx.signals[:0]
+	for _, sig := range hx.signals {
+		if sig.RunID != runID {
+			kept = append(kept, sig)
+		}
+	}
+	hx.signals = kept
+}
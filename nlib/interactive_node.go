@@ -2,7 +2,6 @@ package nlib
 
 import (
 	"bufio"
-	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -15,11 +14,12 @@ var _ node.Node = (*InteractiveNode)(nil)
 
 // InteractiveNode is a node that interacts with the user via the command line.
 // It waits for user input and processes the user's query as part of the signal.
+// It processes one signal at a time regardless of Options.Concurrency.
 type InteractiveNode struct {
 	EmptyNode // Inherits base node functionality.
 }
 
-// NewInteractiveNode creates a new InteractiveNode and starts listening for signals.
+// NewInteractiveNode creates a new InteractiveNode that listens for signals once started.
 // - l: Logger for logging interactions.
 // - sm: StateManager for managing the state of the node.
 // - name: Name/ID of the node.
@@ -28,28 +28,20 @@ func NewInteractiveNode(mgr node.StateManager, options node.Options) *Interactiv
 	n.SetOptions(options)
 	n.SetStateManager(mgr)
 	n.MakeInputCh()
+	n.serial = true // Only one prompt can read from the user at a time
+	n.SetProcessFunc(n.processSignal)
 
-	go func() {
-		for {
-			select {
-			case sig := <-n.InputCh():
-				n.processSignal(sig)
-			case <-n.StateManager().Register():
-				n.LogInfo("Received done")
-				return
-			}
-		}
-	}()
 	return &n
 }
 
 // listen listens for incoming signals and processes them by interacting with the user.
 // It prompts the user to enter a query and sends that query as the signal's response.
 func (n *InteractiveNode) processSignal(sig node.Signal) {
-	n.LogInfo("Received signal") // Log that a signal has been received.
-
 	var err error
-	sig, err = n.PreProcessSignal(sig)
+	ctx, done := n.BeginSignal(sig)
+	defer done()
+
+	sig, err = n.PreProcessSignal(ctx, sig)
 	if err != nil {
 		n.Fail(sig, err)
 		return
@@ -77,13 +69,13 @@ func (n *InteractiveNode) processSignal(sig node.Signal) {
 	sig.Status = StatusSuccess
 	// Run post-processing hooks and forward the signal to connected nodes.
 
-	sig, err = n.PostProcessSignal(sig)
+	sig, err = n.PostProcessSignal(ctx, sig)
 	if err != nil {
 		n.Fail(sig, err)
 		return
 	}
 
-	if err := n.SendToConnected(context.TODO(), sig); err != nil {
+	if err := n.SendToConnected(ctx, sig); err != nil {
 		n.Fail(sig, err)
 		return
 	}
@@ -0,0 +1,294 @@
+package nlib
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dshills/wiggle/node"
+)
+
+// Ensure that SimpleJoinNode implements the node.JoinNode interface
+var _ node.JoinNode = (*SimpleJoinNode)(nil)
+
+// SimpleJoinNode merges the signals of parallel branches back together. Signals are
+// correlated by run ID. Once every upstream node has sent a signal for a run, or the
+// configured number of signals has arrived, or the wait timeout expires, the signals
+// are merged into one signal that is sent to the connected nodes.
+type SimpleJoinNode struct {
+	EmptyNode                         // Embeds the base functionality of an EmptyNode
+	mergeFn     node.MergeFn          // Function producing the Result of the merged signal
+	upstream    []string              // IDs of the nodes to wait for
+	count       int                   // Number of signals to wait for when no upstream IDs are set
+	waitTimeout time.Duration         // Maximum time to wait after the first signal of a run, zero waits forever
+	joinMu      sync.Mutex            // Protects pending
+	pending     map[string]*joinState // Signals received so far by run ID
+}
+
+// joinState holds the signals received for a single run
+type joinState struct {
+	sigs     []node.Signal
+	held     bool // Signals are kept pending for the run until the join is released
+	timer    *time.Timer
+	stopWait func() bool // Stops watching the run's context
+}
+
+// NewSimpleJoinNode creates a new SimpleJoinNode. A nil merge function concatenates
+// the Tasks of the received signals with MergeTasks.
+func NewSimpleJoinNode(mfn node.MergeFn, mgr node.StateManager, options node.Options) *SimpleJoinNode {
+	if mfn == nil {
+		mfn = MergeTasks
+	}
+	n := SimpleJoinNode{mergeFn: mfn, pending: make(map[string]*joinState)}
+	n.SetOptions(options)
+	n.SetStateManager(mgr)
+	n.MakeInputCh()
+	n.SetProcessFunc(n.processSignal)
+
+	return &n
+}
+
+// MergeTasks joins the Task text of each signal with newlines
+func MergeTasks(sigs []node.Signal) (node.DataCarrier, error) {
+	parts := []string{}
+	for _, sig := range sigs {
+		if sig.Task != nil {
+			parts = append(parts, sig.Task.String())
+		}
+	}
+	return NewTextCarrier(strings.Join(parts, "\n")), nil
+}
+
+// SetMergeFunc sets the function that merges the received signals
+func (n *SimpleJoinNode) SetMergeFunc(mfn node.MergeFn) {
+	n.mergeFn = mfn
+}
+
+// SetUpstream sets the IDs of the nodes to wait for. Signals are passed to the merge
+// function in this order.
+func (n *SimpleJoinNode) SetUpstream(ids ...string) {
+	n.joinMu.Lock()
+	defer n.joinMu.Unlock()
+	n.upstream = ids
+}
+
+// SetCount sets the number of signals to wait for when no upstream IDs are set
+func (n *SimpleJoinNode) SetCount(count int) {
+	n.joinMu.Lock()
+	defer n.joinMu.Unlock()
+	n.count = count
+}
+
+// SetWaitTimeout sets how long to wait after the first signal of a run before
+// merging whatever has arrived. Zero waits forever.
+func (n *SimpleJoinNode) SetWaitTimeout(d time.Duration) {
+	n.joinMu.Lock()
+	defer n.joinMu.Unlock()
+	n.waitTimeout = d
+}
+
+// Stop stops the node and discards any partially joined runs
+func (n *SimpleJoinNode) Stop() {
+	n.EmptyNode.Stop()
+
+	n.joinMu.Lock()
+	pending := n.pending
+	n.pending = make(map[string]*joinState)
+	n.joinMu.Unlock()
+	for _, st := range pending {
+		st.release()
+	}
+}
+
+// processSignal stores the signal with the others of its run and merges them once the join is complete
+func (n *SimpleJoinNode) processSignal(sig node.Signal) {
+	ctx, done := n.BeginSignal(sig)
+	sig, err := n.PreProcessSignal(ctx, sig)
+	done()
+	if err != nil {
+		n.Fail(sig, err)
+		return
+	}
+
+	n.joinMu.Lock()
+	if len(n.upstream) == 0 && n.count < 1 {
+		n.joinMu.Unlock()
+		n.Fail(sig, fmt.Errorf("join node %s has no upstream nodes or count", n.ID()))
+		return
+	}
+
+	st, ok := n.pending[sig.RunID]
+	if !ok {
+		st = &joinState{}
+		n.pending[sig.RunID] = st
+		runID := sig.RunID
+		if n.waitTimeout > 0 {
+			st.timer = time.AfterFunc(n.waitTimeout, func() { n.timeout(runID) })
+			st.held = true
+		}
+		st.stopWait = context.AfterFunc(sig.Context(), func() { n.discard(runID) })
+	}
+	st.add(sig, n.upstream)
+
+	complete := n.complete(st)
+	if complete {
+		delete(n.pending, sig.RunID)
+	}
+	upstream := n.upstream
+	n.joinMu.Unlock()
+
+	if complete {
+		n.emit(st, upstream)
+	}
+}
+
+// add stores a signal, keeping only the latest signal from each upstream node.
+// A join with a wait timeout is certain to emit, so its signals keep the run
+// pending until then. Without one, a run whose other branches have finished or
+// failed ends and the partial join is discarded when the run is cancelled.
+func (st *joinState) add(sig node.Signal, upstream []string) {
+	if st.held {
+		DispatchSignal(sig)
+	}
+	if len(upstream) > 0 {
+		for i := range st.sigs {
+			if st.sigs[i].FromNodeID == sig.FromNodeID {
+				st.consume(st.sigs[i])
+				st.sigs[i] = sig
+				return
+			}
+		}
+	}
+	st.sigs = append(st.sigs, sig)
+}
+
+func (st *joinState) consume(sig node.Signal) {
+	if st.held {
+		ConsumeSignal(sig)
+	}
+}
+
+// release stops the timers of a join and releases its signals
+func (st *joinState) release() {
+	if st.timer != nil {
+		st.timer.Stop()
+	}
+	if st.stopWait != nil {
+		st.stopWait()
+	}
+	for _, sig := range st.sigs {
+		st.consume(sig)
+	}
+}
+
+// complete reports whether every expected signal has arrived. Must be called with joinMu held.
+func (n *SimpleJoinNode) complete(st *joinState) bool {
+	if len(n.upstream) == 0 {
+		return len(st.sigs) >= n.count
+	}
+	for _, id := range n.upstream {
+		found := false
+		for _, sig := range st.sigs {
+			if sig.FromNodeID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// timeout merges whatever has arrived for a run when the wait timeout expires
+func (n *SimpleJoinNode) timeout(runID string) {
+	n.joinMu.Lock()
+	st, ok := n.pending[runID]
+	if ok {
+		delete(n.pending, runID)
+	}
+	upstream := n.upstream
+	n.joinMu.Unlock()
+
+	if ok {
+		n.LogAt(node.LevelWarn, "Join timed out", node.LogKeyRunID, runID, "signals", len(st.sigs))
+		n.emit(st, upstream)
+	}
+}
+
+// discard drops the signals of a run that has been cancelled
+func (n *SimpleJoinNode) discard(runID string) {
+	n.joinMu.Lock()
+	st, ok := n.pending[runID]
+	if ok {
+		delete(n.pending, runID)
+	}
+	n.joinMu.Unlock()
+
+	if ok {
+		st.release()
+	}
+}
+
+// emit merges the signals of a run and sends the result to the connected nodes
+func (n *SimpleJoinNode) emit(st *joinState, upstream []string) {
+	defer st.release()
+
+	sigs := orderSignals(st.sigs, upstream)
+	sig := sigs[0]
+	sig.NodeID = n.ID()
+	sig.ID = NewSignalID()
+	sig.ParentIDs = nil
+	metas := []node.Metadata{}
+	for _, s := range sigs {
+		sig.ParentIDs = append(sig.ParentIDs, s.ParentIDs...)
+		metas = append(metas, s.Meta)
+	}
+	sig.Meta = node.MergeMetadata(metas...)
+	ctx, done := n.BeginSignal(sig)
+	defer done()
+
+	sig.Status = StatusInProcess
+	result, err := n.mergeFn(sigs)
+	if err != nil {
+		n.Fail(sig, err)
+		return
+	}
+	sig.Result = result
+	sig.Status = StatusSuccess
+
+	sig, err = n.PostProcessSignal(ctx, sig)
+	if err != nil {
+		n.Fail(sig, err)
+		return
+	}
+
+	if err := n.SendToConnected(ctx, sig); err != nil {
+		n.Fail(sig, err)
+		return
+	}
+}
+
+// orderSignals sorts signals by the order of the upstream IDs, signals from
+// other nodes follow in arrival order
+func orderSignals(sigs []node.Signal, upstream []string) []node.Signal {
+	ordered := []node.Signal{}
+	used := make([]bool, len(sigs))
+	for _, id := range upstream {
+		for i, sig := range sigs {
+			if !used[i] && sig.FromNodeID == id {
+				ordered = append(ordered, sig)
+				used[i] = true
+			}
+		}
+	}
+	for i, sig := range sigs {
+		if !used[i] {
+			ordered = append(ordered, sig)
+		}
+	}
+	return ordered
+}
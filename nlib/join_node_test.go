@@ -0,0 +1,108 @@
+package nlib_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+)
+
+// newDiamond builds src -> {upper, lower} -> join
+func newDiamond(mgr node.StateManager) (node.Node, *nlib.SimpleJoinNode) {
+	src := newTransformNode(mgr, "src", strings.TrimSpace)
+	upper := newTransformNode(mgr, "upper", strings.ToUpper)
+	lower := newTransformNode(mgr, "lower", strings.ToLower)
+	join := nlib.NewSimpleJoinNode(nil, mgr, node.Options{ID: "join"})
+	src.Connect(upper, lower)
+	upper.Connect(join)
+	lower.Connect(join)
+	return src, join
+}
+
+func TestSimpleJoinNode_Upstream(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	src, join := newDiamond(mgr)
+	join.SetUpstream("lower", "upper")
+
+	sig, err := nlib.Run(context.Background(), src, node.Signal{Task: nlib.NewTextCarrier(" Hello ")})
+	assert.NoError(t, err)
+	assert.Equal(t, "join", sig.NodeID)
+	assert.Equal(t, "hello\nHELLO", sig.Result.String())
+}
+
+func TestSimpleJoinNode_CorrelatesRuns(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	src, join := newDiamond(mgr)
+	join.SetCount(2)
+	join.SetMergeFunc(func(sigs []node.Signal) (node.DataCarrier, error) {
+		parts := []string{}
+		for _, sig := range sigs {
+			parts = append(parts, sig.Task.String())
+		}
+		if parts[0] > parts[1] {
+			parts[0], parts[1] = parts[1], parts[0]
+		}
+		return nlib.NewTextCarrier(strings.Join(parts, "+")), nil
+	})
+	startGraph(t, src)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			word := fmt.Sprintf("Word%d", i)
+			sig, err := nlib.Run(context.Background(), src, node.Signal{Task: nlib.NewTextCarrier(word)})
+			assert.NoError(t, err)
+			assert.Equal(t, strings.ToUpper(word)+"+"+strings.ToLower(word), sig.Result.String())
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestSimpleJoinNode_WaitTimeout(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	src, join := newDiamond(mgr)
+	join.SetUpstream("upper", "lower", "missing")
+	join.SetWaitTimeout(50 * time.Millisecond)
+
+	start := time.Now()
+	sig, err := nlib.Run(context.Background(), src, node.Signal{Task: nlib.NewTextCarrier("Hi")})
+	assert.NoError(t, err)
+	assert.Equal(t, "HI\nhi", sig.Result.String())
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestSimpleJoinNode_IncompleteRunEnds(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	src, join := newDiamond(mgr)
+	join.SetUpstream("upper", "lower", "missing")
+
+	// Without a wait timeout the run ends once the branches have finished
+	h, err := nlib.StartRun(context.Background(), src, node.Signal{Task: nlib.NewTextCarrier("Hi")})
+	assert.NoError(t, err)
+	select {
+	case <-h.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not end")
+	}
+	assert.Equal(t, nlib.StatusFail, h.Status())
+	assert.Empty(t, h.Results())
+	_, err = h.Result()
+	assert.ErrorContains(t, err, "without reaching a terminal node")
+}
+
+func TestSimpleJoinNode_NotConfigured(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	src, _ := newDiamond(mgr)
+
+	_, err := nlib.Run(context.Background(), src, node.Signal{Task: nlib.NewTextCarrier("Hi")})
+	assert.ErrorContains(t, err, "no upstream nodes or count")
+	assert.ErrorContains(t, nlib.Validate(src), "join: join node has no upstream nodes or count")
+}
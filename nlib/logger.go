@@ -1,25 +1,39 @@
 package nlib
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"log"
+	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/dshills/wiggle/node"
 )
 
-// Ensure that SimpleLogger implements the node.Logger interface
+// Ensure that the loggers implement the node.Logger and node.StructuredLogger interfaces
 var _ node.Logger = (*SimpleLogger)(nil)
 var _ node.Logger = (*NoLogger)(nil)
+var _ node.Logger = (*SlogLogger)(nil)
+var _ node.StructuredLogger = (*SimpleLogger)(nil)
+var _ node.StructuredLogger = (*NoLogger)(nil)
+var _ node.StructuredLogger = (*SlogLogger)(nil)
+var _ node.StructuredLogger = (*LoggerAdapter)(nil)
 
-// SimpleLogger is a basic implementation of the node.Logger interface.
+// SimpleLogger is a basic implementation of the node.Logger and node.StructuredLogger interfaces.
 // It wraps the standard library's log.Logger to provide logging functionality for nodes.
+// Structured entries are written as a line of JSON.
 type SimpleLogger struct {
-	l *log.Logger // The standard library logger used for logging messages
+	l     *log.Logger // The standard library logger used for logging messages
+	level node.Level  // Entries below this level are skipped
 }
 
 // NewSimpleLogger creates a new instance of SimpleLogger with the provided log.Logger.
 // This is a constructor function that returns a pointer to the newly created SimpleLogger.
+// It logs entries at node.LevelInfo and above; use SetLevel to change that.
 func NewSimpleLogger(l *log.Logger) *SimpleLogger {
-	return &SimpleLogger{l: l}
+	return &SimpleLogger{l: l, level: node.LevelInfo}
 }
 
 // Log logs the provided message using the underlying log.Logger.
@@ -28,6 +42,23 @@ func (l *SimpleLogger) Log(msg string) {
 	l.l.Println(msg) // Log the message using the standard logger
 }
 
+// SetLevel sets the lowest level logged
+func (l *SimpleLogger) SetLevel(level node.Level) {
+	l.level = level
+}
+
+// Enabled reports whether entries at the level are logged
+func (l *SimpleLogger) Enabled(level node.Level) bool {
+	return level >= l.level
+}
+
+// LogAt logs an entry as a line of JSON
+func (l *SimpleLogger) LogAt(level node.Level, msg string, fields ...any) {
+	if l.Enabled(level) {
+		l.l.Println(formatEntry(level, msg, fields))
+	}
+}
+
 type NoLogger struct{}
 
 func NewNoLogger() *NoLogger {
@@ -37,3 +68,132 @@ func NewNoLogger() *NoLogger {
 func (l *NoLogger) Log(_ string) {
 	// NOOP
 }
+
+// Enabled always returns false
+func (l *NoLogger) Enabled(_ node.Level) bool {
+	return false
+}
+
+func (l *NoLogger) LogAt(_ node.Level, _ string, _ ...any) {
+	// NOOP
+}
+
+// SlogLogger writes log entries to a log/slog Logger. Fields become slog attributes, so
+// entries can be filtered by level and fields extracted by any slog.Handler.
+type SlogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger creates a SlogLogger writing to l, or to slog.Default() if l is nil
+func NewSlogLogger(l *slog.Logger) *SlogLogger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &SlogLogger{l: l}
+}
+
+// Log logs the message at info level
+func (l *SlogLogger) Log(msg string) {
+	l.l.Info(msg)
+}
+
+// Enabled reports whether the slog handler logs entries at the level
+func (l *SlogLogger) Enabled(level node.Level) bool {
+	return l.l.Enabled(context.Background(), slog.Level(level))
+}
+
+// LogAt logs an entry with the fields as slog attributes
+func (l *SlogLogger) LogAt(level node.Level, msg string, fields ...any) {
+	l.l.Log(context.Background(), slog.Level(level), msg, fields...)
+}
+
+// LoggerAdapter writes structured entries to a node.Logger as lines of JSON, so loggers
+// written for the original Log(string) interface keep working
+type LoggerAdapter struct {
+	l     node.Logger
+	level node.Level // Entries below this level are skipped
+}
+
+// NewLoggerAdapter creates a LoggerAdapter writing entries at level and above to l
+func NewLoggerAdapter(l node.Logger, level node.Level) *LoggerAdapter {
+	return &LoggerAdapter{l: l, level: level}
+}
+
+// Enabled reports whether entries at the level are logged
+func (a *LoggerAdapter) Enabled(level node.Level) bool {
+	return level >= a.level
+}
+
+// LogAt logs an entry as a line of JSON
+func (a *LoggerAdapter) LogAt(level node.Level, msg string, fields ...any) {
+	if a.Enabled(level) {
+		a.l.Log(formatEntry(level, msg, fields))
+	}
+}
+
+// structuredLogger returns l as a node.StructuredLogger, adapting loggers that only log strings
+func structuredLogger(l node.Logger) node.StructuredLogger {
+	if l == nil {
+		return nil
+	}
+	if sl, ok := l.(node.StructuredLogger); ok {
+		return sl
+	}
+	return NewLoggerAdapter(l, node.LevelDebug)
+}
+
+// logAt writes a leveled entry through the StateManager, formatting it as JSON for
+// StateManagers that only log strings
+func logAt(sm node.StateManager, level node.Level, msg string, fields ...any) {
+	if lm, ok := sm.(node.StructuredLogStateManager); ok {
+		lm.LogAt(level, msg, fields...)
+		return
+	}
+	sm.Log(formatEntry(level, msg, fields))
+}
+
+// messageLogger logs the messages passed to the node.Logger interface at info level
+type messageLogger struct {
+	l node.StructuredLogger
+}
+
+func (m messageLogger) Log(msg string) {
+	m.l.LogAt(node.LevelInfo, msg)
+}
+
+// formatEntry formats a log entry as a JSON object with the level and message first
+// followed by the fields in order
+func formatEntry(level node.Level, msg string, fields []any) string {
+	b := strings.Builder{}
+	b.WriteString(`{"level":"` + level.String() + `","msg":`)
+	b.WriteString(jsonValue(msg))
+	for i := 0; i < len(fields); i++ {
+		key, ok := fields[i].(string)
+		if !ok || i+1 == len(fields) {
+			key = "!BADKEY"
+		} else {
+			i++
+		}
+		b.WriteString("," + jsonValue(key) + ":" + jsonValue(fields[i]))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// jsonValue encodes a field value as JSON. Errors and durations are written as strings.
+func jsonValue(v any) string {
+	switch t := v.(type) {
+	case error:
+		v = t.Error()
+	case time.Duration:
+		v = t.String()
+	case time.Time:
+	case fmt.Stringer:
+		v = t.String()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return string(data)
+}
@@ -0,0 +1,98 @@
+package nlib_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+)
+
+// lineLogger collects the messages of the original node.Logger interface
+type lineLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *lineLogger) Log(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, msg)
+}
+
+func TestSimpleLogger_LogAt(t *testing.T) {
+	buf := bytes.Buffer{}
+	l := nlib.NewSimpleLogger(log.New(&buf, "", 0))
+	l.LogAt(node.LevelDebug, "hidden")
+	l.LogAt(node.LevelError, "failed", node.LogKeyNodeID, "a", node.LogKeyError, errors.New("boom"), "odd")
+	assert.Equal(t, `{"level":"error","msg":"failed","node_id":"a","error":"boom","!BADKEY":"odd"}`+"\n", buf.String())
+
+	buf.Reset()
+	l.SetLevel(node.LevelDebug)
+	l.LogAt(node.LevelDebug, "shown")
+	assert.Contains(t, buf.String(), `"msg":"shown"`)
+}
+
+func TestSlogLogger_RunFields(t *testing.T) {
+	buf := bytes.Buffer{}
+	mgr := nlib.NewSimpleStateManager(nil)
+	mgr.SetStructuredLogger(nlib.NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	after := func(sig node.Signal) (node.Signal, error) { return sig, errors.New("boom") }
+	n := nlib.NewSimpleBranchNode(mgr, node.Options{ID: "broken", Hooks: nlib.NewSimpleNodeHooks(nil, after)})
+
+	sig, err := nlib.Run(context.Background(), n, node.Signal{Task: nlib.NewTextCarrier("hello")})
+	assert.Error(t, err)
+
+	var failed map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		entry := map[string]any{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+		if entry["msg"] == "Signal failed" {
+			failed = entry
+		}
+	}
+	if assert.NotNil(t, failed) {
+		assert.Equal(t, "ERROR", failed["level"])
+		assert.Equal(t, "broken", failed[node.LogKeyNodeID])
+		assert.Equal(t, sig.RunID, failed[node.LogKeyRunID])
+		assert.Equal(t, sig.ID, failed[node.LogKeySignalID])
+		assert.Equal(t, "boom", failed[node.LogKeyError])
+	}
+}
+
+func TestStateManager_LoggerAdapters(t *testing.T) {
+	// A logger of the original interface receives entries formatted as JSON
+	lines := &lineLogger{}
+	mgr := nlib.NewSimpleStateManager(lines)
+	mgr.LogAt(node.LevelDebug, "entry", node.LogKeyRunID, "r1")
+	mgr.Log("message")
+	assert.Equal(t, []string{`{"level":"debug","msg":"entry","run_id":"r1"}`, "message"}, lines.lines)
+
+	// A structured logger receives the messages passed to Log at info level
+	buf := bytes.Buffer{}
+	mgr.SetStructuredLogger(nlib.NewSlogLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	mgr.Log("message")
+	mgr.LogAt(node.LevelDebug, "filtered")
+	assert.Contains(t, buf.String(), "level=INFO msg=message")
+	assert.NotContains(t, buf.String(), "filtered")
+	assert.NotNil(t, mgr.Logger())
+}
+
+func TestEmptyNode_LogWithoutStructuredLogger(t *testing.T) {
+	// A StateManager that only logs strings receives the entries of a node formatted as JSON
+	lines := &lineLogger{}
+	mgr := struct{ node.StateManager }{nlib.NewSimpleStateManager(lines)}
+	n := &nlib.EmptyNode{}
+	n.SetID("a")
+	n.SetStateManager(mgr)
+	n.LogInfo("hello")
+	assert.Equal(t, []string{`{"level":"info","msg":"hello","node_id":"a"}`}, lines.lines)
+}
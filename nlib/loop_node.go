@@ -1,9 +1,6 @@
 package nlib
 
 import (
-	"context"
-	"fmt"
-
 	"github.com/dshills/wiggle/node"
 )
 
@@ -30,20 +27,7 @@ func NewSimpleLoopNode(start node.Node, condFn node.ConditionFn, mgr node.StateM
 	n.SetOptions(options)
 	n.SetStateManager(mgr)
 	n.MakeInputCh()
-
-	// Goroutine to listen for incoming signals and process them.
-	go func() {
-		for {
-			select {
-			case sig := <-n.InputCh():
-				n.LogInfo("Received signal")
-				n.processSignal(sig)
-			case <-n.StateManager().Register():
-				n.LogInfo("Received Done")
-				return
-			}
-		}
-	}()
+	n.SetProcessFunc(n.processSignal)
 
 	return &n
 }
@@ -52,37 +36,43 @@ func NewSimpleLoopNode(start node.Node, condFn node.ConditionFn, mgr node.StateM
 // it back to the start node or forwards it to the connected nodes.
 func (n *SimpleLoopNode) processSignal(sig node.Signal) {
 	var err error
+	ctx, done := n.BeginSignal(sig)
+	defer done()
 
-	sig, err = n.PreProcessSignal(sig)
+	sig, err = n.PreProcessSignal(ctx, sig)
 	if err != nil {
 		n.Fail(sig, err)
 		return
 	}
 
-	// No specific processing here, but post-processing is handled next.
-	sig, err = n.PostProcessSignal(sig)
+	// No specific processing here, the signal is complete once the hooks have run.
+	sig.Status = StatusSuccess
+	sig, err = n.PostProcessSignal(ctx, sig)
 	if err != nil {
 		n.Fail(sig, err)
 		return
 	}
-	sig.Status = StatusInProcess
 
 	// Check if the condition is met (if condFn is not nil).
 	if n.condFn == nil || !n.condFn(sig) {
 		if n.startNode != nil {
-			n.LogInfo(fmt.Sprintf("Sending to %s", n.startNode.ID()))
-			newSig := NewSignalFromSignal(n.startNode.ID(), n.ID(), sig)
-			n.startNode.InputCh() <- newSig
+			if err := n.SendToNode(ctx, n.startNode, sig); err != nil {
+				n.Fail(sig, err)
+				return
+			}
 		}
 	}
-	sig.Status = StatusSuccess
-
-	if err := n.SendToConnected(context.TODO(), sig); err != nil {
+	if err := n.SendToConnected(ctx, sig); err != nil {
 		n.Fail(sig, err)
 		return
 	}
 }
 
+// StartNode returns the node signals are looped back to.
+func (n *SimpleLoopNode) StartNode() node.Node {
+	return n.startNode
+}
+
 // SetStartNode sets the start node where the signal will be looped back to for re-processing.
 func (n *SimpleLoopNode) SetStartNode(start node.Node) {
 	n.startNode = start
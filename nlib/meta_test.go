@@ -0,0 +1,142 @@
+package nlib_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+)
+
+// newMetaNode returns a node that passes on its task after changing its metadata with fn
+func newMetaNode(mgr node.StateManager, id string, fn func(node.Metadata) node.Metadata) node.Node {
+	after := func(sig node.Signal) (node.Signal, error) {
+		sig.Result = sig.Task
+		sig.Meta = fn(sig.Meta)
+		return sig, nil
+	}
+	return nlib.NewSimpleBranchNode(mgr, node.Options{ID: id, Hooks: nlib.NewSimpleNodeHooks(nil, after)})
+}
+
+func TestMetadata_Typed(t *testing.T) {
+	now := time.Date(2024, 10, 1, 12, 30, 0, 5, time.UTC)
+	md := node.Metadata{}.
+		SetString("lang", "en").
+		SetInt("attempt", 2).
+		SetFloat("temperature", 0.7).
+		SetBool("draft", true).
+		SetTime("deadline", now)
+	md, err := md.SetJSON("user", map[string]string{"name": "ada"})
+	assert.NoError(t, err)
+
+	// The types survive encoding the signal
+	data, err := json.Marshal(node.Signal{Meta: md})
+	assert.NoError(t, err)
+	var sig node.Signal
+	assert.NoError(t, json.Unmarshal(data, &sig))
+	md = sig.Meta
+
+	s, _ := md.GetString("lang")
+	i, _ := md.GetInt("attempt")
+	f, _ := md.GetFloat("temperature")
+	b, _ := md.GetBool("draft")
+	tm, _ := md.GetTime("deadline")
+	user := map[string]string{}
+	assert.NoError(t, md.GetJSON("user", &user))
+	assert.Equal(t, "en", s)
+	assert.Equal(t, 2, i)
+	assert.Equal(t, 0.7, f)
+	assert.True(t, b)
+	assert.True(t, now.Equal(tm))
+	assert.Equal(t, "ada", user["name"])
+	assert.Equal(t, map[string]any{"name": "ada"}, md.Map()["user"])
+
+	_, ok := md.GetInt("lang")
+	assert.False(t, ok)
+	_, ok = md.GetBool("missing")
+	assert.False(t, ok)
+	assert.Error(t, md.GetJSON("missing", &user))
+
+	// Setting a key replaces its value unless the key accumulates
+	md = md.SetInt("attempt", 3).Set(node.StringMeta("tag", "a").WithRule(node.MetaAccumulate)).SetString("tag", "b")
+	assert.Len(t, md.Values("attempt"), 1)
+	assert.Equal(t, []node.Meta{
+		{Key: "tag", Value: "a", Type: node.MetaString, Rule: node.MetaAccumulate},
+		{Key: "tag", Value: "b", Type: node.MetaString, Rule: node.MetaAccumulate},
+	}, md.Values("tag"))
+}
+
+func TestMetadata_CopyOnWrite(t *testing.T) {
+	base := make(node.Metadata, 0, 10).SetString("lang", "en")
+	a := base.SetString("lang", "fr")
+	b := base.Add(node.StringMeta("extra", "b"))
+	c := base.Add(node.StringMeta("extra", "c"))
+
+	lang, _ := base.GetString("lang")
+	assert.Equal(t, "en", lang)
+	lang, _ = a.GetString("lang")
+	assert.Equal(t, "fr", lang)
+	extra, _ := b.GetString("extra")
+	assert.Equal(t, "b", extra)
+	extra, _ = c.GetString("extra")
+	assert.Equal(t, "c", extra)
+	assert.Len(t, base, 1)
+}
+
+func TestMetadata_Propagation(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	src := newMetaNode(mgr, "src", func(md node.Metadata) node.Metadata {
+		return md.
+			Set(node.IntMeta("attempt", 1).WithRule(node.MetaDrop)).
+			Set(node.StringMeta("visited", "src").WithRule(node.MetaAccumulate)).
+			SetString("lang", "en")
+	})
+	upper := newMetaNode(mgr, "upper", func(md node.Metadata) node.Metadata {
+		_, dropped := md.Get("attempt")
+		assert.False(t, dropped)
+		return md.SetString("visited", "upper").SetString("lang", "fr")
+	})
+	lower := newMetaNode(mgr, "lower", func(md node.Metadata) node.Metadata {
+		return md.SetString("visited", "lower")
+	})
+	join := nlib.NewSimpleJoinNode(nil, mgr, node.Options{ID: "join"})
+	join.SetUpstream("upper", "lower")
+	src.Connect(upper, lower)
+	upper.Connect(join)
+	lower.Connect(join)
+
+	sig, err := nlib.Run(context.Background(), src, node.Signal{Task: nlib.NewTextCarrier("hello")})
+	assert.NoError(t, err)
+	_, ok := sig.Meta.Get("attempt")
+	assert.False(t, ok)
+	visited := []string{}
+	for _, m := range sig.Meta.Values("visited") {
+		visited = append(visited, m.Value)
+	}
+	assert.Equal(t, []string{"src", "upper", "lower"}, visited)
+
+	// Inherited keys keep the value of the last upstream node, and the branches did
+	// not see each other's changes
+	lang, _ := sig.Meta.GetString("lang")
+	assert.Equal(t, "en", lang)
+}
+
+func TestSimpleGuidance_Meta(t *testing.T) {
+	g := nlib.NewSimpleGuidance()
+	g.Template = `Answer in {{ .Meta.lang }} within {{ .Meta.words }} words: {{ .Input }}{{ range .MetaAll.tag }} #{{ . }}{{ end }}`
+	md := node.Metadata{}.SetString("lang", "French").SetInt("words", 50).
+		Add(node.StringMeta("tag", "a")).Add(node.StringMeta("tag", "b"))
+
+	sig, err := g.Generate(node.Signal{Task: nlib.NewTextCarrier("hello"), Meta: md}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "Answer in French within 50 words: hello #a #b", sig.Task.String())
+
+	g.Template = ""
+	sig, err = g.Generate(node.Signal{Task: nlib.NewTextCarrier("hello"), Meta: md}, "")
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(sig.Task.String(), "<input>"))
+}
@@ -0,0 +1,280 @@
+package nlib
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dshills/wiggle/node"
+)
+
+// Metrics recorded by the nodes when the StateManager has a node.Metrics
+const (
+	MetricSignalsProcessed = "wiggle_signals_processed_total"      // Counter of signals processed, by node
+	MetricSignalsFailed    = "wiggle_signals_failed_total"         // Counter of signals failed, by node
+	MetricSignalDuration   = "wiggle_signal_duration_seconds"      // Histogram of the time spent processing a signal, by node
+	MetricQueueDepth       = "wiggle_queue_depth"                  // Gauge of the signals waiting in a node's input channel, by node
+	MetricRateLimitWaits   = "wiggle_rate_limit_waits_total"       // Counter of waits for the ResourceManager's rate limit, by node
+	MetricRateLimitWait    = "wiggle_rate_limit_wait_seconds"      // Histogram of the time spent waiting for the rate limit, by node
+	MetricLLMRequests      = "wiggle_llm_requests_total"           // Counter of LLM requests, by provider and model
+	MetricLLMErrors        = "wiggle_llm_request_errors_total"     // Counter of failed LLM requests, by provider and model
+	MetricLLMDuration      = "wiggle_llm_request_duration_seconds" // Histogram of LLM request latency, by provider and model
+	MetricLLMTokens        = "wiggle_llm_tokens_total"             // Counter of tokens used by LLM requests, by provider, model and type (prompt or completion)
+)
+
+// metricHelp describes the metrics recorded by the nodes
+var metricHelp = map[string]string{
+	MetricSignalsProcessed: "Signals processed by each node.",
+	MetricSignalsFailed:    "Signals failed by each node.",
+	MetricSignalDuration:   "Time spent processing a signal in seconds.",
+	MetricQueueDepth:       "Signals waiting in the input channel of each node.",
+	MetricRateLimitWaits:   "Waits for the rate limit of the ResourceManager.",
+	MetricRateLimitWait:    "Time spent waiting for the rate limit in seconds.",
+	MetricLLMRequests:      "Requests sent to an LLM.",
+	MetricLLMErrors:        "Requests to an LLM that failed.",
+	MetricLLMDuration:      "Latency of LLM requests in seconds.",
+	MetricLLMTokens:        "Tokens used by LLM requests.",
+}
+
+// stateMetrics returns the Metrics of the StateManager, nil if it does not collect metrics
+func stateMetrics(sm node.StateManager) node.Metrics {
+	if mm, ok := sm.(node.MetricsStateManager); ok {
+		return mm.Metrics()
+	}
+	return nil
+}
+
+// addCounter increases a counter if metrics are on
+func addCounter(m node.Metrics, name string, labels map[string]string, delta float64) {
+	if m != nil {
+		m.AddCounter(name, labels, delta)
+	}
+}
+
+// setGauge sets a gauge if metrics are on
+func setGauge(m node.Metrics, name string, labels map[string]string, value float64) {
+	if m != nil {
+		m.SetGauge(name, labels, value)
+	}
+}
+
+// observeHistogram records a histogram sample if metrics are on
+func observeHistogram(m node.Metrics, name string, labels map[string]string, value float64) {
+	if m != nil {
+		m.ObserveHistogram(name, labels, value)
+	}
+}
+
+// Compile-time check to ensure PrometheusMetrics implements the node.Metrics and http.Handler interfaces
+var _ node.Metrics = (*PrometheusMetrics)(nil)
+var _ http.Handler = (*PrometheusMetrics)(nil)
+
+// DefaultBuckets are the upper bounds in seconds of the histogram buckets used by
+// NewPrometheusMetrics. They cover fast local nodes up to slow LLM requests.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// PrometheusMetrics keeps metrics in memory and serves them over HTTP in the Prometheus
+// text exposition format, so a Prometheus server can scrape them:
+//
+//	metrics := nlib.NewPrometheusMetrics()
+//	stateMgr.SetMetrics(metrics)
+//	http.Handle("/metrics", metrics)
+type PrometheusMetrics struct {
+	mu       sync.Mutex
+	buckets  []float64
+	families map[string]*metricFamily
+}
+
+// metricFamily holds the series of a metric
+type metricFamily struct {
+	kind   string // counter, gauge or histogram
+	series map[string]*metricSeries
+}
+
+// metricSeries is a metric for one set of labels
+type metricSeries struct {
+	labels string    // Formatted labels, for example {node="a"}
+	value  float64   // Value of a counter or gauge, sum of a histogram
+	counts []uint64  // Samples in each histogram bucket, not cumulative
+	count  uint64    // Samples in a histogram
+	bounds []float64 // Upper bounds of the histogram buckets
+}
+
+// NewPrometheusMetrics creates an empty PrometheusMetrics using DefaultBuckets for histograms
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return NewPrometheusMetricsWithBuckets(DefaultBuckets)
+}
+
+// NewPrometheusMetricsWithBuckets creates an empty PrometheusMetrics using the given
+// upper bounds for histogram buckets
+func NewPrometheusMetricsWithBuckets(buckets []float64) *PrometheusMetrics {
+	b := append([]float64{}, buckets...)
+	sort.Float64s(b)
+	return &PrometheusMetrics{buckets: b, families: make(map[string]*metricFamily)}
+}
+
+// AddCounter increases a counter
+func (m *PrometheusMetrics) AddCounter(name string, labels map[string]string, delta float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seriesFor("counter", name, labels).value += delta
+}
+
+// SetGauge sets the current value of a gauge
+func (m *PrometheusMetrics) SetGauge(name string, labels map[string]string, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seriesFor("gauge", name, labels).value = value
+}
+
+// ObserveHistogram records a sample in a histogram
+func (m *PrometheusMetrics) ObserveHistogram(name string, labels map[string]string, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.seriesFor("histogram", name, labels)
+	if s.counts == nil {
+		s.bounds = m.buckets
+		s.counts = make([]uint64, len(m.buckets))
+	}
+	if i := sort.SearchFloat64s(s.bounds, value); i < len(s.bounds) {
+		s.counts[i]++
+	}
+	s.value += value
+	s.count++
+}
+
+// Value returns the value of a counter or gauge, or the sum of a histogram's samples
+func (m *PrometheusMetrics) Value(name string, labels map[string]string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f, ok := m.families[name]; ok {
+		if s, ok := f.series[formatLabels(labels)]; ok {
+			return s.value
+		}
+	}
+	return 0
+}
+
+// Count returns the number of samples recorded in a histogram
+func (m *PrometheusMetrics) Count(name string, labels map[string]string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f, ok := m.families[name]; ok {
+		if s, ok := f.series[formatLabels(labels)]; ok {
+			return s.count
+		}
+	}
+	return 0
+}
+
+// seriesFor returns the series of a metric, creating it if needed. The caller must hold the lock.
+func (m *PrometheusMetrics) seriesFor(kind, name string, labels map[string]string) *metricSeries {
+	f, ok := m.families[name]
+	if !ok {
+		f = &metricFamily{kind: kind, series: make(map[string]*metricSeries)}
+		m.families[name] = f
+	}
+	key := formatLabels(labels)
+	s, ok := f.series[key]
+	if !ok {
+		s = &metricSeries{labels: key}
+		f.series[key] = s
+	}
+	return s
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := m.WriteText(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// WriteText writes the metrics in the Prometheus text exposition format
+func (m *PrometheusMetrics) WriteText(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := strings.Builder{}
+	for _, name := range names {
+		f := m.families[name]
+		if help, ok := metricHelp[name]; ok {
+			fmt.Fprintf(&b, "# HELP %s %s\n", name, help)
+		}
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, f.kind)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := f.series[key]
+			if f.kind != "histogram" {
+				fmt.Fprintf(&b, "%s%s %s\n", name, s.labels, formatFloat(s.value))
+				continue
+			}
+			var cumulative uint64
+			for i, bound := range s.bounds {
+				cumulative += s.counts[i]
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", name, withLabel(s.labels, "le", formatFloat(bound)), cumulative)
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", name, withLabel(s.labels, "le", "+Inf"), s.count)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", name, s.labels, formatFloat(s.value))
+			fmt.Fprintf(&b, "%s_count%s %d\n", name, s.labels, s.count)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// formatLabels formats labels sorted by name, for example {model="x",provider="y"}
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, labelEscaper.Replace(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// withLabel adds a label to formatted labels
+func withLabel(labels, name, value string) string {
+	pair := fmt.Sprintf("%s=\"%s\"", name, value)
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+// labelEscaper escapes label values for the text exposition format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,69 @@
+package nlib_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics_Run(t *testing.T) {
+	metrics := nlib.NewPrometheusMetrics()
+	mgr := nlib.NewSimpleStateManager(nil)
+	mgr.SetMetrics(metrics)
+	ai := nlib.NewAINode(newReplyLLM("HELLO", nil), mgr, node.Options{ID: "ai"})
+	ai.Connect(newTransformNode(mgr, "punctuate", func(s string) string { return s + "!" }))
+
+	for i := 0; i < 2; i++ {
+		_, err := nlib.Run(context.Background(), ai, node.Signal{Task: nlib.NewTextCarrier("hello")})
+		assert.NoError(t, err)
+	}
+	broken := newReplyLLM("", errors.New("unavailable"))
+	_, err := nlib.Run(context.Background(), nlib.NewAINode(broken, mgr, node.Options{ID: "broken"}), node.Signal{Task: nlib.NewTextCarrier("hello")})
+	assert.Error(t, err)
+
+	llmLabels := map[string]string{"provider": "nmock", "model": "mock"}
+	assert.Equal(t, 2.0, metrics.Value(nlib.MetricSignalsProcessed, map[string]string{"node": "ai"}))
+	assert.Equal(t, 2.0, metrics.Value(nlib.MetricSignalsProcessed, map[string]string{"node": "punctuate"}))
+	assert.Equal(t, uint64(2), metrics.Count(nlib.MetricSignalDuration, map[string]string{"node": "punctuate"}))
+	assert.Equal(t, 1.0, metrics.Value(nlib.MetricSignalsFailed, map[string]string{"node": "broken"}))
+	assert.Equal(t, 0.0, metrics.Value(nlib.MetricSignalsFailed, map[string]string{"node": "ai"}))
+	assert.Equal(t, 3.0, metrics.Value(nlib.MetricLLMRequests, llmLabels))
+	assert.Equal(t, 1.0, metrics.Value(nlib.MetricLLMErrors, llmLabels))
+	assert.Equal(t, uint64(3), metrics.Count(nlib.MetricLLMDuration, llmLabels))
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, body, "# TYPE wiggle_signals_processed_total counter\n")
+	assert.Contains(t, body, `wiggle_llm_requests_total{model="mock",provider="nmock"} 3`)
+	assert.Contains(t, body, `wiggle_queue_depth{node="punctuate"} 0`)
+}
+
+func TestPrometheusMetrics_Text(t *testing.T) {
+	metrics := nlib.NewPrometheusMetricsWithBuckets([]float64{1, 0.1})
+	labels := map[string]string{"node": `say "hi"` + "\n"}
+	metrics.ObserveHistogram("latency_seconds", labels, 0.05)
+	metrics.ObserveHistogram("latency_seconds", labels, 0.5)
+	metrics.ObserveHistogram("latency_seconds", labels, 5)
+	metrics.SetGauge("depth", nil, 3)
+	metrics.SetGauge("depth", nil, 2)
+
+	b := strings.Builder{}
+	assert.NoError(t, metrics.WriteText(&b))
+	assert.Equal(t, `# TYPE depth gauge
+depth 2
+# TYPE latency_seconds histogram
+latency_seconds_bucket{node="say \"hi\"\n",le="0.1"} 1
+latency_seconds_bucket{node="say \"hi\"\n",le="1"} 2
+latency_seconds_bucket{node="say \"hi\"\n",le="+Inf"} 3
+latency_seconds_sum{node="say \"hi\"\n"} 5.55
+latency_seconds_count{node="say \"hi\"\n"} 3
+`, b.String())
+}
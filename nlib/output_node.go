@@ -1,7 +1,6 @@
 package nlib
 
 import (
-	"context"
 	"io"
 
 	"github.com/dshills/wiggle/node"
@@ -18,54 +17,47 @@ type OutputStringNode struct {
 }
 
 // NewOutputStringNode creates a new OutputStringNode with the specified writer, logger, state manager, and name.
-// It starts a goroutine to listen for incoming signals and write their data to the writer.
+// Once started it writes the data of incoming signals to the writer.
 func NewOutputStringNode(w io.Writer, mgr node.StateManager, options node.Options) *OutputStringNode {
 	n := OutputStringNode{writer: w}
 	n.SetOptions(options)
 	n.SetStateManager(mgr)
 	n.MakeInputCh()
+	n.SetProcessFunc(n.processSignal)
 
-	// Goroutine to listen for incoming signals and process them.
-	go func() {
-		var err error
-		for {
-			select {
-			case sig := <-n.InputCh(): // Receive a signal from the input channel.
-				n.LogInfo("Received Signal")
-				sig, err = n.PreProcessSignal(sig)
-				if err != nil {
-					n.Fail(sig, err)
-					return
-				}
+	return &n
+}
 
-				sig.Status = StatusInProcess
-				// Write the signal's data (response) to the provided writer.
-				_, err := n.writer.Write([]byte(sig.Task.String() + "\n"))
-				if err != nil {
-					n.Fail(sig, err)
-					return
-				}
-				sig.Status = StatusSuccess
+// processSignal writes the signal's task data to the writer and forwards the signal to connected nodes.
+func (n *OutputStringNode) processSignal(sig node.Signal) {
+	var err error
+	ctx, done := n.BeginSignal(sig)
+	defer done()
 
-				sig, err = n.PostProcessSignal(sig)
-				if err != nil {
-					n.Fail(sig, err)
-					return
-				}
+	sig, err = n.PreProcessSignal(ctx, sig)
+	if err != nil {
+		n.Fail(sig, err)
+		return
+	}
 
-				if err := n.SendToConnected(context.TODO(), sig); err != nil {
-					n.Fail(sig, err)
-					return
-				}
+	sig.Status = StatusInProcess
+	// Write the signal's data (response) to the provided writer.
+	if _, err := n.writer.Write([]byte(sig.Task.String() + "\n")); err != nil {
+		n.Fail(sig, err)
+		return
+	}
+	sig.Status = StatusSuccess
 
-			case <-n.StateManager().Register():
-				n.LogInfo("Received done")
-				return
-			}
-		}
-	}()
+	sig, err = n.PostProcessSignal(ctx, sig)
+	if err != nil {
+		n.Fail(sig, err)
+		return
+	}
 
-	return &n
+	if err := n.SendToConnected(ctx, sig); err != nil {
+		n.Fail(sig, err)
+		return
+	}
 }
 
 func (n *OutputStringNode) SetWriter(w io.Writer) {
@@ -18,35 +18,22 @@ type SimplePartitionerNode struct {
 	EmptyNode                          // Embeds the base functionality of an EmptyNode
 	partitionFunc   node.PartitionerFn // Function for partitioning signal data
 	integrationFunc node.IntegratorFn  // Function for integrating the partitioned results
-	factory         node.Factory       // Factory function to create nodes for processing partitions
+	factory         node.FactoryFn     // Factory function to create nodes for processing partitions
 }
 
 // NewSimplePartitionerNode creates a new SimplePartitionerNode. It sets the partition, integration,
-// and factory functions, as well as the state manager and options. The node listens for incoming signals,
+// and factory functions, as well as the state manager and options. Once started the node listens for incoming signals,
 // partitions the signal's data, processes the partitions, and integrates the results.
 func NewSimplePartitionerNode(pfn node.PartitionerFn, ifn node.IntegratorFn, fac node.Factory, mgr node.StateManager, options node.Options) *SimplePartitionerNode {
 	n := SimplePartitionerNode{
-		partitionFunc:   pfn, // Set the partition function
-		integrationFunc: ifn, // Set the integration function
-		factory:         fac, // Set the factory function
+		partitionFunc:   pfn,            // Set the partition function
+		integrationFunc: ifn,            // Set the integration function
+		factory:         factoryFn(fac), // Set the factory function
 	}
 	n.SetOptions(options)
 	n.SetStateManager(mgr)
 	n.MakeInputCh()
-
-	// Start a goroutine to listen for signals and process them
-	go func() {
-		for {
-			select {
-			case sig := <-n.InputCh():
-				n.LogInfo("Received Signal")
-				n.processSignal(sig) // Process the signal
-			case <-n.StateManager().Register():
-				n.LogInfo("Received Done")
-				return // Terminate when done
-			}
-		}
-	}()
+	n.SetProcessFunc(n.processSignal)
 
 	return &n
 }
@@ -66,14 +53,36 @@ func (n *SimplePartitionerNode) SetIntegrationFunc(integratonFunc node.Integrato
 // SetNodeFactory updates the factory function used by the node.
 // The factory is responsible for creating nodes that will process the partitioned data.
 func (n *SimplePartitionerNode) SetNodeFactory(factory node.Factory) {
+	n.factory = factoryFn(factory)
+}
+
+// SetNodeFactoryFn updates the factory function used by the node to one that can fail.
+// The signal fails with the factory's error.
+func (n *SimplePartitionerNode) SetNodeFactoryFn(factory node.FactoryFn) {
 	n.factory = factory
 }
 
+// factoryFn adapts a node.Factory that cannot fail to a node.FactoryFn
+func factoryFn(factory node.Factory) node.FactoryFn {
+	if factory == nil {
+		return nil
+	}
+	return func(count int) ([]node.Node, error) { return factory(count), nil }
+}
+
+// partitionIndexKey is the context key of the index of the partition a signal was sent for by
+// the partitioner owning it. The owner keeps the partitions of nested partitioners apart.
+type partitionIndexKey struct {
+	owner *SimplePartitionerNode
+}
+
 // processSignal handles the signal processing for the SimplePartitionerNode. It first applies signal preprocessing,
 // then partitions the signal's data, creates new nodes to process the partitions, and integrates the results.
 // If any error occurs, the signal is marked as failed. Otherwise, the final integrated result is sent to connected nodes.
 func (n *SimplePartitionerNode) processSignal(sig node.Signal) {
 	var err error
+	ctx, done := n.BeginSignal(sig)
+	defer done()
 
 	// Ensure that the required functions (partition, integration, and factory) are set
 	if n.partitionFunc == nil || n.factory == nil || n.integrationFunc == nil {
@@ -83,7 +92,7 @@ func (n *SimplePartitionerNode) processSignal(sig node.Signal) {
 	}
 
 	// Preprocess the signal
-	sig, err = n.PreProcessSignal(sig)
+	sig, err = n.PreProcessSignal(ctx, sig)
 	if err != nil {
 		n.Fail(sig, err)
 		return
@@ -94,29 +103,84 @@ func (n *SimplePartitionerNode) processSignal(sig node.Signal) {
 	// Partition the signal's task data into smaller parts
 	parts, err := n.partitionFunc(sig.Task.String())
 	if err != nil {
-		n.LogErr(err)
-		sig.Err = err.Error()
+		n.Fail(sig, fmt.Errorf("partitioning failed: %w", err))
 		return
 	}
 
 	// Create a set of nodes to process the partitioned data
-	nodes := n.factory(len(parts))
+	nodes, err := n.factory(len(parts))
+	if err != nil {
+		n.Fail(sig, fmt.Errorf("creating partition nodes: %w", err))
+		return
+	}
+	if len(nodes) < len(parts) {
+		n.Fail(sig, fmt.Errorf("node factory returned %d nodes for %d partitions", len(nodes), len(parts)))
+		return
+	}
+	subGraph := NewGraph(nodes...)
+	subGraph.Start(ctx)
+	defer subGraph.Stop()
 	respChan := make(chan node.Signal, len(parts)) // Channel to collect responses from the nodes
 	emptyNode := &EmptyNode{inputCh: respChan}     // Empty node to gather results
+	failures := make(chan node.Signal, len(parts)) // Failures of the partition nodes
+
+	// abort stops the partition nodes, releases the results of the partitions that
+	// completed anyway and fails the signal
+	abort := func(err error) {
+		subGraph.Stop()
+		for len(respChan) > 0 {
+			ConsumeSignal(<-respChan)
+		}
+		n.Fail(sig, err)
+	}
 
-	// Send each partitioned task to a separate node for processing
+	// Send each partitioned task to a separate node for processing. The results are
+	// integrated in partition order, whatever order they complete in.
+	respList := make([]string, len(parts))
+	sent := 0
 	for i, task := range parts {
-		newSig := NewSignalFromSignal(nodes[i].ID(), n.ID(), sig)
+		// A resumed run reuses the output of partitions that completed before it was interrupted
+		if result, ok := resumedPartition(sig, n.ID(), task); ok {
+			respList[i] = result.String()
+			continue
+		}
+		newSig := watchFailures(NewSignalFromSignal(nodes[i].ID(), n.ID(), sig), failures)
+		newSig = newSig.WithContext(context.WithValue(newSig.Context(), partitionIndexKey{owner: n}, i))
 		newSig.Task = &Carrier{TextData: task}
-		nodes[i].Connect(emptyNode)  // Connect the node to the empty node
-		nodes[i].InputCh() <- newSig // Send the signal to the node
+		nodes[i].Connect(emptyNode) // Connect the node to the empty node
+		DispatchSignal(newSig)
+		select {
+		case nodes[i].InputCh() <- newSig: // Send the signal to the node
+		case <-ctx.Done():
+			ConsumeSignal(newSig)
+			abort(fmt.Errorf("context timeout or cancellation while sending partition to node %s: %v", nodes[i].ID(), ctx.Err()))
+			return
+		}
+		sent++
 	}
 
 	// Collect the results from the nodes
-	respList := []string{}
-	for i := 0; i < len(parts); i++ {
-		recSig := <-respChan
-		respList = append(respList, recSig.Task.String()) // Collect the task results
+	parents := []string{}
+	metas := []node.Metadata{sig.Meta}
+	for i := 0; i < sent; i++ {
+		select {
+		case recSig := <-respChan:
+			ConsumeSignal(recSig)
+			part, ok := recSig.Context().Value(partitionIndexKey{owner: n}).(int)
+			if !ok {
+				abort(fmt.Errorf("received a signal from node %s that is not a partition", recSig.FromNodeID))
+				return
+			}
+			respList[part] = recSig.Task.String()          // Collect the task results
+			parents = append(parents, recSig.ParentIDs...) // The signals of the partition nodes
+			metas = append(metas, recSig.Meta)             // The metadata the partition nodes added
+		case failed := <-failures:
+			abort(fmt.Errorf("partition node %s: %s", failed.NodeID, failed.Err))
+			return
+		case <-ctx.Done():
+			abort(fmt.Errorf("context timeout or cancellation while collecting partitions: %v", ctx.Err()))
+			return
+		}
 	}
 
 	// Integrate the results from the partitions
@@ -127,16 +191,18 @@ func (n *SimplePartitionerNode) processSignal(sig node.Signal) {
 	}
 
 	sig.Result = &Carrier{TextData: response}
+	sig.ParentIDs = append(sig.ParentIDs, parents...)
+	sig.Meta = node.MergeMetadata(metas...)
 	sig.Status = StatusSuccess
 
 	// Post-process the signal
-	sig, err = n.PostProcessSignal(sig)
+	sig, err = n.PostProcessSignal(ctx, sig)
 	if err != nil {
 		n.Fail(sig, err)
 		return
 	}
 
-	if err := n.SendToConnected(context.TODO(), sig); err != nil {
+	if err := n.SendToConnected(ctx, sig); err != nil {
 		n.Fail(sig, err)
 		return
 	}
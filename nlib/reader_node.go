@@ -1,7 +1,6 @@
 package nlib
 
 import (
-	"context"
 	"io"
 
 	"github.com/dshills/wiggle/node"
@@ -19,26 +18,14 @@ type SimpleStringReaderNode struct {
 }
 
 // NewSimpleStringReaderNode creates a new instance of SimpleStringReaderNode. It sets up the reader,
-// options, and StateManager, and initializes the input channel for signal reception. A go routine
-// is launched to process incoming signals or to handle node termination via StateManager.
+// options, and StateManager, and initializes the input channel for signal reception. Incoming
+// signals are processed once the node has been started.
 func NewSimpleStringReaderNode(r io.Reader, mgr node.StateManager, options node.Options) *SimpleStringReaderNode {
 	n := SimpleStringReaderNode{reader: r}
 	n.SetOptions(options)
 	n.SetStateManager(mgr)
 	n.MakeInputCh()
-
-	go func() {
-		for {
-			select {
-			case sig := <-n.InputCh():
-				n.LogInfo("Received Signal")
-				n.processSignal(sig)
-			case <-n.StateManager().Register():
-				n.LogInfo("Received Done")
-				return
-			}
-		}
-	}()
+	n.SetProcessFunc(n.processSignal)
 
 	return &n
 }
@@ -49,7 +36,10 @@ func NewSimpleStringReaderNode(r io.Reader, mgr node.StateManager, options node.
 // is marked as failed. A 2-second timeout is applied during the sending of the signal to prevent blocking.
 func (n *SimpleStringReaderNode) processSignal(sig node.Signal) {
 	var err error
-	sig, err = n.PreProcessSignal(sig)
+	ctx, done := n.BeginSignal(sig)
+	defer done()
+
+	sig, err = n.PreProcessSignal(ctx, sig)
 	if err != nil {
 		n.Fail(sig, err)
 		return
@@ -65,13 +55,13 @@ func (n *SimpleStringReaderNode) processSignal(sig node.Signal) {
 
 	sig.Status = StatusSuccess
 
-	sig, err = n.PostProcessSignal(sig)
+	sig, err = n.PostProcessSignal(ctx, sig)
 	if err != nil {
 		n.Fail(sig, err)
 		return
 	}
 
-	if err := n.SendToConnected(context.TODO(), sig); err != nil {
+	if err := n.SendToConnected(ctx, sig); err != nil {
 		n.Fail(sig, err)
 		return
 	}
@@ -0,0 +1,287 @@
+package nlib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/node"
+)
+
+// Kinds of recorded events
+const (
+	EventEnter = "enter" // A signal was received by a node
+	EventLeave = "leave" // A signal was sent from a node to a connected node
+	EventFail  = "fail"  // A node failed a signal
+	EventChat  = "chat"  // A node called llm.LLM.Chat
+)
+
+// ErrNotRecorded is returned by LLM calls during a replay when the recording has no matching call
+var ErrNotRecorded = errors.New("no recorded LLM call")
+
+// RecordEvent is a single event of a recorded run
+type RecordEvent struct {
+	Seq      int             `json:"seq"`                // Order in which the event was recorded
+	Time     time.Time       `json:"time"`               // When the event was recorded
+	Kind     string          `json:"kind"`               // EventEnter, EventLeave, EventFail or EventChat
+	NodeID   string          `json:"node_id"`            // Node the event happened at
+	Signal   *node.Signal    `json:"signal,omitempty"`   // Signal received, sent or failed
+	Request  llm.MessageList `json:"request,omitempty"`  // Messages sent to the LLM
+	Response *llm.Response   `json:"response,omitempty"` // Response returned by the LLM
+	Err      string          `json:"err,omitempty"`      // Error returned by the LLM
+}
+
+// Recording holds the events of a recorded run. It is saved and loaded as JSON.
+type Recording struct {
+	Input  node.Signal   `json:"input"`  // Signal the run was started with
+	Events []RecordEvent `json:"events"` // Events in the order they were recorded
+}
+
+// LoadRecording reads a recording saved with Recorder.Save
+func LoadRecording(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("recording: %w", err)
+	}
+	rec := Recording{}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("recording: %w", err)
+	}
+	return &rec, nil
+}
+
+// Recorder records the signals entering and leaving each node and every LLM call of the
+// runs started with a context returned by WithRecorder.
+type Recorder struct {
+	mu  sync.Mutex
+	rec Recording
+}
+
+// NewRecorder creates an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+type recorderKey struct{}
+
+// WithRecorder returns a context that records the runs started with it into r
+func WithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+func recorderFrom(ctx context.Context) *Recorder {
+	r, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return r
+}
+
+// Recording returns a copy of what has been recorded so far
+func (r *Recorder) Recording() *Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Recording{Input: r.rec.Input, Events: append([]RecordEvent{}, r.rec.Events...)}
+}
+
+// Save writes the recording to a JSON file
+func (r *Recorder) Save(path string) error {
+	data, err := json.MarshalIndent(r.Recording(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("recording: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("recording: %w", err)
+	}
+	return nil
+}
+
+func (r *Recorder) setInput(sig node.Signal) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rec.Input = sig.WithContext(context.Background())
+}
+
+func (r *Recorder) add(ev RecordEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ev.Seq = len(r.rec.Events)
+	ev.Time = time.Now()
+	r.rec.Events = append(r.rec.Events, ev)
+}
+
+// recordSignal records a signal event if the signal belongs to a recorded run
+func recordSignal(kind, nodeID string, sig node.Signal) {
+	if r := recorderFrom(sig.Context()); r != nil {
+		sig = sig.WithContext(context.Background())
+		r.add(RecordEvent{Kind: kind, NodeID: nodeID, Signal: &sig})
+	}
+}
+
+// Chat calls lm.Chat. Calls made for a recorded run are recorded, and calls made during
+// a replay are answered from the recording without calling the LLM. Calls made for a
+// traced run are recorded as an "llm.chat" span, and requests and tokens are counted and
+// requests timed when metrics are on. Custom nodes should call their LLM through Chat, with the context
+// returned by EmptyNode.BeginSignal, so their runs can be recorded, replayed, traced and
+// measured.
+func Chat(ctx context.Context, lm llm.LLM, msgs llm.MessageList) (llm.Response, error) {
+	return chat(ctx, lm, msgs, nil, nil)
+}
+
+// ChatWithTools is Chat offering the tools to the model, see llm.ChatWithTools
+func ChatWithTools(ctx context.Context, lm llm.LLM, msgs llm.MessageList, tools []llm.Tool) (llm.Response, error) {
+	return chat(ctx, lm, msgs, tools, nil)
+}
+
+// ChatStream is Chat streaming the response: fn is called with each piece of the response
+// text as it arrives, and the complete response is returned at the end. LLMs that do not
+// implement llm.Streamer, and replayed calls, pass the whole response to fn at once.
+func ChatStream(ctx context.Context, lm llm.LLM, msgs llm.MessageList, fn func(delta string)) (llm.Response, error) {
+	if fn == nil {
+		fn = func(string) {}
+	}
+	return chat(ctx, lm, msgs, nil, fn)
+}
+
+func chat(ctx context.Context, lm llm.LLM, msgs llm.MessageList, tools []llm.Tool, stream func(string)) (llm.Response, error) {
+	nodeID := nodeIDFrom(ctx)
+	ctx, span := StartSpan(ctx, "llm.chat")
+	span.SetAttr("llm.provider", providerName(lm))
+	span.SetAttr("llm.model", lm.Model())
+	span.SetAttr("llm.request.messages", len(msgs))
+	span.SetAttr("llm.stream", stream != nil)
+
+	var resp llm.Response
+	var err error
+	if rp := replayerFrom(ctx); rp != nil {
+		span.SetAttr("llm.replayed", true)
+		resp, err = rp.chat(nodeID, msgs)
+		if err == nil && stream != nil {
+			stream(resp.Content)
+		}
+	} else {
+		labels := map[string]string{"provider": providerName(lm), "model": lm.Model()}
+		metrics := metricsFrom(ctx)
+		start := time.Now()
+		if stream != nil {
+			resp, err = streamChat(ctx, lm, msgs, stream)
+		} else {
+			resp, err = llm.ChatWithTools(ctx, lm, msgs, tools)
+		}
+		addCounter(metrics, MetricLLMRequests, labels, 1)
+		observeHistogram(metrics, MetricLLMDuration, labels, time.Since(start).Seconds())
+		for kind, tokens := range map[string]int{"prompt": resp.Usage.PromptTokens, "completion": resp.Usage.CompletionTokens} {
+			addCounter(metrics, MetricLLMTokens, map[string]string{"provider": labels["provider"], "model": labels["model"], "type": kind}, float64(tokens))
+		}
+		if err != nil {
+			addCounter(metrics, MetricLLMErrors, labels, 1)
+		}
+	}
+	span.SetAttr("llm.response.chars", len(resp.Content))
+	span.SetAttr("llm.response.model", resp.Model)
+	span.SetAttr("llm.response.finish_reason", resp.FinishReason)
+	span.SetAttr("llm.usage.prompt_tokens", resp.Usage.PromptTokens)
+	span.SetAttr("llm.usage.completion_tokens", resp.Usage.CompletionTokens)
+	span.SetAttr("llm.usage.total_tokens", resp.Usage.TotalTokens)
+	span.End(err)
+
+	if r := recorderFrom(ctx); r != nil {
+		ev := RecordEvent{Kind: EventChat, NodeID: nodeID, Request: msgs, Response: &resp}
+		if err != nil {
+			ev.Err = err.Error()
+		}
+		r.add(ev)
+	}
+	return resp, err
+}
+
+// streamChat streams the response of lm to fn, stopping the stream when it returns
+func streamChat(ctx context.Context, lm llm.LLM, msgs llm.MessageList, fn func(string)) (llm.Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, err := llm.StreamChat(ctx, lm, msgs)
+	if err != nil {
+		return llm.Response{}, err
+	}
+	resp, err := llm.Collect(events, fn)
+	if err != nil && ctx.Err() != nil {
+		return resp, ctx.Err()
+	}
+	return resp, err
+}
+
+// Replay runs the recorded input through the graph again under a new run ID. LLM calls
+// are answered from the recording instead of calling the LLM, so changes to hooks,
+// validators and other nodes can be tried without paying for model calls. A call is
+// matched to a recorded call with the same messages, preferring the same node, or else
+// to the next call recorded at the same node. Replay returns like Run.
+func Replay(ctx context.Context, entry node.Node, rec *Recording) (node.Signal, error) {
+	input := rec.Input
+	input.RunID = ""
+	ctx = context.WithValue(ctx, replayerKey{}, newReplayer(rec))
+	return Run(ctx, entry, input)
+}
+
+type replayerKey struct{}
+
+func replayerFrom(ctx context.Context) *replayer {
+	rp, _ := ctx.Value(replayerKey{}).(*replayer)
+	return rp
+}
+
+// replayer serves LLM calls from a recording, each recorded call once
+type replayer struct {
+	mu    sync.Mutex
+	calls []RecordEvent
+	used  []bool
+}
+
+func newReplayer(rec *Recording) *replayer {
+	rp := &replayer{}
+	for _, ev := range rec.Events {
+		if ev.Kind == EventChat {
+			rp.calls = append(rp.calls, ev)
+		}
+	}
+	rp.used = make([]bool, len(rp.calls))
+	return rp
+}
+
+func (rp *replayer) chat(nodeID string, msgs llm.MessageList) (llm.Response, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	match := func(sameNode, sameRequest bool) int {
+		for i, ev := range rp.calls {
+			if rp.used[i] || (sameNode && ev.NodeID != nodeID) || (sameRequest && !reflect.DeepEqual(ev.Request, msgs)) {
+				continue
+			}
+			return i
+		}
+		return -1
+	}
+	i := match(true, true)
+	if i < 0 {
+		i = match(false, true)
+	}
+	if i < 0 {
+		i = match(true, false)
+	}
+	if i < 0 {
+		return llm.Response{}, fmt.Errorf("node %s: %w", nodeID, ErrNotRecorded)
+	}
+
+	rp.used[i] = true
+	ev := rp.calls[i]
+	resp := llm.Response{}
+	if ev.Response != nil {
+		resp = *ev.Response
+	}
+	if ev.Err != "" {
+		return resp, errors.New(ev.Err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,71 @@
+package nlib_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/nmock"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newReplyLLM(reply string, err error) *nmock.MockLLM {
+	lm := new(nmock.MockLLM)
+	lm.On("Model").Return("mock")
+	lm.On("Chat", mock.Anything, mock.Anything).Return(llm.Message{Role: llm.RoleAssistant, Content: reply}, err)
+	lm.On("ChatWithTools", mock.Anything, mock.Anything, mock.Anything).Return(llm.Message{Role: llm.RoleAssistant, Content: reply}, err)
+	return lm
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.json")
+
+	// Record a run
+	mgr := nlib.NewSimpleStateManager(nil)
+	lm := newReplyLLM("HELLO", nil)
+	ai := nlib.NewAINode(lm, mgr, node.Options{ID: "ai"})
+	ai.Connect(newTransformNode(mgr, "punctuate", func(s string) string { return s + "!" }))
+
+	rec := nlib.NewRecorder()
+	sig, err := nlib.Run(nlib.WithRecorder(context.Background(), rec), ai, node.Signal{Task: nlib.NewTextCarrier("hello")})
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO!", sig.Result.String())
+	assert.NoError(t, rec.Save(path))
+
+	recording, err := nlib.LoadRecording(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", recording.Input.Task.String())
+	kinds := []string{}
+	for _, ev := range recording.Events {
+		kinds = append(kinds, ev.Kind+" "+ev.NodeID)
+	}
+	assert.Equal(t, []string{"enter ai", "chat ai", "leave ai", "enter punctuate"}, kinds)
+	assert.Equal(t, "hello", recording.Events[1].Request.Latest().Content)
+	assert.Equal(t, "HELLO", recording.Events[1].Response.Content)
+	assert.Equal(t, "punctuate", recording.Events[2].Signal.NodeID)
+
+	// Replay against a changed downstream node without calling the LLM
+	mgr = nlib.NewSimpleStateManager(nil)
+	unused := newReplyLLM("", errors.New("LLM called during replay"))
+	ai = nlib.NewAINode(unused, mgr, node.Options{ID: "ai"})
+	ai.Connect(newTransformNode(mgr, "punctuate", func(s string) string { return s + "?" }))
+
+	sig, err = nlib.Replay(context.Background(), ai, recording)
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO?", sig.Result.String())
+	assert.NotEqual(t, recording.Input.RunID, sig.RunID)
+	unused.AssertNotCalled(t, "Chat", mock.Anything, mock.Anything)
+}
+
+func TestReplay_NotRecorded(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	ai := nlib.NewAINode(newReplyLLM("HELLO", nil), mgr, node.Options{ID: "ai"})
+
+	_, err := nlib.Replay(context.Background(), ai, &nlib.Recording{Input: node.Signal{Task: nlib.NewTextCarrier("hello")}})
+	assert.ErrorContains(t, err, "no recorded LLM call")
+}
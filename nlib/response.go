@@ -0,0 +1,56 @@
+package nlib
+
+import (
+	"time"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/node"
+)
+
+// Metadata keys describing the LLM response a signal was processed with. AINode and
+// AgentNode set them with SetLLMResponse so hooks, budgets and metrics downstream can
+// read them.
+const (
+	MetaLLMModel            = "llm.model"             // Model that generated the response
+	MetaLLMFinishReason     = "llm.finish_reason"     // Why the model stopped, for example llm.FinishLength
+	MetaLLMPromptTokens     = "llm.prompt_tokens"     // Tokens in the request
+	MetaLLMCompletionTokens = "llm.completion_tokens" // Tokens in the response
+	MetaLLMLatency          = "llm.latency_ms"        // Milliseconds until the response was complete
+	MetaLLMTokensUsed       = "llm.tokens_used"       // Total tokens of every response along the signal's path
+)
+
+// SetLLMResponse returns the signal with metadata describing resp. The values replace
+// those of an earlier response, except MetaLLMTokensUsed, which adds the tokens of resp
+// to the tokens used so far:
+//
+//	used, _ := sig.Meta.GetInt(nlib.MetaLLMTokensUsed)
+//	if used > budget {
+//		return sig, errors.New("token budget exceeded")
+//	}
+func SetLLMResponse(sig node.Signal, resp llm.Response) node.Signal {
+	used, _ := sig.Meta.GetInt(MetaLLMTokensUsed)
+	sig.Meta = sig.Meta.
+		SetString(MetaLLMModel, resp.Model).
+		SetString(MetaLLMFinishReason, resp.FinishReason).
+		SetInt(MetaLLMPromptTokens, resp.Usage.PromptTokens).
+		SetInt(MetaLLMCompletionTokens, resp.Usage.CompletionTokens).
+		SetInt(MetaLLMLatency, int(resp.Latency.Milliseconds())).
+		SetInt(MetaLLMTokensUsed, used+resp.Usage.TotalTokens)
+	return sig
+}
+
+// LLMResponse returns the usage, finish reason, model and latency of the last LLM
+// response set on the signal by SetLLMResponse. The message is not included, it is the
+// signal's Result. ok is false if no response was set.
+func LLMResponse(sig node.Signal) (resp llm.Response, ok bool) {
+	if resp.Model, ok = sig.Meta.GetString(MetaLLMModel); !ok {
+		return resp, false
+	}
+	resp.FinishReason, _ = sig.Meta.GetString(MetaLLMFinishReason)
+	resp.Usage.PromptTokens, _ = sig.Meta.GetInt(MetaLLMPromptTokens)
+	resp.Usage.CompletionTokens, _ = sig.Meta.GetInt(MetaLLMCompletionTokens)
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+	ms, _ := sig.Meta.GetInt(MetaLLMLatency)
+	resp.Latency = time.Duration(ms) * time.Millisecond
+	return resp, true
+}
@@ -0,0 +1,69 @@
+package nlib_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/nmock"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newUsageLLM replies with a response reporting its usage
+func newUsageLLM(reply string, prompt, completion int) *nmock.MockLLM {
+	lm := new(nmock.MockLLM)
+	lm.On("Model").Return("mock")
+	lm.On("Chat", mock.Anything, mock.Anything).Return(llm.Response{
+		Message:      llm.AssistantMsg(reply),
+		Usage:        llm.Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion},
+		FinishReason: llm.FinishStop,
+		Model:        "mock-2024",
+		Latency:      1500 * time.Millisecond,
+	}, nil)
+	return lm
+}
+
+func TestAINode_LLMResponse(t *testing.T) {
+	metrics := nlib.NewPrometheusMetrics()
+	mgr := nlib.NewSimpleStateManager(nil)
+	mgr.SetMetrics(metrics)
+	first := nlib.NewAINode(newUsageLLM("Bonjour", 10, 5), mgr, node.Options{ID: "first"})
+	second := nlib.NewAINode(newUsageLLM("Hello", 20, 7), mgr, node.Options{ID: "second"})
+	first.Connect(second)
+
+	rec := nlib.NewRecorder()
+	sig, err := nlib.Run(nlib.WithRecorder(context.Background(), rec), first, node.Signal{Task: nlib.NewTextCarrier("Hi")})
+	assert.NoError(t, err)
+
+	resp, ok := nlib.LLMResponse(sig)
+	assert.True(t, ok)
+	assert.Equal(t, "mock-2024", resp.Model)
+	assert.Equal(t, llm.FinishStop, resp.FinishReason)
+	assert.Equal(t, llm.Usage{PromptTokens: 20, CompletionTokens: 7, TotalTokens: 27}, resp.Usage)
+	assert.Equal(t, 1500*time.Millisecond, resp.Latency)
+	assert.Empty(t, resp.Content)
+
+	// Tokens used add up along the path
+	used, _ := sig.Meta.GetInt(nlib.MetaLLMTokensUsed)
+	assert.Equal(t, 42, used)
+
+	labels := map[string]string{"provider": "nmock", "model": "mock", "type": "prompt"}
+	assert.Equal(t, 30.0, metrics.Value(nlib.MetricLLMTokens, labels))
+	labels["type"] = "completion"
+	assert.Equal(t, 12.0, metrics.Value(nlib.MetricLLMTokens, labels))
+
+	// Replayed responses keep their details
+	replayed := nlib.NewAINode(newReplyLLM("", nil), mgr, node.Options{ID: "first"})
+	replayed.Connect(nlib.NewAINode(newReplyLLM("", nil), mgr, node.Options{ID: "second"}))
+	sig, err = nlib.Replay(context.Background(), replayed, rec.Recording())
+	assert.NoError(t, err)
+	used, _ = sig.Meta.GetInt(nlib.MetaLLMTokensUsed)
+	assert.Equal(t, 42, used)
+
+	_, ok = nlib.LLMResponse(node.Signal{})
+	assert.False(t, ok)
+}
@@ -0,0 +1,184 @@
+package nlib
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/dshills/wiggle/node"
+)
+
+// Resume continues a run that was interrupted, for example because the process exited,
+// using the checkpoints saved by the entry node's StateManager. Nodes that had completed
+// are not run again: the output they saved is sent on to the nodes that had not yet
+// processed it. Partitioner nodes reuse the saved output of partitions that had completed.
+// Resume blocks like Run and returns the signal produced by the terminal node of the run.
+func Resume(ctx context.Context, entry node.Node, runID string) (node.Signal, error) {
+	h, err := StartResume(ctx, entry, runID)
+	if err != nil {
+		return node.Signal{RunID: runID}, err
+	}
+	return h.Result()
+}
+
+// StartResume resumes a run like Resume and returns immediately with a handle to the run.
+// The saved checkpoints are added to the run's history so the handle reports the whole run.
+func StartResume(ctx context.Context, entry node.Node, runID string) (*RunHandle, error) {
+	stateMgr, err := entryStateManager(entry)
+	if err != nil {
+		return nil, err
+	}
+	store := checkpointStore(stateMgr)
+	if store == nil {
+		return nil, fmt.Errorf("run %s: no CheckpointStore set", runID)
+	}
+	checkpoints, err := store.Load(runID)
+	if err != nil {
+		return nil, fmt.Errorf("run %s: %w", runID, err)
+	}
+	if len(checkpoints) == 0 {
+		return nil, fmt.Errorf("run %s: no checkpoints to resume from", runID)
+	}
+
+	for _, sig := range checkpoints {
+		stateMgr.AddHistory(sig)
+	}
+	deliveries, partitions := planResume(entry, checkpoints)
+	ctx = context.WithValue(ctx, resumeKey{}, partitions)
+
+	return startRun(ctx, entry, runID, func(runCtx context.Context) error {
+		for _, d := range deliveries {
+			sig := NewSignalFromSignal(d.to.ID(), d.from.ID(), d.sig).WithContext(runCtx)
+			if err := sendRunSignal(runCtx, d.to, sig); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// delivery is the output of a completed node that a connected node has not yet processed
+type delivery struct {
+	from node.Node
+	to   node.Node
+	sig  node.Signal
+}
+
+// planResume replays the checkpoints of a run against the graph. Each checkpoint
+// delivers its output to the nodes the signal is routed to, and consumes the delivery
+// it was produced from. The deliveries left over are the signals to send to resume the
+// run. Nodes inside a set are skipped: a set that had not completed runs its sub-graph
+// again. Checkpoints of nodes outside the graph that were sent work by a partitioner
+// are returned so the partitioner can reuse them.
+func planResume(entry node.Node, checkpoints []node.Signal) ([]delivery, *resumedPartitions) {
+	nodes := make(map[string]node.Node)
+	inner := make(map[node.Node]bool)
+	Walk(func(n node.Node) {
+		nodes[n.ID()] = n
+		if set, ok := n.(*SimpleSetNode); ok {
+			inner[set.collector] = true
+			if set.startNode != nil {
+				Walk(func(sub node.Node) { inner[sub] = true }, set.startNode)
+			}
+		}
+	}, entry)
+
+	pending := []delivery{}
+	consume := func(from string, to node.Node) {
+		for i, d := range pending {
+			if d.to == to && d.from.ID() == from {
+				pending = append(pending[:i], pending[i+1:]...)
+				return
+			}
+		}
+	}
+
+	partitions := &resumedPartitions{results: make(map[partitionKey][]node.DataCarrier)}
+	for _, sig := range checkpoints {
+		n, ok := nodes[sig.NodeID]
+		if !ok {
+			if sig.Task != nil && sig.Result != nil {
+				key := partitionKey{from: sig.FromNodeID, task: sig.Task.String()}
+				partitions.results[key] = append(partitions.results[key], sig.Result)
+			}
+			continue
+		}
+		if inner[n] {
+			continue
+		}
+
+		if _, ok := n.(*SimpleJoinNode); ok {
+			// A join consumes one signal from each of its upstream nodes
+			seen := make(map[string]bool)
+			for _, d := range append([]delivery{}, pending...) {
+				if d.to == n && !seen[d.from.ID()] {
+					seen[d.from.ID()] = true
+					consume(d.from.ID(), n)
+				}
+			}
+		} else {
+			consume(sig.FromNodeID, n)
+		}
+
+		for _, to := range routeTargets(n, sig) {
+			if to != nil && !inner[to] {
+				pending = append(pending, delivery{from: n, to: to, sig: sig})
+			}
+		}
+	}
+	return pending, partitions
+}
+
+// routeTargets returns the nodes a node sends a processed signal to
+func routeTargets(n node.Node, sig node.Signal) []node.Node {
+	targets := []node.Node{}
+	switch t := n.(type) {
+	case *SimpleBranchNode:
+		for _, cond := range t.conditions {
+			if cond.ConditionFn != nil && cond.ConditionFn(sig) {
+				return append(targets, cond.Target)
+			}
+		}
+	case *SimpleLoopNode:
+		if t.startNode != nil && (t.condFn == nil || !t.condFn(sig)) {
+			targets = append(targets, t.startNode)
+		}
+	}
+	if con, ok := n.(interface{ Nodes() []node.Node }); ok {
+		targets = append(targets, con.Nodes()...)
+	}
+	return targets
+}
+
+// resumeKey is the context key of the resumedPartitions of a resumed run
+type resumeKey struct{}
+
+// partitionKey identifies the work a partitioner sent to a partition node
+type partitionKey struct {
+	from string // ID of the partitioner
+	task string // Task sent to the partition node
+}
+
+// resumedPartitions holds the saved output of partitions completed before a run was resumed
+type resumedPartitions struct {
+	mu      sync.Mutex
+	results map[partitionKey][]node.DataCarrier
+}
+
+// resumedPartition returns the saved output of a partition of a resumed run, if there is one.
+// Each saved output is returned once.
+func resumedPartition(sig node.Signal, partitioner, task string) (node.DataCarrier, bool) {
+	rp, ok := sig.Context().Value(resumeKey{}).(*resumedPartitions)
+	if !ok {
+		return nil, false
+	}
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	key := partitionKey{from: partitioner, task: task}
+	results := rp.results[key]
+	if len(results) == 0 {
+		return nil, false
+	}
+	rp.results[key] = results[1:]
+	return results[0], true
+}
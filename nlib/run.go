@@ -0,0 +1,363 @@
+package nlib
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/dshills/wiggle/node"
+)
+
+// runTracker counts the signals of a run that have been dispatched to a node but not yet
+// consumed. The run is finished when the count drops to zero. The run has ended once its
+// result has been collected, its state is no longer needed after that.
+type runTracker struct {
+	mu      sync.Mutex
+	pending int
+	done    chan struct{}
+	ended   chan struct{}
+}
+
+type runTrackerKey struct{}
+
+func newRunTracker() *runTracker {
+	return &runTracker{done: make(chan struct{}), ended: make(chan struct{})}
+}
+
+// end records that the result of the run has been collected
+func (t *runTracker) end() {
+	close(t.ended)
+}
+
+// hasEnded reports whether the result of the run has been collected
+func (t *runTracker) hasEnded() bool {
+	select {
+	case <-t.ended:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *runTracker) add(delta int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending += delta
+	if t.pending <= 0 {
+		select {
+		case <-t.done:
+		default:
+			close(t.done)
+		}
+	}
+}
+
+// runTrackerFrom returns the tracker carried by the signal's context, if any
+func runTrackerFrom(sig node.Signal) *runTracker {
+	tr, _ := sig.Context().Value(runTrackerKey{}).(*runTracker)
+	return tr
+}
+
+// DispatchSignal records that a signal has been handed to a node. Nodes built on EmptyNode
+// call it when sending. Custom nodes that send signals directly on InputCh should call it
+// before each send so Run can tell when a run has finished.
+func DispatchSignal(sig node.Signal) {
+	if tr := runTrackerFrom(sig); tr != nil {
+		tr.add(1)
+	}
+}
+
+// ConsumeSignal records that a received signal has been fully processed. Nodes built on
+// EmptyNode call it after processing each signal. Custom nodes should call it once for
+// every signal they receive so Run can tell when a run has finished.
+func ConsumeSignal(sig node.Signal) {
+	if tr := runTrackerFrom(sig); tr != nil {
+		tr.add(-1)
+	}
+}
+
+// RunHandle tracks a run started with StartRun
+type RunHandle struct {
+	runID   string
+	cancel  context.CancelFunc
+	done    chan struct{}
+	mu      sync.Mutex
+	status  string
+	result  node.Signal
+	results []node.Signal
+	err     error
+}
+
+// Run sends the input signal to the entry node and blocks until every signal of the run
+// has been processed. It returns the signal produced by the terminal node of the run, the
+// node with no further connections that processed it last. If any node fails the failing
+// signal is returned with an error. Branches and loops may terminate on different nodes;
+// use StartRun and RunHandle.Results to inspect every terminal signal.
+//
+// If the entry node is not running, the graph reachable from it is started and kept running
+// until every run through the entry node that started or joined it has finished. The run's state is kept by the StateManager until RunStateManager.EndRun is
+// called or the StateManager's retention ends it, see SimpleStateManager.SetRunRetention.
+func Run(ctx context.Context, entry node.Node, input node.Signal) (node.Signal, error) {
+	h, err := StartRun(ctx, entry, input)
+	if err != nil {
+		return input, err
+	}
+	return h.Result()
+}
+
+// StartRun sends the input signal to the entry node and returns immediately with a handle
+// to the run. The input signal is given a new RunID if it does not already have one.
+func StartRun(ctx context.Context, entry node.Node, input node.Signal) (*RunHandle, error) {
+	input = StampRunID(input)
+	input.NodeID = entry.ID()
+	input = StampSignal(input, entry.ID())
+	if r := recorderFrom(ctx); r != nil {
+		r.setInput(input)
+	}
+	return startRun(ctx, entry, input.RunID, func(runCtx context.Context) error {
+		return sendRunSignal(runCtx, entry, input.WithContext(runCtx))
+	})
+}
+
+// startRun starts tracking a run, calls send to inject its signals and waits for
+// the run to finish in the background
+func startRun(ctx context.Context, entry node.Node, runID string, send func(runCtx context.Context) error) (*RunHandle, error) {
+	stateMgr, err := entryStateManager(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start the graph for the duration of the run if needed
+	graph := acquireRunGraph(entry)
+
+	tracker := newRunTracker()
+	runCtx, cancel := context.WithCancel(context.WithValue(ctx, runTrackerKey{}, tracker))
+
+	h := &RunHandle{
+		runID:  runID,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		status: StatusInProcess,
+	}
+
+	// Hold the run open until every signal has been sent
+	tracker.add(1)
+	if err := send(runCtx); err != nil {
+		tracker.end()
+		cancel()
+		releaseRunGraph(graph)
+		return nil, fmt.Errorf("run %s: %w", runID, err)
+	}
+	tracker.add(-1)
+
+	go func() {
+		defer close(h.done)
+		defer cancel()
+		defer releaseRunGraph(graph)
+
+		select {
+		case <-tracker.done:
+		case <-runCtx.Done():
+		}
+		// A cancelled run may also drain, report the cancellation in that case
+		h.collect(stateMgr, entry, runCtx.Err())
+		h.removeCheckpoints(stateMgr)
+		tracker.end()
+	}()
+
+	return h, nil
+}
+
+// runGraph is a graph started by Run for an entry node that was not running. It is
+// shared by every run through the entry node and stopped when the last one finishes.
+type runGraph struct {
+	entry node.Node
+	graph *Graph
+	runs  int
+}
+
+var (
+	runGraphsMu sync.Mutex
+	runGraphs   = make(map[node.Node]*runGraph)
+)
+
+// acquireRunGraph starts the graph of the entry node if it is not running, or joins the
+// graph started by another run through it. It returns nil if the caller started the graph.
+func acquireRunGraph(entry node.Node) *runGraph {
+	runGraphsMu.Lock()
+	defer runGraphsMu.Unlock()
+	if rg, ok := runGraphs[entry]; ok {
+		rg.runs++
+		return rg
+	}
+	if r, ok := entry.(interface{ Running() bool }); !ok || r.Running() {
+		return nil
+	}
+	rg := &runGraph{entry: entry, graph: NewGraph(entry), runs: 1}
+	rg.graph.Start(context.Background())
+	runGraphs[entry] = rg
+	return rg
+}
+
+// releaseRunGraph stops the graph once the last run using it has finished
+func releaseRunGraph(rg *runGraph) {
+	if rg == nil {
+		return
+	}
+	runGraphsMu.Lock()
+	defer runGraphsMu.Unlock()
+	rg.runs--
+	if rg.runs == 0 {
+		delete(runGraphs, rg.entry)
+		rg.graph.Stop()
+	}
+}
+
+// entryStateManager returns the StateManager of a run's entry node
+func entryStateManager(entry node.Node) (node.StateManager, error) {
+	smNode, ok := entry.(interface{ StateManager() node.StateManager })
+	if !ok || smNode.StateManager() == nil {
+		return nil, fmt.Errorf("entry node %s has no StateManager", entry.ID())
+	}
+	return smNode.StateManager(), nil
+}
+
+// sendRunSignal sends a signal of a run to the target node
+func sendRunSignal(runCtx context.Context, target node.Node, sig node.Signal) error {
+	DispatchSignal(sig)
+	select {
+	case target.InputCh() <- sig:
+		return nil
+	case <-runCtx.Done():
+		ConsumeSignal(sig)
+		return fmt.Errorf("sending to node %s: %w", target.ID(), runCtx.Err())
+	}
+}
+
+// collect gathers the terminal signals and failures of the run from its history. A run
+// whose history is missing or holds no signal of a terminal node has failed.
+func (h *RunHandle) collect(stateMgr node.StateManager, entry node.Node, ctxErr error) {
+	terminal := make(map[string]bool)
+	Walk(func(n node.Node) {
+		if isTerminal(n) {
+			terminal[n.ID()] = true
+		}
+	}, entry)
+
+	history := runHistory(stateMgr, h.runID)
+	var failed *node.Signal
+	results := []node.Signal{}
+	for i := range history {
+		if history[i].Status == StatusAgentStep {
+			continue // Steps of an agent are not signals sent through the graph
+		}
+		if history[i].Err != "" && failed == nil {
+			failed = &history[i]
+		}
+		if terminal[history[i].NodeID] {
+			results = append(results, history[i])
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.results = results
+	switch {
+	case len(results) > 0:
+		h.result = results[len(results)-1]
+	case len(history) > 0:
+		h.result = history[len(history)-1]
+	}
+
+	switch {
+	case ctxErr != nil:
+		h.status = StatusFail
+		h.err = fmt.Errorf("run %s: %w", h.runID, ctxErr)
+	case failed != nil:
+		h.status = StatusFail
+		h.result = *failed
+		h.err = fmt.Errorf("run %s failed at node %s: %s", h.runID, failed.NodeID, failed.Err)
+	case len(history) == 0:
+		h.status = StatusFail
+		h.err = fmt.Errorf("run %s has no history", h.runID)
+	case len(results) == 0:
+		h.status = StatusFail
+		h.err = fmt.Errorf("run %s finished without reaching a terminal node", h.runID)
+	default:
+		h.status = StatusSuccess
+	}
+}
+
+// isTerminal reports whether the node sends its signals nowhere. The sub-graph of a set
+// node is not counted, the set node itself is terminal when nothing is connected to it.
+func isTerminal(n node.Node) bool {
+	if con, ok := n.(interface{ Nodes() []node.Node }); ok && len(con.Nodes()) > 0 {
+		return false
+	}
+	if branch, ok := n.(node.BranchNode); ok {
+		for _, cond := range branch.Conditions() {
+			if cond.Target != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// runHistory returns the history of a run, filtering the full history if the StateManager
+// does not keep runs apart
+func runHistory(stateMgr node.StateManager, runID string) []node.Signal {
+	if rm, ok := stateMgr.(node.RunStateManager); ok {
+		return rm.FilterRunHistory(runID)
+	}
+	return filterRun(stateMgr.GetHistory(), runID)
+}
+
+// removeCheckpoints drops the checkpoints of a run that succeeded, they are no longer needed to resume it
+func (h *RunHandle) removeCheckpoints(stateMgr node.StateManager) {
+	store := checkpointStore(stateMgr)
+	if store == nil || h.Status() != StatusSuccess {
+		return
+	}
+	if err := store.Remove(h.runID); err != nil {
+		logAt(stateMgr, node.LevelError, "removing checkpoints", node.LogKeyRunID, h.runID, node.LogKeyError, err)
+	}
+}
+
+// RunID returns the ID of the run
+func (h *RunHandle) RunID() string {
+	return h.runID
+}
+
+// Status returns StatusInProcess while the run is active, then StatusSuccess or StatusFail
+func (h *RunHandle) Status() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status
+}
+
+// Done returns a channel that is closed when the run has finished
+func (h *RunHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Result blocks until the run has finished and returns its final signal
+func (h *RunHandle) Result() (node.Signal, error) {
+	<-h.done
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.result, h.err
+}
+
+// Results blocks until the run has finished and returns every terminal signal of the run
+func (h *RunHandle) Results() []node.Signal {
+	<-h.done
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]node.Signal{}, h.results...)
+}
+
+// Cancel aborts the run, cancelling any in-flight processing of its signals
+func (h *RunHandle) Cancel() {
+	h.cancel()
+}
@@ -0,0 +1,169 @@
+package nlib_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun_ReturnsFinalSignal(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	upper := newTransformNode(mgr, "upper", strings.ToUpper)
+	exclaim := newTransformNode(mgr, "exclaim", func(s string) string { return s + "!" })
+	upper.Connect(exclaim)
+
+	sig, err := nlib.Run(context.Background(), upper, node.Signal{Task: nlib.NewTextCarrier("hello")})
+	assert.NoError(t, err)
+	assert.Equal(t, "exclaim", sig.NodeID)
+	assert.Equal(t, "HELLO!", sig.Result.String())
+	assert.NotEmpty(t, sig.RunID)
+
+	// The graph started by Run is stopped once the run has finished
+	assert.False(t, upper.(*nlib.SimpleBranchNode).Running())
+}
+
+func TestRun_ConcurrentRunsShareGraph(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	sleep := newSleepNode(mgr, node.Options{ID: "sleep", Concurrency: 2})
+	upper := newTransformNode(mgr, "upper", strings.ToUpper)
+	exclaim := newTransformNode(mgr, "exclaim", func(s string) string { return s + "!" })
+	sleep.Connect(upper)
+	upper.Connect(exclaim)
+
+	// The run that started the graph finishes first, the graph keeps running for the other one
+	first, err := nlib.StartRun(context.Background(), sleep, node.Signal{Task: nlib.NewTextCarrier("50ms")})
+	assert.NoError(t, err)
+	second, err := nlib.StartRun(context.Background(), sleep, node.Signal{Task: nlib.NewTextCarrier("200ms")})
+	assert.NoError(t, err)
+
+	sig, err := first.Result()
+	assert.NoError(t, err)
+	assert.Equal(t, "50MS!", sig.Result.String())
+	assert.True(t, sleep.(*nlib.SimpleBranchNode).Running())
+
+	sig, err = second.Result()
+	assert.NoError(t, err)
+	assert.Equal(t, "200MS!", sig.Result.String())
+	assert.False(t, sleep.(*nlib.SimpleBranchNode).Running())
+}
+
+func TestRun_BranchTerminatesOnDifferentNodes(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	short := newTransformNode(mgr, "short", strings.ToUpper)
+	long := newTransformNode(mgr, "long", strings.ToLower)
+	branch := newTransformNode(mgr, "branch", strings.TrimSpace).(*nlib.SimpleBranchNode)
+	branch.AddConditional(node.BranchCondition{
+		Target:      short,
+		ConditionFn: func(sig node.Signal) bool { return len(sig.Result.String()) < 5 },
+	})
+	branch.Connect(long)
+	startGraph(t, branch)
+
+	sig, err := nlib.Run(context.Background(), branch, node.Signal{Task: nlib.NewTextCarrier("hi")})
+	assert.NoError(t, err)
+	assert.Equal(t, "short", sig.NodeID)
+	assert.Equal(t, "HI", sig.Result.String())
+
+	sig, err = nlib.Run(context.Background(), branch, node.Signal{Task: nlib.NewTextCarrier("Hello World")})
+	assert.NoError(t, err)
+	assert.Equal(t, "long", sig.NodeID)
+	assert.Equal(t, "hello world", sig.Result.String())
+
+	// Nodes started by the caller keep running
+	assert.True(t, branch.Running())
+}
+
+func TestRun_Loop(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	inc := newTransformNode(mgr, "inc", func(s string) string { return s + "x" })
+	passThrough := func(sig node.Signal) (node.Signal, error) {
+		sig.Result = sig.Task
+		return sig, nil
+	}
+	cond := func(sig node.Signal) bool { return len(sig.Task.String()) >= 3 }
+	loop := nlib.NewSimpleLoopNode(inc, cond, mgr, node.Options{ID: "loop", Hooks: nlib.NewSimpleNodeHooks(nil, passThrough)})
+	done := newTransformNode(mgr, "done", strings.ToUpper)
+	inc.Connect(loop)
+	loop.Connect(done)
+
+	h, err := nlib.StartRun(context.Background(), inc, node.Signal{Task: nlib.NewTextCarrier("")})
+	assert.NoError(t, err)
+
+	sig, err := h.Result()
+	assert.NoError(t, err)
+	assert.Equal(t, "done", sig.NodeID)
+	assert.Equal(t, "XXX", sig.Result.String())
+	assert.Len(t, h.Results(), 3)
+	assert.Equal(t, nlib.StatusSuccess, h.Status())
+}
+
+func TestRun_BranchAndLoopStatus(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	branch := nlib.NewSimpleBranchNode(mgr, node.Options{ID: "branch"})
+	loop := nlib.NewSimpleLoopNode(nil, nil, mgr, node.Options{ID: "loop"})
+	branch.AddConditional(node.BranchCondition{Target: loop, ConditionFn: func(node.Signal) bool { return true }})
+
+	// The loop ends the run, both nodes are recorded as successful
+	sig, err := nlib.Run(context.Background(), branch, node.Signal{Task: nlib.NewTextCarrier("hi")})
+	assert.NoError(t, err)
+	assert.Equal(t, "loop", sig.NodeID)
+	assert.Equal(t, nlib.StatusSuccess, sig.Status)
+	for _, id := range []string{"branch", "loop"} {
+		assert.Equal(t, nlib.StatusSuccess, mgr.GetState(node.Signal{NodeID: id, RunID: sig.RunID}).Status, id)
+	}
+}
+
+func TestRun_Failure(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	fail := func(sig node.Signal) (node.Signal, error) { return sig, errors.New("boom") }
+	upper := newTransformNode(mgr, "upper", strings.ToUpper)
+	broken := nlib.NewSimpleBranchNode(mgr, node.Options{ID: "broken", Hooks: nlib.NewSimpleNodeHooks(fail, nil)})
+	upper.Connect(broken)
+
+	sig, err := nlib.Run(context.Background(), upper, node.Signal{Task: nlib.NewTextCarrier("hello")})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "broken")
+	assert.Equal(t, "broken", sig.NodeID)
+	assert.Equal(t, "boom", sig.Err)
+}
+
+func TestRun_NoTerminalSignal(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	short := newTransformNode(mgr, "short", strings.ToUpper)
+	branch := newTransformNode(mgr, "branch", strings.TrimSpace).(*nlib.SimpleBranchNode)
+	branch.AddConditional(node.BranchCondition{
+		Target:      short,
+		ConditionFn: func(sig node.Signal) bool { return len(sig.Result.String()) < 5 },
+	})
+
+	// No condition matches, the run ends at the branch
+	sig, err := nlib.Run(context.Background(), branch, node.Signal{Task: nlib.NewTextCarrier("Hello World")})
+	assert.ErrorContains(t, err, "without reaching a terminal node")
+	assert.Equal(t, "branch", sig.NodeID)
+}
+
+func TestStartRun_Cancel(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	aiNode := nlib.NewAINode(newStallingLLM(), mgr, node.Options{ID: "ai"})
+
+	h, err := nlib.StartRun(context.Background(), aiNode, node.Signal{Task: nlib.NewTextCarrier("stall")})
+	assert.NoError(t, err)
+	assert.Equal(t, nlib.StatusInProcess, h.Status())
+
+	h.Cancel()
+	select {
+	case <-h.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run was not cancelled")
+	}
+
+	_, err = h.Result()
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, nlib.StatusFail, h.Status())
+}
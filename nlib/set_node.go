@@ -0,0 +1,320 @@
+package nlib
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/dshills/wiggle/node"
+)
+
+// Compile-time check to ensure SimpleSetNode implements the node.SetNode interface
+var _ node.SetNode = (*SimpleSetNode)(nil)
+
+// SimpleSetNode encapsulates a sub-graph of nodes so that it can be used as a single node.
+// Signals received on its input channel are forwarded to the start node. The output of the
+// final node is captured and sent to the SimpleSetNode's connected nodes as if the entire
+// sub-graph were one node. With Options.Concurrency a set processes several signals at once,
+// the output of the final node is matched to the signal it was produced for.
+type SimpleSetNode struct {
+	EmptyNode                    // Inherits base node functionality.
+	startNode   node.Node        // The entry point of the sub-graph.
+	finalNode   node.Node        // The node whose output is the output of the set.
+	coordinator node.Coordinator // Optional coordinator for the sub-graph.
+	collector   *EmptyNode       // Receives the output of the final node.
+	subGraph    *Graph           // Lifecycle of the sub-graph while the set is running.
+
+	waitMu    sync.Mutex                  // Guards waiting
+	waiting   map[string]chan node.Signal // Receives the output for a signal sent to the start node, by its ID
+	collect   context.CancelFunc          // Stops routing the collector's input, guarded by lifeMu
+	collected chan struct{}               // Closed once routing has stopped, nil when not running
+}
+
+// NewSimpleSetNode creates a new SimpleSetNode with the given start and final nodes.
+// The start and final nodes may be the same node. Either can be set later with
+// SetStartNode and SetFinalNode. Starting the set also starts the nodes of its sub-graph.
+func NewSimpleSetNode(start, final node.Node, mgr node.StateManager, options node.Options) *SimpleSetNode {
+	n := SimpleSetNode{startNode: start, waiting: make(map[string]chan node.Signal)}
+	n.SetOptions(options)
+	n.SetStateManager(mgr)
+	n.MakeInputCh()
+	n.collector = &EmptyNode{id: n.ID() + "-collector"}
+	n.collector.MakeInputCh()
+	if final != nil {
+		n.SetFinalNode(final)
+	}
+	n.SetProcessFunc(n.processSignal)
+
+	return &n
+}
+
+// subGraphFailuresKey is the context key of the channel receiving the failures of a sub-graph
+type subGraphFailuresKey struct{}
+
+// watchFailures returns a copy of sig whose context reports the signals failed by the nodes
+// it reaches on failures. Sets and partitioners use it so they stop waiting for the output
+// of a sub-graph that will never produce one. Signals derived from sig keep the watch.
+func watchFailures(sig node.Signal, failures chan<- node.Signal) node.Signal {
+	return sig.WithContext(context.WithValue(sig.Context(), subGraphFailuresKey{}, failures))
+}
+
+// reportFailure hands a failed signal to the sub-graph watching it, if any. It never
+// blocks, only the first failures that fit the channel are reported.
+func reportFailure(sig node.Signal) {
+	failures, ok := sig.Context().Value(subGraphFailuresKey{}).(chan<- node.Signal)
+	if !ok {
+		return
+	}
+	select {
+	case failures <- sig:
+	default:
+	}
+}
+
+// setOutputKey is the context key of the ID of the signal a set sent to its start node. The
+// set keeps the IDs of nested sets apart.
+type setOutputKey struct {
+	set *SimpleSetNode
+}
+
+// wait registers the signal sent to the start node and returns the channel receiving the
+// output of the final node for it. The signal is returned with the ID in its context.
+func (n *SimpleSetNode) wait(subSig node.Signal) (node.Signal, <-chan node.Signal) {
+	out := make(chan node.Signal, 1)
+	n.waitMu.Lock()
+	n.waiting[subSig.ID] = out
+	n.waitMu.Unlock()
+	return subSig.WithContext(context.WithValue(subSig.Context(), setOutputKey{set: n}, subSig.ID)), out
+}
+
+// stopWaiting removes the signal registered by wait, releasing its output if it arrived
+func (n *SimpleSetNode) stopWaiting(subSig node.Signal, out <-chan node.Signal) {
+	n.waitMu.Lock()
+	delete(n.waiting, subSig.ID)
+	n.waitMu.Unlock()
+	select {
+	case recSig := <-out:
+		ConsumeSignal(recSig)
+	default:
+	}
+}
+
+// collectOutput hands each output of the final node to the signal waiting for it until ctx
+// is done. Output no signal waits for, because the set stopped waiting or the final node
+// was reached more than once, is discarded.
+func (n *SimpleSetNode) collectOutput(ctx context.Context) {
+	for {
+		select {
+		case recSig := <-n.collector.InputCh():
+			id, _ := recSig.Context().Value(setOutputKey{set: n}).(string)
+			n.waitMu.Lock()
+			out, ok := n.waiting[id]
+			delete(n.waiting, id)
+			if ok {
+				out <- recSig
+			}
+			n.waitMu.Unlock()
+			if !ok {
+				n.LogSignal(node.LevelWarn, recSig, "Discarding output of the final node no signal is waiting for")
+				ConsumeSignal(recSig)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// processSignal forwards the signal's task to the start node, waits for the final node
+// to emit its output, and sends the result to the connected nodes.
+func (n *SimpleSetNode) processSignal(sig node.Signal) {
+	var err error
+	ctx, done := n.BeginSignal(sig)
+	defer done()
+
+	if n.startNode == nil || n.finalNode == nil {
+		n.Fail(sig, fmt.Errorf("start and final nodes are required"))
+		return
+	}
+
+	sig, err = n.PreProcessSignal(ctx, sig)
+	if err != nil {
+		n.Fail(sig, err)
+		return
+	}
+
+	sig.Status = StatusInProcess
+
+	// The set's own coordinator, if any, tracks the sub-graph run
+	subCtx := ctx
+	if n.coordinator != nil {
+		subCtx = n.coordinator.Begin(sig)
+		defer n.coordinator.End(sig)
+	}
+
+	// The sub-graph receives the same task the set received
+	n.LogSignal(node.LevelDebug, sig, "Sending Signal", "target", n.startNode.ID())
+	failures := make(chan node.Signal, 1)
+	subSig, out := n.wait(watchFailures(NewSignalFromSignal(n.startNode.ID(), n.ID(), sig), failures))
+	defer n.stopWaiting(subSig, out)
+	subSig.Task = sig.Task
+	DispatchSignal(subSig)
+	select {
+	case n.startNode.InputCh() <- subSig:
+	case <-ctx.Done():
+		ConsumeSignal(subSig)
+		n.Fail(sig, fmt.Errorf("context timeout or cancellation while sending signal to node %s: %v", n.startNode.ID(), ctx.Err()))
+		return
+	case <-subCtx.Done():
+		ConsumeSignal(subSig)
+		n.Fail(sig, fmt.Errorf("set coordinator cancelled while sending signal to node %s: %v", n.startNode.ID(), subCtx.Err()))
+		return
+	}
+
+	// Wait for the final node to produce its output, or a node of the sub-graph to fail
+	var recSig node.Signal
+	select {
+	case recSig = <-out:
+		ConsumeSignal(recSig)
+	case failed := <-failures:
+		n.Fail(sig, fmt.Errorf("%s: %s", failed.NodeID, failed.Err))
+		return
+	case <-ctx.Done():
+		n.Fail(sig, fmt.Errorf("context timeout or cancellation while waiting for node %s: %v", n.finalNode.ID(), ctx.Err()))
+		return
+	case <-subCtx.Done():
+		n.Fail(sig, fmt.Errorf("set coordinator cancelled while waiting for node %s: %v", n.finalNode.ID(), subCtx.Err()))
+		return
+	}
+
+	if recSig.Err != "" {
+		n.Fail(sig, fmt.Errorf("%s: %s", n.finalNode.ID(), recSig.Err))
+		return
+	}
+
+	// The collector receives the final node's Result as its Task
+	sig.Result = recSig.Task
+	sig.ParentIDs = append(sig.ParentIDs, recSig.ParentIDs...)
+	sig.Meta = node.MergeMetadata(sig.Meta, recSig.Meta)
+	sig.Status = StatusSuccess
+
+	sig, err = n.PostProcessSignal(ctx, sig)
+	if err != nil {
+		n.Fail(sig, err)
+		return
+	}
+
+	if err := n.SendToConnected(ctx, sig); err != nil {
+		n.Fail(sig, err)
+		return
+	}
+}
+
+// Start starts the set and every node of its sub-graph that is not running.
+func (n *SimpleSetNode) Start(ctx context.Context) {
+	n.EmptyNode.Start(ctx)
+	n.lifeMu.Lock()
+	if n.startNode == nil {
+		n.lifeMu.Unlock()
+		return
+	}
+	if n.subGraph == nil {
+		n.subGraph = NewGraph(n.startNode)
+	}
+	subGraph := n.subGraph
+	if n.collected == nil {
+		collectCtx, stop := context.WithCancel(ctx)
+		collected := make(chan struct{})
+		n.collect, n.collected = stop, collected
+		go func() {
+			n.collectOutput(collectCtx)
+			n.lifeMu.Lock()
+			if n.collected == collected {
+				n.collect, n.collected = nil, nil
+			}
+			n.lifeMu.Unlock()
+			stop()
+			close(collected)
+		}()
+	}
+	n.lifeMu.Unlock()
+	subGraph.Start(ctx)
+}
+
+// Stop stops the set and every node of its sub-graph, blocking until they have exited.
+func (n *SimpleSetNode) Stop() {
+	n.EmptyNode.Stop()
+	n.lifeMu.Lock()
+	subGraph, stopCollect, collected := n.subGraph, n.collect, n.collected
+	n.subGraph, n.collect, n.collected = nil, nil, nil
+	n.lifeMu.Unlock()
+	if subGraph != nil {
+		subGraph.Stop()
+	}
+	if stopCollect != nil {
+		stopCollect()
+		<-collected
+	}
+}
+
+// SetStartNode sets the node that receives signals sent to the set. The sub-graph of a
+// running set is built from its start node, so the change is rejected until the set is stopped.
+func (n *SimpleSetNode) SetStartNode(start node.Node) {
+	if n.Running() {
+		n.LogAt(node.LevelError, "Cannot change the start node of a running set", "start", nodeID(start))
+		return
+	}
+	n.startNode = start
+}
+
+// SetFinalNode sets the node whose output becomes the output of the set.
+// The final node is connected to an internal collector to capture its output, a
+// replaced final node is disconnected from it. The change is rejected while the set is running.
+func (n *SimpleSetNode) SetFinalNode(final node.Node) {
+	if n.Running() {
+		n.LogAt(node.LevelError, "Cannot change the final node of a running set", "final", nodeID(final))
+		return
+	}
+	if n.finalNode == final {
+		return
+	}
+	if prev, ok := n.finalNode.(interface{ Disconnect(...node.Node) }); ok {
+		prev.Disconnect(n.collector)
+	}
+	n.finalNode = final
+	if final == nil {
+		return
+	}
+	if con, ok := final.(interface{ Nodes() []node.Node }); ok && slices.Contains(con.Nodes(), node.Node(n.collector)) {
+		return
+	}
+	final.Connect(n.collector)
+}
+
+// nodeID returns the ID of n, or an empty string if n is nil
+func nodeID(n node.Node) string {
+	if n == nil {
+		return ""
+	}
+	return n.ID()
+}
+
+// SetCoordinator sets the Coordinator used to synchronize the sub-graph.
+func (n *SimpleSetNode) SetCoordinator(coordinator node.Coordinator) {
+	n.coordinator = coordinator
+}
+
+// StartNode returns the entry node of the sub-graph.
+func (n *SimpleSetNode) StartNode() node.Node {
+	return n.startNode
+}
+
+// FinalNode returns the node whose output is the output of the set.
+func (n *SimpleSetNode) FinalNode() node.Node {
+	return n.finalNode
+}
+
+// Coordinator returns the Coordinator associated with the set.
+func (n *SimpleSetNode) Coordinator() node.Coordinator {
+	return n.coordinator
+}
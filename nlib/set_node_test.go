@@ -0,0 +1,315 @@
+package nlib_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTransformNode returns a pass-through node whose after hook sets the Result using fn
+func newTransformNode(mgr node.StateManager, id string, fn func(string) string) node.Node {
+	after := func(sig node.Signal) (node.Signal, error) {
+		sig.Result = nlib.NewTextCarrier(fn(sig.Task.String()))
+		return sig, nil
+	}
+	return nlib.NewSimpleBranchNode(mgr, node.Options{ID: id, Hooks: nlib.NewSimpleNodeHooks(nil, after)})
+}
+
+func newCollectorNode(id string) *nlib.EmptyNode {
+	n := &nlib.EmptyNode{}
+	n.SetID(id)
+	n.SetInputCh(make(chan node.Signal, 10))
+	return n
+}
+
+func TestSimpleSetNode_ForwardsFinalOutput(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+
+	upper := newTransformNode(mgr, "upper", strings.ToUpper)
+	exclaim := newTransformNode(mgr, "exclaim", func(s string) string { return s + "!" })
+	upper.Connect(exclaim)
+
+	set := nlib.NewSimpleSetNode(upper, exclaim, mgr, node.Options{ID: "set"})
+	out := newCollectorNode("out")
+	set.Connect(out)
+	startGraph(t, set)
+
+	set.InputCh() <- node.Signal{NodeID: set.ID(), Task: nlib.NewTextCarrier("hello")}
+
+	select {
+	case sig := <-out.InputCh():
+		assert.Equal(t, "HELLO!", sig.Task.String())
+		assert.Equal(t, "set", sig.FromNodeID)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Signal was not sent from set node")
+	}
+}
+
+func TestSimpleSetNode_PartitionerFactory(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+
+	build := func(setID string) (node.Node, node.Node) {
+		upper := newTransformNode(mgr, setID+"-upper", strings.ToUpper)
+		trim := newTransformNode(mgr, setID+"-trim", strings.TrimSpace)
+		upper.Connect(trim)
+		return upper, trim
+	}
+	factory := nlib.GenerateSetNodeFactory(build, mgr, "set", node.Options{})
+
+	split := func(s string) ([]string, error) { return strings.Split(s, ","), nil }
+	join := func(parts []string) (string, error) { return strings.Join(parts, "+"), nil }
+	part := nlib.NewSimplePartitionerNode(split, join, factory, mgr, node.Options{ID: "partitioner"})
+	out := newCollectorNode("out")
+	part.Connect(out)
+	startGraph(t, part)
+
+	part.InputCh() <- node.Signal{NodeID: part.ID(), Task: nlib.NewTextCarrier("a ,a ,a")}
+
+	select {
+	case sig := <-out.InputCh():
+		assert.Equal(t, "A+A+A", sig.Task.String())
+	case <-time.After(2 * time.Second):
+		t.Fatal("Signal was not sent from partitioner node")
+	}
+}
+
+func TestSimpleSetNode_Setters(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	set := nlib.NewSimpleSetNode(nil, nil, mgr, node.Options{ID: "set"})
+	assert.Nil(t, set.StartNode())
+	assert.Nil(t, set.FinalNode())
+
+	start := newCollectorNode("start")
+	final := newCollectorNode("final")
+	set.SetStartNode(start)
+	set.SetFinalNode(final)
+
+	assert.Equal(t, start, set.StartNode())
+	assert.Equal(t, final, set.FinalNode())
+	assert.Len(t, final.Nodes(), 1)
+
+	// Setting the same final node again does not connect it twice
+	set.SetFinalNode(final)
+	assert.Len(t, final.Nodes(), 1)
+
+	// A replaced final node no longer sends its output to the set
+	last := newCollectorNode("last")
+	set.SetFinalNode(last)
+	assert.Equal(t, last, set.FinalNode())
+	assert.Empty(t, final.Nodes())
+	assert.Len(t, last.Nodes(), 1)
+}
+
+func TestSimpleSetNode_SettersWhileRunning(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	upper := newTransformNode(mgr, "upper", strings.ToUpper)
+	set := nlib.NewSimpleSetNode(upper, upper, mgr, node.Options{ID: "set"})
+	out := newCollectorNode("out")
+	set.Connect(out)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	set.Start(ctx)
+
+	// The sub-graph of a running set was built from its start node, changes are rejected
+	lower := newTransformNode(mgr, "lower", strings.ToLower)
+	set.SetStartNode(lower)
+	set.SetFinalNode(lower)
+	assert.Equal(t, upper, set.StartNode())
+	assert.Equal(t, upper, set.FinalNode())
+	assert.Empty(t, nlib.Successors(lower))
+
+	set.InputCh() <- node.Signal{NodeID: set.ID(), Task: nlib.NewTextCarrier("hello")}
+	select {
+	case sig := <-out.InputCh():
+		assert.Equal(t, "HELLO", sig.Task.String())
+	case <-ctx.Done():
+		t.Fatal("Signal was not sent from set node")
+	}
+
+	// Once stopped the set accepts the change
+	set.Stop()
+	set.SetStartNode(lower)
+	set.SetFinalNode(lower)
+	assert.Equal(t, lower, set.StartNode())
+	assert.Equal(t, lower, set.FinalNode())
+}
+
+// newFailingNode returns a node whose after hook fails every signal
+func newFailingNode(mgr node.StateManager, id string) node.Node {
+	after := func(sig node.Signal) (node.Signal, error) {
+		return sig, errors.New("broken")
+	}
+	return nlib.NewSimpleBranchNode(mgr, node.Options{ID: id, Hooks: nlib.NewSimpleNodeHooks(nil, after)})
+}
+
+func TestSimpleSetNode_SubGraphFails(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	upper := newTransformNode(mgr, "upper", strings.ToUpper)
+	broken := newFailingNode(mgr, "broken")
+	upper.Connect(broken)
+	set := nlib.NewSimpleSetNode(upper, broken, mgr, node.Options{ID: "set"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	sig, err := nlib.Run(ctx, set, node.Signal{Task: nlib.NewTextCarrier("hello")})
+	assert.ErrorContains(t, err, "failed at node broken: broken")
+	assert.NotErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"broken: broken"}, failuresOf(mgr.FilterRunHistory(sig.RunID), "set"))
+}
+
+// failuresOf returns the errors of the node in the history
+func failuresOf(history []node.Signal, nodeID string) []string {
+	errs := []string{}
+	for _, h := range history {
+		if h.NodeID == nodeID && h.Err != "" {
+			errs = append(errs, h.Err)
+		}
+	}
+	return errs
+}
+
+func TestSimplePartitionerNode_Failures(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	split := func(s string) ([]string, error) { return strings.Split(s, ","), nil }
+	join := func(parts []string) (string, error) { return strings.Join(parts, "+"), nil }
+	factory := func(count int) []node.Node {
+		nodes := []node.Node{}
+		for i := 0; i < count; i++ {
+			id := fmt.Sprintf("worker-%d", i)
+			if i == 1 {
+				nodes = append(nodes, newFailingNode(mgr, id))
+				continue
+			}
+			nodes = append(nodes, newTransformNode(mgr, id, strings.ToUpper))
+		}
+		return nodes
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// A failing partition fails the partitioner instead of leaving it waiting
+	part := nlib.NewSimplePartitionerNode(split, join, factory, mgr, node.Options{ID: "part"})
+	sig, err := nlib.Run(ctx, part, node.Signal{Task: nlib.NewTextCarrier("a,b,c")})
+	assert.ErrorContains(t, err, "failed at node worker-1: broken")
+	assert.NotErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"partition node worker-1: broken"}, failuresOf(mgr.FilterRunHistory(sig.RunID), "part"))
+
+	// So does an error of the partition function
+	badSplit := func(string) ([]string, error) { return nil, errors.New("cannot split") }
+	part = nlib.NewSimplePartitionerNode(badSplit, join, factory, mgr, node.Options{ID: "part"})
+	sig, err = nlib.Run(ctx, part, node.Signal{Task: nlib.NewTextCarrier("a,b,c")})
+	assert.ErrorContains(t, err, "partitioning failed: cannot split")
+	assert.Equal(t, nlib.StatusFail, sig.Status)
+}
+
+// newLateNode creates a node that forwards its signals to the connected nodes after
+// the delay, whether or not they are still waiting for them
+func newLateNode(mgr node.StateManager, id string, delay time.Duration) node.Node {
+	n := &nlib.EmptyNode{}
+	n.SetID(id)
+	n.SetStateManager(mgr)
+	n.MakeInputCh()
+	n.SetProcessFunc(func(sig node.Signal) {
+		time.Sleep(delay)
+		for _, target := range n.Nodes() {
+			nlib.DispatchSignal(sig)
+			target.InputCh() <- sig
+		}
+	})
+	return n
+}
+
+func TestSimplePartitionerNode_Timeout(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	split := func(s string) ([]string, error) { return strings.Split(s, ","), nil }
+	join := func(parts []string) (string, error) { return strings.Join(parts, "+"), nil }
+	factory := func(count int) []node.Node {
+		nodes := []node.Node{}
+		for i := 0; i < count; i++ {
+			nodes = append(nodes, newLateNode(mgr, fmt.Sprintf("worker-%d", i), 100*time.Millisecond))
+		}
+		return nodes
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// The partitions that complete after the timeout are released and the run ends
+	part := nlib.NewSimplePartitionerNode(split, join, factory, mgr, node.Options{ID: "part", Timeout: 50 * time.Millisecond})
+	_, err := nlib.Run(ctx, part, node.Signal{Task: nlib.NewTextCarrier("a,b")})
+	assert.ErrorContains(t, err, "cancellation while collecting partitions")
+	assert.NotErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSimplePartitionerNode_ResultOrder(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	split := func(s string) ([]string, error) { return strings.Split(s, ","), nil }
+	join := func(parts []string) (string, error) { return strings.Join(parts, "+"), nil }
+	// Later partitions finish first
+	factory := func(count int) []node.Node {
+		nodes := []node.Node{}
+		for i := 0; i < count; i++ {
+			delay := time.Duration(count-i) * 20 * time.Millisecond
+			nodes = append(nodes, newTransformNode(mgr, fmt.Sprintf("worker-%d", i), func(s string) string {
+				time.Sleep(delay)
+				return strings.ToUpper(s)
+			}))
+		}
+		return nodes
+	}
+
+	part := nlib.NewSimplePartitionerNode(split, join, factory, mgr, node.Options{ID: "part"})
+	sig, err := nlib.Run(context.Background(), part, node.Signal{Task: nlib.NewTextCarrier("a,b,c")})
+	assert.NoError(t, err)
+	assert.Equal(t, "A+B+C", sig.Result.String())
+}
+
+func TestSimpleSetNode_Concurrency(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	sleep := newSleepNode(mgr, node.Options{ID: "sleep", Concurrency: 4})
+	set := nlib.NewSimpleSetNode(sleep, sleep, mgr, node.Options{ID: "set", Concurrency: 4})
+	startGraph(t, set)
+
+	// Each signal gets the output produced for it, not the first one to finish
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	handles := []*nlib.RunHandle{}
+	for _, d := range []string{"300ms", "200ms", "100ms", "0s"} {
+		h, err := nlib.StartRun(ctx, set, node.Signal{Task: nlib.NewTextCarrier(d)})
+		assert.NoError(t, err)
+		handles = append(handles, h)
+	}
+	for _, h := range handles {
+		sig, err := h.Result()
+		assert.NoError(t, err)
+		assert.Equal(t, sig.Task.String(), sig.Result.String())
+	}
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "the signals are processed at once")
+}
+
+func TestSimpleSetNode_DiscardsUnmatchedOutput(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	upper := newTransformNode(mgr, "upper", strings.ToUpper)
+	final := newTransformNode(mgr, "final", func(s string) string { return s + "!" })
+	upper.Connect(final, final) // The final node produces two outputs for each signal
+	set := nlib.NewSimpleSetNode(upper, final, mgr, node.Options{ID: "set"})
+	startGraph(t, set)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for _, task := range []string{"a", "b"} {
+		sig, err := nlib.Run(ctx, set, node.Signal{Task: nlib.NewTextCarrier(task)})
+		assert.NoError(t, err, "the extra output does not hold up the run")
+		assert.Equal(t, strings.ToUpper(task)+"!", sig.Result.String())
+	}
+}
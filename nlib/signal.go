@@ -2,6 +2,7 @@ package nlib
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/dshills/wiggle/node"
 )
@@ -12,11 +13,99 @@ func SignalToLog(sig node.Signal) string {
 	return fmt.Sprintf("{ NodeID: %s, data: %v, Response: %v, Err: %s, Status: %s }", sig.NodeID, sig.Task, sig.Result, sig.Err, sig.Status)
 }
 
+// NewRunID returns a new unique run ID
+func NewRunID() string {
+	id, err := GenerateUUID()
+	if err != nil {
+		return fmt.Sprintf("run-%d", time.Now().UnixNano())
+	}
+	return id
+}
+
+// StampRunID returns the signal with a new run ID if it does not already belong to a run
+func StampRunID(sig node.Signal) node.Signal {
+	if sig.RunID == "" {
+		sig.RunID = NewRunID()
+	}
+	return sig
+}
+
+// NewSignalID returns a new unique signal ID
+func NewSignalID() string {
+	id, err := GenerateUUID()
+	if err != nil {
+		return fmt.Sprintf("sig-%d", time.Now().UnixNano())
+	}
+	return id
+}
+
+// StampSignal returns the signal with an ID, creation time and path if it does not already
+// have them. Nodes stamp the signals they receive so signals sent into the graph directly
+// are tracked like the signals created by NewSignalFromSignal.
+func StampSignal(sig node.Signal, nodeID string) node.Signal {
+	if sig.ID == "" {
+		sig.ID = NewSignalID()
+	}
+	if sig.Created.IsZero() {
+		sig.Created = time.Now()
+	}
+	if len(sig.Path) == 0 && nodeID != "" {
+		sig.Path = []string{nodeID}
+	}
+	return sig
+}
+
+// NewSignalFromSignal creates the signal sent from one node to the next. The result of the
+// incoming signal becomes the task of the new signal. The new signal has its own ID with the
+// incoming signal as its parent, extends its path with the target node, and carries over
+// its run, status, error and context, without what the sending node added to the context.
+// The metadata is carried over according to its propagation rules.
 func NewSignalFromSignal(toID, fromID string, sig node.Signal) node.Signal {
-	return node.Signal{
+	newSig := node.Signal{
+		ID:         NewSignalID(),
 		NodeID:     toID,
 		FromNodeID: fromID,
+		RunID:      sig.RunID,
+		Path:       append(append([]string{}, sig.Path...), toID),
 		Task:       sig.Result,
-		Meta:       sig.Meta,
+		Meta:       sig.Meta.Propagate(),
+		Err:        sig.Err,
+		Status:     sig.Status,
+		Created:    time.Now(),
+	}
+	if sig.ID != "" {
+		newSig.ParentIDs = []string{sig.ID}
+	}
+	return newSig.WithContext(unscoped(sig.Context()))
+}
+
+// Lineage returns the signals in history that sig was derived from, following ParentIDs,
+// followed by sig itself. Ancestors come before the signals derived from them. Pass the
+// history of the run, for example from StateManager.FilterRunHistory, to see how a final
+// answer was produced, including the partitions and branches merged into it.
+func Lineage(history []node.Signal, sig node.Signal) []node.Signal {
+	byID := make(map[string]node.Signal)
+	for _, h := range history {
+		if h.ID != "" {
+			byID[h.ID] = h // The last entry for an ID holds the final state of the signal
+		}
+	}
+
+	lineage := []node.Signal{}
+	visited := make(map[string]bool)
+	var visit func(s node.Signal)
+	visit = func(s node.Signal) {
+		for _, id := range s.ParentIDs {
+			parent, ok := byID[id]
+			if !ok || visited[id] {
+				continue
+			}
+			visited[id] = true
+			visit(parent)
+			lineage = append(lineage, parent)
+		}
 	}
+	visited[sig.ID] = true
+	visit(sig)
+	return append(lineage, sig)
 }
@@ -0,0 +1,169 @@
+package nlib_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/nmock"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type ctxKey string
+
+func TestNewSignalFromSignal_PropagatesContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey("run"), "run-1")
+	sig := node.Signal{NodeID: "a", Result: nlib.NewTextCarrier("out")}.WithContext(ctx)
+
+	newSig := nlib.NewSignalFromSignal("b", "a", sig)
+	assert.Equal(t, "b", newSig.NodeID)
+	assert.Equal(t, "a", newSig.FromNodeID)
+	assert.Equal(t, "out", newSig.Task.String())
+	assert.Equal(t, "run-1", newSig.Context().Value(ctxKey("run")))
+}
+
+func TestNewSignalFromSignal_Lineage(t *testing.T) {
+	sig := node.Signal{ID: "s1", NodeID: "a", RunID: "r1", Path: []string{"a"}, Status: nlib.StatusSuccess, Err: "warning"}
+
+	newSig := nlib.NewSignalFromSignal("b", "a", sig)
+	assert.NotEmpty(t, newSig.ID)
+	assert.NotEqual(t, sig.ID, newSig.ID)
+	assert.Equal(t, []string{"s1"}, newSig.ParentIDs)
+	assert.Equal(t, []string{"a", "b"}, newSig.Path)
+	assert.Equal(t, "r1", newSig.RunID)
+	assert.Equal(t, nlib.StatusSuccess, newSig.Status)
+	assert.Equal(t, "warning", newSig.Err)
+	assert.False(t, newSig.Created.IsZero())
+	assert.Equal(t, []string{"a"}, sig.Path)
+}
+
+func TestLineage_Partitions(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	factory := func(count int) []node.Node {
+		nodes := []node.Node{}
+		for i := 0; i < count; i++ {
+			nodes = append(nodes, newTransformNode(mgr, fmt.Sprintf("worker-%d", i), strings.ToUpper))
+		}
+		return nodes
+	}
+	split := func(s string) ([]string, error) { return strings.Split(s, ","), nil }
+	join := func(parts []string) (string, error) { sort.Strings(parts); return strings.Join(parts, "|"), nil }
+	src := newTransformNode(mgr, "src", strings.TrimSpace)
+	part := nlib.NewSimplePartitionerNode(split, join, factory, mgr, node.Options{ID: "part"})
+	out := newTransformNode(mgr, "out", func(s string) string { return s + "!" })
+	src.Connect(part)
+	part.Connect(out)
+
+	final, err := nlib.Run(context.Background(), src, node.Signal{Task: nlib.NewTextCarrier("a,b,c")})
+	assert.NoError(t, err)
+	assert.Equal(t, "A|B|C!", final.Result.String())
+	assert.Equal(t, []string{"src", "part", "out"}, final.Path)
+	assert.False(t, final.Started.Before(final.Created))
+	assert.False(t, final.Finished.Before(final.Started))
+
+	lineage := nlib.Lineage(mgr.FilterRunHistory(final.RunID), final)
+	chunks := map[string]string{}
+	for _, sig := range lineage {
+		if strings.HasPrefix(sig.NodeID, "worker-") {
+			chunks[sig.Task.String()] = sig.Result.String()
+			assert.Equal(t, []string{"src", "part", sig.NodeID}, sig.Path)
+		}
+	}
+	assert.Equal(t, map[string]string{"a": "A", "b": "B", "c": "C"}, chunks)
+	assert.Equal(t, "src", lineage[0].NodeID)
+	assert.Equal(t, final.ID, lineage[len(lineage)-1].ID)
+}
+
+func TestLineage_Join(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	src, join := newDiamond(mgr)
+	join.SetUpstream("upper", "lower")
+
+	final, err := nlib.Run(context.Background(), src, node.Signal{Task: nlib.NewTextCarrier("Hi")})
+	assert.NoError(t, err)
+	assert.Len(t, final.ParentIDs, 2)
+
+	ids := []string{}
+	for _, sig := range nlib.Lineage(mgr.FilterRunHistory(final.RunID), final) {
+		ids = append(ids, sig.NodeID)
+	}
+	assert.Equal(t, []string{"src", "upper", "lower", "join"}, ids)
+}
+
+func TestSignal_DefaultContext(t *testing.T) {
+	sig := node.Signal{}
+	assert.NotNil(t, sig.Context())
+	assert.NoError(t, sig.Context().Err())
+}
+
+func TestSignal_JSONRoundTrip(t *testing.T) {
+	want := node.Signal{
+		NodeID:     "b",
+		FromNodeID: "a",
+		RunID:      "run-1",
+		Status:     nlib.StatusSuccess,
+		Err:        "",
+		Task:       &nlib.Carrier{TextData: "task", JSONData: []byte(`{"a":1}`), URLData: []string{"http://example.com/a.png"}},
+		Result:     &nlib.Carrier{VectorData: [][]float32{{1, 2}, {3}}, Base64Data: []string{"aGk="}},
+		Meta:       []node.Meta{{Key: "k", Value: "v"}},
+	}
+	sig := want.WithContext(context.WithValue(context.Background(), ctxKey("run"), "run-1"))
+
+	data, err := json.Marshal(sig)
+	assert.NoError(t, err)
+
+	decoded := node.Signal{}
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, want, decoded)
+	assert.Nil(t, decoded.Context().Value(ctxKey("run")))
+
+	// Signals without a Task or Result decode with nil carriers
+	assert.NoError(t, json.Unmarshal([]byte(`{"node_id":"a"}`), &decoded))
+	assert.Equal(t, node.Signal{NodeID: "a"}, decoded)
+}
+
+func newStallingLLM() *nmock.MockLLM {
+	lm := new(nmock.MockLLM)
+	lm.On("Model").Return("mock")
+	lm.On("Chat", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() }).
+		Return(llm.Message{}, context.Canceled)
+	return lm
+}
+
+func TestAINode_RunCancellationAbortsLLM(t *testing.T) {
+	lm := newStallingLLM()
+	mgr := nlib.NewSimpleStateManager(nil)
+	coord := nlib.NewSimpleCoordinator()
+	mgr.SetCoordinator(coord)
+	aiNode := nlib.NewAINode(lm, mgr, node.Options{ID: "ai"})
+	startGraph(t, aiNode)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	aiNode.InputCh() <- node.Signal{NodeID: aiNode.ID(), Task: nlib.NewTextCarrier("stall")}.WithContext(ctx)
+
+	assert.Eventually(t, func() bool { return coord.InFlight(aiNode.ID()) == 1 }, time.Second, 5*time.Millisecond)
+	cancel()
+	assert.NoError(t, coord.WaitForCompletion(aiNode))
+}
+
+func TestAINode_OptionsTimeoutAbortsLLM(t *testing.T) {
+	lm := newStallingLLM()
+	mgr := nlib.NewSimpleStateManager(nil)
+	coord := nlib.NewSimpleCoordinator()
+	mgr.SetCoordinator(coord)
+	aiNode := nlib.NewAINode(lm, mgr, node.Options{ID: "ai", Timeout: 20 * time.Millisecond})
+	startGraph(t, aiNode)
+
+	aiNode.InputCh() <- node.Signal{NodeID: aiNode.ID(), Task: nlib.NewTextCarrier("stall")}
+	assert.NoError(t, coord.WaitForCompletion(aiNode))
+	assert.Equal(t, nlib.StatusFail, mgr.GetState(node.Signal{NodeID: aiNode.ID()}).Status)
+}
@@ -1,58 +1,210 @@
 package nlib
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
 	"github.com/dshills/wiggle/node"
 )
 
-// Compile-time check to ensure SimpleStateManager implements the node.StateManager interface
-var _ node.StateManager = (*SimpleStateManager)(nil)
+// Compile-time check to ensure SimpleStateManager implements the node.RunStateManager interface
+var _ node.RunStateManager = (*SimpleStateManager)(nil)
+var _ node.CheckpointStateManager = (*SimpleStateManager)(nil)
+var _ node.TracingStateManager = (*SimpleStateManager)(nil)
+var _ node.MetricsStateManager = (*SimpleStateManager)(nil)
+var _ node.StructuredLogStateManager = (*SimpleStateManager)(nil)
 
-// SimpleStateManager is a basic implementation of the StateManager interface.
+// DefaultRunRetention is the number of runs a SimpleStateManager keeps the state of
+const DefaultRunRetention = 100
+
+// maxEndedRuns is the number of ended runs remembered so their late updates are dropped
+const maxEndedRuns = 1024
+
+// SimpleStateManager is a basic implementation of the RunStateManager interface.
 // It manages the state of signals and tracks errors and completion for nodes.
+// State is kept per node across all runs as well as per run, so concurrent runs
+// through the same graph do not interfere with each other.
+//
+// The state, history and run-scoped context of a run are kept until EndRun is called.
+// Once more runs than its retention are kept, the least recently updated run that is no
+// longer in flight is ended to make room, see SetRunRetention. Updates arriving for a
+// run after it has ended are counted for their node but not kept for the run.
 type SimpleStateManager struct {
 	stateMap    map[string]node.State
+	runs        map[string]*runState
+	retention   int                 // Runs kept before the least recently updated is ended, 0 keeps every run
+	ended       map[string]struct{} // Recently ended runs
+	endedIDs    []string            // Recently ended runs in the order they ended
+	clock       uint64              // Incremented whenever a run is used
+	runsChanged chan struct{}       // Closed and replaced whenever a run is added or ended
 	mu          sync.Mutex
-	doneChs     []chan struct{}
+	doneCh      chan struct{}
 	nodeWaitID  string
 	waitCh      chan struct{}
 	logger      node.Logger
+	slogger     node.StructuredLogger
 	resourceMgr node.ResourceManager
 	coordinator node.Coordinator
 	historyMgr  node.HistoryManager
 	contextMgr  node.ContextManager
+	checkpoints node.CheckpointStore
+	spans       node.SpanExporter
+	metrics     node.Metrics
 }
 
 // NewSimpleStateManager creates and returns a new instance of SimpleStateManager.
 func NewSimpleStateManager(l node.Logger) *SimpleStateManager {
 	sm := SimpleStateManager{
-		stateMap:   make(map[string]node.State),
-		logger:     l,
-		historyMgr: NewSimpleHistoryManager(),
-		contextMgr: NewSimpleContextManager(),
+		stateMap:    make(map[string]node.State),
+		runs:        make(map[string]*runState),
+		retention:   DefaultRunRetention,
+		ended:       make(map[string]struct{}),
+		runsChanged: make(chan struct{}),
+		doneCh:      make(chan struct{}),
+		historyMgr:  NewSimpleHistoryManager(),
+		contextMgr:  NewSimpleContextManager(),
 	}
+	sm.SetLogger(l)
 	return &sm
 }
 
+// runState holds the state of a single run
+type runState struct {
+	states  map[string]node.State  // NodeID to state within the run
+	signals map[string]node.Signal // NodeID to the last signal processed within the run
+	failed  *node.Signal           // First failing signal of the run
+	changed chan struct{}          // Closed and replaced whenever the run is updated
+	used    uint64                 // Clock of the last use of the run
+	tracker *runTracker            // Tracker of a run started with Run or StartRun, nil for other runs
+}
+
+// inFlight reports whether the run was started with Run or StartRun and has not yet
+// finished. Runs sent to a node directly are never known to be in flight.
+func (r *runState) inFlight() bool {
+	return r.tracker != nil && !r.tracker.hasEnded()
+}
+
+// run returns the state of the run the signal belongs to, creating it if needed. Creating
+// a run may end the least recently used run that is not in flight to stay within the
+// retention. The caller must hold the lock.
+func (s *SimpleStateManager) run(sig node.Signal) *runState {
+	run, ok := s.runs[sig.RunID]
+	if !ok {
+		if s.retention > 0 && len(s.runs) >= s.retention {
+			s.endRun(s.leastRecentRun())
+		}
+		run = &runState{
+			states:  make(map[string]node.State),
+			signals: make(map[string]node.Signal),
+			changed: make(chan struct{}),
+		}
+		s.runs[sig.RunID] = run
+		s.notifyRunsChanged()
+	}
+	if run.tracker == nil {
+		run.tracker = runTrackerFrom(sig)
+	}
+	s.clock++
+	run.used = s.clock
+	return run
+}
+
+// leastRecentRun returns the ID of the run used the longest time ago that is not in
+// flight, or an empty string if every run is in flight. The caller must hold the lock.
+func (s *SimpleStateManager) leastRecentRun() string {
+	oldest := ""
+	var used uint64
+	for id, run := range s.runs {
+		if run.inFlight() {
+			continue
+		}
+		if oldest == "" || run.used < used {
+			oldest, used = id, run.used
+		}
+	}
+	return oldest
+}
+
+// notifyRunsChanged wakes anything waiting for a run to be added or ended. The caller must hold the lock.
+func (s *SimpleStateManager) notifyRunsChanged() {
+	close(s.runsChanged)
+	s.runsChanged = make(chan struct{})
+}
+
+// hasEnded reports whether the run has ended. The caller must hold the lock.
+func (s *SimpleStateManager) hasEnded(runID string) bool {
+	_, ok := s.ended[runID]
+	return ok
+}
+
 // GetState returns the current state of the specified signal.
+// If the signal has a RunID the state of the node within that run is returned,
+// otherwise the state of the node across all runs.
 // If no state exists for the NodeID, it returns a default state with "unknown" status.
 func (s *SimpleStateManager) GetState(signal node.Signal) node.State {
 	s.mu.Lock() // Lock to ensure safe access to stateMap
 	defer s.mu.Unlock()
-	if state, exists := s.stateMap[signal.NodeID]; exists {
+	states := s.stateMap
+	if signal.RunID != "" {
+		run, ok := s.runs[signal.RunID]
+		if !ok {
+			return node.State{Status: "unknown"}
+		}
+		states = run.states
+	}
+	if state, exists := states[signal.NodeID]; exists {
 		return state // Return the found state
 	}
 	return node.State{Status: "unknown"} // Return default state if none found
 }
 
 // UpdateState updates the state of a signal for the corresponding NodeID.
-// It increments the Completed or Failures counters and updates the status.
+// It increments the Completed or Failures counters, updates the status,
+// and records the signal in the history.
 func (s *SimpleStateManager) UpdateState(sig node.Signal) {
+	s.AddHistory(sig)
+	if !s.runEnded(sig.RunID) {
+		s.checkpoint(sig)
+	}
+
 	s.mu.Lock() // Lock to ensure safe modification of stateMap
 	defer s.mu.Unlock()
-	st, ok := s.stateMap[sig.NodeID] // Get the state associated with the signal's NodeID
+	updateNodeState(s.stateMap, sig)
+
+	if sig.RunID != "" && !s.hasEnded(sig.RunID) {
+		run := s.run(sig)
+		updateNodeState(run.states, sig)
+		run.signals[sig.NodeID] = sig
+		if sig.Err != "" && run.failed == nil {
+			run.failed = &sig
+		}
+		close(run.changed) // Wake anything waiting on the run
+		run.changed = make(chan struct{})
+	}
+
+	// If waiting on this NodeID, or a node failed, wake the waiter
+	if s.waitCh != nil && (sig.Err != "" || s.nodeWaitID != "" && s.nodeWaitID == sig.NodeID) {
+		select {
+		case s.waitCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// checkpoint saves the output of a node that completed a signal of a run
+func (s *SimpleStateManager) checkpoint(sig node.Signal) {
+	if s.checkpoints == nil || sig.RunID == "" || sig.Err != "" || sig.Status != StatusSuccess {
+		return
+	}
+	if err := s.checkpoints.Save(sig); err != nil {
+		s.LogAt(node.LevelError, "saving checkpoint", node.LogKeyNodeID, sig.NodeID, node.LogKeyRunID, sig.RunID, node.LogKeySignalID, sig.ID, node.LogKeyError, err)
+	}
+}
+
+// updateNodeState applies the signal to the state of its node
+func updateNodeState(states map[string]node.State, sig node.Signal) {
+	st, ok := states[sig.NodeID] // Get the state associated with the signal's NodeID
 	if !ok {
 		st = node.State{} // Initialize if no state exists for the NodeID
 	}
@@ -60,13 +212,13 @@ func (s *SimpleStateManager) UpdateState(sig node.Signal) {
 	if sig.Err != "" { // Increment the failure counter if there's an error
 		st.Failures++
 	}
-	st.Status = sig.Status      // Update the status of the signal
-	s.stateMap[sig.NodeID] = st // Store the updated state
+	st.Status = sig.Status  // Update the status of the signal
+	states[sig.NodeID] = st // Store the updated state
+}
 
-	// If waiting on this NodeID, signal completion
-	if s.nodeWaitID == sig.NodeID {
-		s.Complete()
-	}
+// CheckpointStore returns the store recording the output of each node, or nil if checkpointing is off
+func (s *SimpleStateManager) CheckpointStore() node.CheckpointStore {
+	return s.checkpoints
 }
 
 func (s *SimpleStateManager) ContextManager() node.ContextManager {
@@ -85,15 +237,37 @@ func (s *SimpleStateManager) Logger() node.Logger {
 	return s.logger
 }
 
+// StructuredLogger returns the logger the nodes write leveled entries to
+func (s *SimpleStateManager) StructuredLogger() node.StructuredLogger {
+	return s.slogger
+}
+
+// Metrics returns the metrics recorded by the nodes, or nil if metrics are off
+func (s *SimpleStateManager) Metrics() node.Metrics {
+	return s.metrics
+}
+
 func (s *SimpleStateManager) ResourceManager() node.ResourceManager {
 	return s.resourceMgr
 }
 
-// Register creates a channel for signaling completion and adds it to the list of done channels.
+// SpanExporter returns the exporter receiving the spans of traced runs, or nil if tracing is off
+func (s *SimpleStateManager) SpanExporter() node.SpanExporter {
+	return s.spans
+}
+
+// Register returns a channel that is closed the next time Complete is called.
+// Every caller shares the same channel so registering does not allocate.
 func (s *SimpleStateManager) Register() chan struct{} {
-	ch := make(chan struct{}, 2)      // Create a new completion channel
-	s.doneChs = append(s.doneChs, ch) // Add it to the list of done channels
-	return ch                         // Return the new channel
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doneCh
+}
+
+// SetCheckpointStore turns on checkpointing. Every successful signal of a run is saved to
+// the store so the run can be resumed with Resume.
+func (s *SimpleStateManager) SetCheckpointStore(store node.CheckpointStore) {
+	s.checkpoints = store
 }
 
 func (s *SimpleStateManager) SetContextManager(con node.ContextManager) {
@@ -108,35 +282,169 @@ func (s *SimpleStateManager) SetHistoryManager(hx node.HistoryManager) {
 	s.historyMgr = hx
 }
 
+// SetLogger sets a logger. Loggers that do not implement node.StructuredLogger receive
+// the entries logged by the nodes formatted as JSON, at every level.
 func (s *SimpleStateManager) SetLogger(logger node.Logger) {
 	s.logger = logger
+	s.slogger = structuredLogger(logger)
+}
+
+// SetStructuredLogger sets the logger the nodes write leveled entries to. Messages passed
+// to Log are logged at info level.
+func (s *SimpleStateManager) SetStructuredLogger(logger node.StructuredLogger) {
+	s.slogger = logger
+	if l, ok := logger.(node.Logger); ok {
+		s.logger = l
+	} else if logger != nil {
+		s.logger = messageLogger{l: logger}
+	} else {
+		s.logger = nil
+	}
+}
+
+// SetMetrics turns on metrics. Nodes record the signals they process and fail, processing
+// and LLM request latency, rate limit waits and the depth of their input queues.
+func (s *SimpleStateManager) SetMetrics(m node.Metrics) {
+	s.metrics = m
 }
 
 func (s *SimpleStateManager) SetResourceManager(resMgr node.ResourceManager) {
 	s.resourceMgr = resMgr
 }
 
-// Complete signals completion to all registered channels.
+// SetSpanExporter turns on tracing. Nodes record a span for each signal they process,
+// with child spans for its hooks, guidance, LLM calls and sends, and export them to exp.
+func (s *SimpleStateManager) SetSpanExporter(exp node.SpanExporter) {
+	s.spans = exp
+}
+
+// Complete signals completion to everything waiting on a registered channel or in WaitFor.
+// It never blocks. Node goroutines are not affected; use Graph.Stop to terminate them.
 func (s *SimpleStateManager) Complete() {
-	if s.logger != nil {
-		s.logger.Log(fmt.Sprintf("{ \"severity\": %q, \"id\": %q, \"msg\": %q }", "info", "STATEMANAGER", "Complete"))
-	}
-	for _, ch := range s.doneChs { // Iterate through all completion channels
-		ch <- struct{}{} // Signal completion
-	}
-	if s.waitCh != nil {
-		s.waitCh <- struct{}{}
-	}
+	s.LogAt(node.LevelDebug, "Complete")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	close(s.doneCh)                // Wake everyone holding the current channel
+	s.doneCh = make(chan struct{}) // Later registrations wait for the next Complete
 }
 
-// WaitFor sets the node to wait for its UpdateState call
-// if Node is nil wait forever
+// WaitFor blocks until the node's state is updated, a node fails or Complete is called.
+// If Node is nil it waits for a node to fail or Complete.
 func (s *SimpleStateManager) WaitFor(n node.Node) {
+	waitCh := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.nodeWaitID = ""
 	if n != nil {
 		s.nodeWaitID = n.ID() // Store the NodeID to wait on
 	}
-	s.waitCh = make(chan struct{})
-	<-s.waitCh
+	s.waitCh = waitCh
+	doneCh := s.doneCh
+	s.mu.Unlock()
+
+	select {
+	case <-waitCh:
+	case <-doneCh:
+	}
+}
+
+// WaitForRun blocks until the node has processed a signal for the run and returns that signal.
+// If a node of the run fails first, the failing signal is returned with an error.
+// If n is nil it waits for the run to fail or ctx to be done. An error is returned if the
+// run ends before the node has processed its signal. Waiting for a run that has not
+// started yet does not create it.
+func (s *SimpleStateManager) WaitForRun(ctx context.Context, runID string, n node.Node) (node.Signal, error) {
+	for {
+		s.mu.Lock()
+		if s.hasEnded(runID) {
+			s.mu.Unlock()
+			return node.Signal{}, fmt.Errorf("run %s has ended", runID)
+		}
+		run, ok := s.runs[runID]
+		if !ok {
+			runsChanged := s.runsChanged
+			s.mu.Unlock()
+			select {
+			case <-runsChanged:
+				continue
+			case <-ctx.Done():
+				return node.Signal{}, fmt.Errorf("run %s: %w", runID, ctx.Err())
+			}
+		}
+		if n != nil {
+			if sig, ok := run.signals[n.ID()]; ok {
+				s.mu.Unlock()
+				if sig.Err != "" {
+					return sig, fmt.Errorf("run %s failed at node %s: %s", runID, sig.NodeID, sig.Err)
+				}
+				return sig, nil
+			}
+		}
+		if run.failed != nil {
+			sig := *run.failed
+			s.mu.Unlock()
+			return sig, fmt.Errorf("run %s failed at node %s: %s", runID, sig.NodeID, sig.Err)
+		}
+		changed := run.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return node.Signal{}, fmt.Errorf("run %s: %w", runID, ctx.Err())
+		}
+	}
+}
+
+// EndRun releases the state, history and run-scoped context held for the run. Later
+// updates for the run are not kept and WaitForRun returns an error for it.
+func (s *SimpleStateManager) EndRun(runID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.endRun(runID)
+}
+
+// SetRunRetention sets the number of runs whose state is kept. When a new run would
+// exceed it the least recently updated run is ended. Runs started with Run or StartRun
+// are not ended while they are in flight, so more runs than the retention are kept while
+// that many are running. Zero keeps every run until EndRun is called.
+func (s *SimpleStateManager) SetRunRetention(runs int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retention = runs
+}
+
+// runEnded reports whether the run has ended
+func (s *SimpleStateManager) runEnded(runID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hasEnded(runID)
+}
+
+// endRun releases the run and remembers that it ended. The caller must hold the lock.
+func (s *SimpleStateManager) endRun(runID string) {
+	if runID == "" {
+		return
+	}
+	if run, ok := s.runs[runID]; ok {
+		close(run.changed) // Wake anything waiting on the run
+		delete(s.runs, runID)
+	}
+	if !s.hasEnded(runID) {
+		s.ended[runID] = struct{}{}
+		s.endedIDs = append(s.endedIDs, runID)
+		if len(s.endedIDs) > maxEndedRuns {
+			delete(s.ended, s.endedIDs[0])
+			s.endedIDs = s.endedIDs[1:]
+		}
+		s.notifyRunsChanged()
+	}
+
+	if hx, ok := s.historyMgr.(node.RunHistoryManager); ok {
+		hx.RemoveRun(runID)
+	}
+	if cm, ok := s.contextMgr.(interface{ RemoveRun(string) }); ok {
+		cm.RemoveRun(runID)
+	}
 }
 
 func (s *SimpleStateManager) Log(msg string) {
@@ -145,6 +453,13 @@ func (s *SimpleStateManager) Log(msg string) {
 	}
 }
 
+// LogAt writes a leveled entry to the structured logger if it is enabled for the level
+func (s *SimpleStateManager) LogAt(level node.Level, msg string, fields ...any) {
+	if s.slogger != nil && s.slogger.Enabled(level) {
+		s.slogger.LogAt(level, msg, fields...)
+	}
+}
+
 func (s *SimpleStateManager) GetContext(key string) (node.DataCarrier, error) {
 	if s.contextMgr == nil {
 		return nil, fmt.Errorf("missing ContextManager")
@@ -166,8 +481,11 @@ func (s *SimpleStateManager) RemoveContext(key string) {
 	s.contextMgr.RemoveContext(key)
 }
 
+// AddHistory adds the signal to the history unless its run has ended
 func (s *SimpleStateManager) AddHistory(n node.Signal) {
-	if s.historyMgr == nil {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.historyMgr == nil || s.hasEnded(n.RunID) {
 		return
 	}
 	s.historyMgr.AddHistory(n)
@@ -186,3 +504,26 @@ func (s *SimpleStateManager) FilterHistory(nodeid string) []node.Signal {
 	}
 	return s.historyMgr.Filter(nodeid)
 }
+
+// FilterRunHistory returns the history of the run. A HistoryManager that does not
+// implement node.RunHistoryManager has its full history filtered.
+func (s *SimpleStateManager) FilterRunHistory(runID string) []node.Signal {
+	if s.historyMgr == nil {
+		return nil
+	}
+	if hx, ok := s.historyMgr.(node.RunHistoryManager); ok {
+		return hx.FilterRun(runID)
+	}
+	return filterRun(s.historyMgr.GetHistory(), runID)
+}
+
+// filterRun returns the signals of the history that belong to the run
+func filterRun(history []node.Signal, runID string) []node.Signal {
+	sigList := []node.Signal{}
+	for _, sig := range history {
+		if sig.RunID == runID {
+			sigList = append(sigList, sig)
+		}
+	}
+	return sigList
+}
@@ -0,0 +1,232 @@
+package nlib_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimpleStateManager_ConcurrentRuns(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	upper := newTransformNode(mgr, "upper", strings.ToUpper)
+	exclaim := newTransformNode(mgr, "exclaim", func(s string) string { return s + "!" })
+	upper.Connect(exclaim)
+	startGraph(t, upper)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			runID := nlib.NewRunID()
+			task := fmt.Sprintf("input %d", i)
+			upper.InputCh() <- node.Signal{NodeID: upper.ID(), RunID: runID, Task: nlib.NewTextCarrier(task)}
+
+			sig, err := mgr.WaitForRun(ctx, runID, exclaim)
+			assert.NoError(t, err)
+			assert.Equal(t, runID, sig.RunID)
+			assert.Equal(t, strings.ToUpper(task)+"!", sig.Result.String())
+			assert.Len(t, mgr.FilterRunHistory(runID), 2)
+			assert.Equal(t, 1, mgr.GetState(node.Signal{NodeID: exclaim.ID(), RunID: runID}).Completed)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, mgr.GetState(node.Signal{NodeID: exclaim.ID()}).Completed)
+}
+
+func TestSimpleStateManager_WaitForRunFailure(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	runID := nlib.NewRunID()
+
+	go mgr.UpdateState(node.Signal{NodeID: "first", RunID: runID, Err: "boom", Status: nlib.StatusFail})
+
+	sig, err := mgr.WaitForRun(context.Background(), runID, newCollectorNode("last"))
+	assert.Error(t, err)
+	assert.Equal(t, "first", sig.NodeID)
+	assert.Contains(t, err.Error(), "boom")
+}
+
+func TestSimpleStateManager_WaitForRunContext(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := mgr.WaitForRun(ctx, "never", newCollectorNode("last"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSimpleStateManager_EndRun(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	mgr.UpdateState(node.Signal{NodeID: "a", RunID: "run-1", Status: nlib.StatusSuccess})
+	mgr.UpdateState(node.Signal{NodeID: "a", RunID: "run-2", Status: nlib.StatusSuccess})
+	mgr.SetContext(nlib.RunContextKey("run-1", "a"), nlib.NewTextCarrier("ctx"))
+
+	mgr.EndRun("run-1")
+
+	assert.Equal(t, "unknown", mgr.GetState(node.Signal{NodeID: "a", RunID: "run-1"}).Status)
+	assert.Equal(t, nlib.StatusSuccess, mgr.GetState(node.Signal{NodeID: "a", RunID: "run-2"}).Status)
+	assert.Empty(t, mgr.FilterRunHistory("run-1"))
+	assert.Len(t, mgr.FilterRunHistory("run-2"), 1)
+	_, err := mgr.GetContext(nlib.RunContextKey("run-1", "a"))
+	assert.Error(t, err)
+
+	// Late updates do not bring the run back
+	mgr.UpdateState(node.Signal{NodeID: "b", RunID: "run-1", Status: nlib.StatusSuccess})
+	mgr.AddHistory(node.Signal{NodeID: "c", RunID: "run-1"})
+	assert.Equal(t, "unknown", mgr.GetState(node.Signal{NodeID: "b", RunID: "run-1"}).Status)
+	assert.Empty(t, mgr.FilterRunHistory("run-1"))
+	assert.Equal(t, 1, mgr.GetState(node.Signal{NodeID: "b"}).Completed)
+	_, err = mgr.WaitForRun(context.Background(), "run-1", newCollectorNode("a"))
+	assert.EqualError(t, err, "run run-1 has ended")
+}
+
+func TestSimpleStateManager_EndRunWakesWaiter(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := mgr.WaitForRun(context.Background(), "run-1", newCollectorNode("last"))
+		errCh <- err
+	}()
+	time.Sleep(20 * time.Millisecond) // Let the waiter block on the run
+	mgr.EndRun("run-1")
+
+	select {
+	case err := <-errCh:
+		assert.EqualError(t, err, "run run-1 has ended")
+	case <-time.After(2 * time.Second):
+		t.Fatal("WaitForRun did not return when the run ended")
+	}
+}
+
+func TestSimpleStateManager_RunRetention(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	mgr.SetRunRetention(2)
+	mgr.UpdateState(node.Signal{NodeID: "a", RunID: "run-1", Status: nlib.StatusSuccess})
+	mgr.UpdateState(node.Signal{NodeID: "a", RunID: "run-2", Status: nlib.StatusSuccess})
+	mgr.UpdateState(node.Signal{NodeID: "b", RunID: "run-1", Status: nlib.StatusSuccess})
+
+	// run-2 is the least recently updated run, it makes room for run-3
+	mgr.UpdateState(node.Signal{NodeID: "a", RunID: "run-3", Status: nlib.StatusSuccess})
+	assert.Empty(t, mgr.FilterRunHistory("run-2"))
+	assert.Equal(t, "unknown", mgr.GetState(node.Signal{NodeID: "a", RunID: "run-2"}).Status)
+	assert.Len(t, mgr.FilterRunHistory("run-1"), 2)
+	assert.Len(t, mgr.FilterRunHistory("run-3"), 1)
+
+	// Runs through the graph are released the same way
+	for i := 0; i < 5; i++ {
+		_, err := nlib.Run(context.Background(), newTransformNode(mgr, "upper", strings.ToUpper), node.Signal{Task: nlib.NewTextCarrier("hi")})
+		assert.NoError(t, err)
+	}
+	assert.Len(t, mgr.GetHistory(), 2)
+}
+
+func TestSimpleStateManager_RunRetentionKeepsRunsInFlight(t *testing.T) {
+	const runs = 10
+	mgr := nlib.NewSimpleStateManager(nil)
+	mgr.SetRunRetention(2)
+
+	// Every run passes upper and is then held in the gate node until all of them are there
+	var arrived sync.WaitGroup
+	arrived.Add(runs)
+	release := make(chan struct{})
+	wait := func(sig node.Signal) (node.Signal, error) {
+		arrived.Done()
+		<-release
+		sig.Result = sig.Task
+		return sig, nil
+	}
+	upper := newTransformNode(mgr, "upper", strings.ToUpper)
+	gate := nlib.NewSimpleBranchNode(mgr, node.Options{ID: "gate", Concurrency: runs, Hooks: nlib.NewSimpleNodeHooks(wait, nil)})
+	upper.Connect(gate)
+	startGraph(t, upper)
+
+	handles := []*nlib.RunHandle{}
+	for i := 0; i < runs; i++ {
+		h, err := nlib.StartRun(context.Background(), upper, node.Signal{Task: nlib.NewTextCarrier(fmt.Sprintf("input %d", i))})
+		assert.NoError(t, err)
+		handles = append(handles, h)
+	}
+	arrived.Wait()
+	close(release)
+
+	for i, h := range handles {
+		sig, err := h.Result()
+		assert.NoError(t, err)
+		if assert.NotNil(t, sig.Result) {
+			assert.Equal(t, fmt.Sprintf("INPUT %d", i), sig.Result.String())
+		}
+	}
+}
+
+func TestSimpleStateManager_WaitForRunDoesNotCreateRun(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	mgr.SetRunRetention(1)
+	mgr.UpdateState(node.Signal{NodeID: "a", RunID: "run-1", Status: nlib.StatusSuccess})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := mgr.WaitForRun(ctx, "run-2", newCollectorNode("a"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Len(t, mgr.FilterRunHistory("run-1"), 1)
+
+	// A waiter is woken once the run starts
+	sigCh := make(chan node.Signal, 1)
+	go func() {
+		sig, err := mgr.WaitForRun(context.Background(), "run-3", newCollectorNode("a"))
+		assert.NoError(t, err)
+		sigCh <- sig
+	}()
+	time.Sleep(20 * time.Millisecond) // Let the waiter block on the missing run
+	mgr.UpdateState(node.Signal{NodeID: "a", RunID: "run-3", Status: nlib.StatusSuccess})
+	select {
+	case sig := <-sigCh:
+		assert.Equal(t, "run-3", sig.RunID)
+	case <-time.After(2 * time.Second):
+		t.Fatal("WaitForRun did not return when the run started")
+	}
+}
+
+func TestSimpleStateManager_FailureWakesWaitFor(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	done := mgr.Register()
+	woken := make(chan struct{})
+	go func() {
+		mgr.WaitFor(newCollectorNode("last"))
+		close(woken)
+	}()
+	time.Sleep(20 * time.Millisecond) // Let the waiter block
+
+	fail := func(sig node.Signal) (node.Signal, error) { return sig, errors.New("boom") }
+	broken := nlib.NewSimpleBranchNode(mgr, node.Options{ID: "broken", Hooks: nlib.NewSimpleNodeHooks(fail, nil)})
+	_, err := nlib.Run(context.Background(), broken, node.Signal{Task: nlib.NewTextCarrier("hi")})
+	assert.Error(t, err)
+
+	select {
+	case <-woken:
+	case <-time.After(2 * time.Second):
+		t.Fatal("WaitFor did not return when a node failed")
+	}
+	select {
+	case <-done:
+		t.Fatal("a failed run completed the StateManager for every run")
+	default:
+	}
+}
+
+func TestStampRunID(t *testing.T) {
+	sig := nlib.StampRunID(node.Signal{})
+	assert.NotEmpty(t, sig.RunID)
+	assert.Equal(t, "run-1", nlib.StampRunID(node.Signal{RunID: "run-1"}).RunID)
+}
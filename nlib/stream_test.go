@@ -0,0 +1,85 @@
+package nlib_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/nmock"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// streamLLM streams its reply in the given pieces
+type streamLLM struct {
+	*nmock.MockLLM
+	pieces []string
+}
+
+func newStreamLLM(pieces ...string) *streamLLM {
+	return &streamLLM{MockLLM: newReplyLLM(strings.Join(pieces, ""), nil), pieces: pieces}
+}
+
+func (s *streamLLM) ChatStream(ctx context.Context, _ llm.MessageList) (<-chan llm.StreamEvent, error) {
+	ch := make(chan llm.StreamEvent)
+	go func() {
+		defer close(ch)
+		for _, p := range s.pieces {
+			select {
+			case ch <- llm.StreamEvent{Delta: p}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		msg := llm.Message{Role: llm.RoleAssistant, Content: strings.Join(s.pieces, "")}
+		ch <- llm.StreamEvent{Done: true, Response: llm.Response{Message: msg, Usage: llm.Usage{PromptTokens: 1, CompletionTokens: len(s.pieces)}}}
+	}()
+	return ch, nil
+}
+
+func TestAINode_Stream(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	lm := newStreamLLM("Hel", "lo", " world")
+	mu := sync.Mutex{}
+	deltas := []string{}
+	ai := nlib.NewAINode(lm, mgr, node.Options{ID: "ai"})
+	ai.SetStreamFunc(func(sig node.Signal, delta string) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "ai", sig.NodeID)
+		deltas = append(deltas, delta)
+	})
+
+	rec := nlib.NewRecorder()
+	sig, err := nlib.Run(nlib.WithRecorder(context.Background(), rec), ai, node.Signal{Task: nlib.NewTextCarrier("hello")})
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello world", sig.Result.String())
+	assert.Equal(t, []string{"Hel", "lo", " world"}, deltas)
+	lm.AssertNotCalled(t, "Chat", mock.Anything, mock.Anything)
+
+	// A replayed response is streamed at once
+	buf := bytes.Buffer{}
+	replayed := nlib.NewAINode(newReplyLLM("", nil), mgr, node.Options{ID: "ai"})
+	replayed.SetStreamFunc(nlib.StreamWriter(&buf))
+	sig, err = nlib.Replay(context.Background(), replayed, rec.Recording())
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello world", sig.Result.String())
+	assert.Equal(t, "Hello world", buf.String())
+}
+
+func TestAINode_StreamWithoutStreamer(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	buf := bytes.Buffer{}
+	ai := nlib.NewAINode(newReplyLLM("HELLO", nil), mgr, node.Options{ID: "ai"})
+	ai.SetStreamFunc(nlib.StreamWriter(&buf))
+
+	sig, err := nlib.Run(context.Background(), ai, node.Signal{Task: nlib.NewTextCarrier("hello")})
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO", sig.Result.String())
+	assert.Equal(t, "HELLO", buf.String())
+}
@@ -0,0 +1,184 @@
+package nlib
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/node"
+)
+
+// TraceSpan is a span being recorded. It is exported to the node.SpanExporter when it ends.
+// The methods of a nil TraceSpan do nothing, so spans can be recorded unconditionally
+// whether or not a run is traced.
+type TraceSpan struct {
+	mu     sync.Mutex
+	span   node.Span
+	exp    node.SpanExporter
+	logErr func(error) // Reports errors returned by the exporter
+	ended  bool
+}
+
+// spanKey is the context key of the span enclosing the work done with the context
+type spanKey struct{}
+
+func spanFrom(ctx context.Context) *TraceSpan {
+	sp, _ := ctx.Value(spanKey{}).(*TraceSpan)
+	return sp
+}
+
+// StartSpan starts a span named name as a child of the span in ctx and returns a context
+// holding the new span. Nodes start a span for each signal they process when their
+// StateManager has a SpanExporter; custom nodes can use StartSpan with the context returned
+// by EmptyNode.BeginSignal to trace their own steps. Without an enclosing span StartSpan
+// returns ctx and a nil span.
+func StartSpan(ctx context.Context, name string) (context.Context, *TraceSpan) {
+	parent := spanFrom(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	parent.mu.Lock()
+	sp := &TraceSpan{
+		span: node.Span{
+			TraceID:  parent.span.TraceID,
+			SpanID:   newSpanID(),
+			ParentID: parent.span.SpanID,
+			Name:     name,
+			RunID:    parent.span.RunID,
+			NodeID:   parent.span.NodeID,
+			SignalID: parent.span.SignalID,
+			Start:    time.Now(),
+		},
+		exp:    parent.exp,
+		logErr: parent.logErr,
+	}
+	parent.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, sp), sp
+}
+
+// SetAttr sets an attribute of the span
+func (s *TraceSpan) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.span.Attributes == nil {
+		s.span.Attributes = make(map[string]any)
+	}
+	s.span.Attributes[key] = value
+}
+
+// SetError records the error the operation failed with
+func (s *TraceSpan) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Err = err.Error()
+}
+
+// End ends the span, recording err if it is not nil, and exports it.
+// Calling End more than once does nothing.
+func (s *TraceSpan) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	if err != nil {
+		s.span.Err = err.Error()
+	}
+	s.span.End = time.Now()
+	span := s.span
+	s.mu.Unlock()
+
+	if err := s.exp.ExportSpan(span); err != nil && s.logErr != nil {
+		s.logErr(fmt.Errorf("exporting span: %w", err))
+	}
+}
+
+// spanExporter returns the SpanExporter of the StateManager, nil if it does not trace runs
+func spanExporter(sm node.StateManager) node.SpanExporter {
+	if tm, ok := sm.(node.TracingStateManager); ok {
+		return tm.SpanExporter()
+	}
+	return nil
+}
+
+// traceSignal starts the span of the node processing a signal when the node's StateManager
+// has a SpanExporter, and returns the signal carrying the span in its context
+func (n *EmptyNode) traceSignal(sig node.Signal, exp node.SpanExporter) (node.Signal, *TraceSpan) {
+	if exp == nil {
+		return sig, nil
+	}
+	sp := &TraceSpan{
+		span: node.Span{
+			TraceID:  TraceID(sig.RunID),
+			SpanID:   newSpanID(),
+			Name:     "node",
+			RunID:    sig.RunID,
+			NodeID:   n.ID(),
+			SignalID: sig.ID,
+			Start:    time.Now(),
+		},
+		exp:    exp,
+		logErr: n.LogErr,
+	}
+	sp.SetAttr("signal.path", strings.Join(sig.Path, "/"))
+	if len(sig.ParentIDs) > 0 {
+		sp.SetAttr("signal.parent_ids", append([]string{}, sig.ParentIDs...))
+	}
+	if sig.FromNodeID != "" {
+		sp.SetAttr("signal.from", sig.FromNodeID)
+	}
+	return sig.WithContext(context.WithValue(sig.Context(), spanKey{}, sp)), sp
+}
+
+// failSpan records the error a node failed a signal with on the node's span
+func failSpan(nodeID string, sig node.Signal, err error) {
+	if sp := spanFrom(sig.Context()); sp != nil && sp.span.NodeID == nodeID {
+		sp.SetError(err)
+	}
+}
+
+// TraceID returns the ID shared by the spans of a run. It is the hex encoded first 16
+// bytes of the SHA-256 hash of the run ID, the form OpenTelemetry expects.
+func TraceID(runID string) string {
+	sum := sha256.Sum256([]byte(runID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// newSpanID returns a random 8 byte hex encoded span ID
+func newSpanID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+// providerName returns the name of the package implementing an LLM, such as "openai"
+func providerName(lm llm.LLM) string {
+	t := reflect.TypeOf(lm)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.PkgPath() == "" {
+		return "unknown"
+	}
+	path := t.PkgPath()
+	return path[strings.LastIndex(path, "/")+1:]
+}
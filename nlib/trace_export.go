@@ -0,0 +1,362 @@
+package nlib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dshills/wiggle/node"
+)
+
+// Compile-time checks to ensure the exporters implement the node.SpanExporter interface
+var _ node.SpanExporter = (*MemorySpanExporter)(nil)
+var _ node.SpanExporter = (*FileSpanExporter)(nil)
+var _ node.SpanExporter = (*OTLPExporter)(nil)
+
+// MemorySpanExporter keeps exported spans in memory. It is useful in tests and for
+// inspecting a run from within the process.
+type MemorySpanExporter struct {
+	mu    sync.Mutex
+	spans []node.Span
+}
+
+// NewMemorySpanExporter creates an empty MemorySpanExporter
+func NewMemorySpanExporter() *MemorySpanExporter {
+	return &MemorySpanExporter{}
+}
+
+// ExportSpan stores the span
+func (e *MemorySpanExporter) ExportSpan(span node.Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+	return nil
+}
+
+// Spans returns the spans exported so far in the order they ended
+func (e *MemorySpanExporter) Spans() []node.Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]node.Span{}, e.spans...)
+}
+
+// RunSpans returns the spans of a run in the order they ended
+func (e *MemorySpanExporter) RunSpans(runID string) []node.Span {
+	spans := []node.Span{}
+	for _, span := range e.Spans() {
+		if span.RunID == runID {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// Reset drops the spans exported so far
+func (e *MemorySpanExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
+
+// FileSpanExporter appends each span to a file as a line of JSON
+type FileSpanExporter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSpanExporter opens or creates the file at path for appending. Close it when done.
+func NewFileSpanExporter(path string) (*FileSpanExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("span exporter: %w", err)
+	}
+	return &FileSpanExporter{file: file}, nil
+}
+
+// ExportSpan writes the span as a line of JSON
+func (e *FileSpanExporter) ExportSpan(span node.Span) error {
+	data, err := json.Marshal(span)
+	if err != nil {
+		return fmt.Errorf("span exporter: %w", err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("span exporter: %w", err)
+	}
+	return nil
+}
+
+// Close closes the file
+func (e *FileSpanExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.file.Close()
+}
+
+// OTLPExporter sends spans to an OpenTelemetry collector using OTLP over HTTP with JSON
+// encoding. Spans are buffered and sent in batches every FlushInterval, or sooner once
+// BatchSize spans are waiting. Close the exporter to send the spans still buffered.
+type OTLPExporter struct {
+	Endpoint      string        // Collector URL, for example http://localhost:4318
+	ServiceName   string        // Reported as the service.name resource attribute
+	BatchSize     int           // Number of buffered spans that triggers a send
+	FlushInterval time.Duration // Longest time a span is buffered
+	Client        *http.Client  // Client used to send spans
+
+	mu      sync.Mutex
+	pending []node.Span
+	err     error // Error of the last background send, returned by the next ExportSpan
+	flushCh chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// NewOTLPExporter creates an exporter sending spans to the OTLP/HTTP collector at endpoint
+// and starts its background sender
+func NewOTLPExporter(endpoint, serviceName string) *OTLPExporter {
+	e := &OTLPExporter{
+		Endpoint:      strings.TrimSuffix(endpoint, "/"),
+		ServiceName:   serviceName,
+		BatchSize:     100,
+		FlushInterval: time.Second,
+		Client:        &http.Client{Timeout: 10 * time.Second},
+		flushCh:       make(chan struct{}, 1),
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// ExportSpan buffers the span to be sent with the next batch. It returns the error of
+// the previous background send, if it failed.
+func (e *OTLPExporter) ExportSpan(span node.Span) error {
+	e.mu.Lock()
+	e.pending = append(e.pending, span)
+	full := len(e.pending) >= e.BatchSize
+	err := e.err
+	e.err = nil
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return err
+}
+
+// Flush sends the buffered spans
+func (e *OTLPExporter) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	spans := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+	return e.send(ctx, spans)
+}
+
+// Close stops the background sender and sends the buffered spans
+func (e *OTLPExporter) Close() error {
+	e.once.Do(func() { close(e.done) })
+	<-e.stopped
+	return e.Flush(context.Background())
+}
+
+// run sends the buffered spans every FlushInterval or when a batch is full, until Close is called
+func (e *OTLPExporter) run() {
+	defer close(e.stopped)
+	ticker := time.NewTicker(e.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.flushCh:
+		case <-e.done:
+			return
+		}
+		if err := e.Flush(context.Background()); err != nil {
+			e.mu.Lock()
+			e.err = err
+			e.mu.Unlock()
+		}
+	}
+}
+
+// send posts the spans to the collector's /v1/traces endpoint
+func (e *OTLPExporter) send(ctx context.Context, spans []node.Span) error {
+	data, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return fmt.Errorf("otlp exporter: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint+"/v1/traces", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("otlp exporter: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("otlp exporter: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("otlp exporter: collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP/JSON encoding of an ExportTraceServiceRequest
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 0 unset, 1 ok, 2 error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpValue `json:"values"`
+}
+
+const (
+	otlpKindInternal = 1
+	otlpKindClient   = 3
+)
+
+func (e *OTLPExporter) request(spans []node.Span) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		attrs := map[string]any{"wiggle.run_id": span.RunID, "wiggle.node_id": span.NodeID, "wiggle.signal_id": span.SignalID}
+		for k, v := range span.Attributes {
+			attrs[k] = v
+		}
+		ospan := otlpSpan{
+			TraceID:           span.TraceID,
+			SpanID:            span.SpanID,
+			ParentSpanID:      span.ParentID,
+			Name:              span.Name,
+			Kind:              otlpKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(attrs),
+		}
+		if span.Name == "node" {
+			ospan.Name = "node " + span.NodeID
+		}
+		if strings.HasPrefix(span.Name, "llm.") {
+			ospan.Kind = otlpKindClient
+		}
+		if span.Err != "" {
+			ospan.Status = otlpStatus{Code: 2, Message: span.Err}
+		}
+		out = append(out, ospan)
+	}
+
+	service := e.ServiceName
+	if service == "" {
+		service = "wiggle"
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(map[string]any{"service.name": service})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/dshills/wiggle"}, Spans: out}},
+	}}}
+}
+
+// otlpAttributes converts attributes to OTLP key values sorted by key
+func otlpAttributes(attrs map[string]any) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: otlpAnyValue(attrs[k])})
+	}
+	return kvs
+}
+
+func otlpAnyValue(v any) otlpValue {
+	switch t := v.(type) {
+	case string:
+		return otlpValue{StringValue: &t}
+	case bool:
+		return otlpValue{BoolValue: &t}
+	case int:
+		s := strconv.Itoa(t)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(t, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &t}
+	case []string:
+		arr := &otlpArrayValue{Values: []otlpValue{}}
+		for _, s := range t {
+			arr.Values = append(arr.Values, otlpAnyValue(s))
+		}
+		return otlpValue{ArrayValue: arr}
+	default:
+		s := fmt.Sprint(t)
+		return otlpValue{StringValue: &s}
+	}
+}
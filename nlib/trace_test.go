@@ -0,0 +1,160 @@
+package nlib_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracing_Spans(t *testing.T) {
+	exp := nlib.NewMemorySpanExporter()
+	mgr := nlib.NewSimpleStateManager(nil)
+	mgr.SetSpanExporter(exp)
+	ai := nlib.NewAINode(newReplyLLM("HELLO", nil), mgr, node.Options{ID: "ai"})
+	ai.Connect(newTransformNode(mgr, "punctuate", func(s string) string { return s + "!" }))
+
+	sig, err := nlib.Run(context.Background(), ai, node.Signal{Task: nlib.NewTextCarrier("hello")})
+	assert.NoError(t, err)
+
+	nodeSpans := make(map[string]node.Span)
+	children := make(map[string][]string)
+	for _, span := range exp.RunSpans(sig.RunID) {
+		assert.Equal(t, nlib.TraceID(sig.RunID), span.TraceID)
+		assert.False(t, span.End.Before(span.Start))
+		if span.Name == "node" {
+			nodeSpans[span.NodeID] = span
+		}
+	}
+	for _, span := range exp.RunSpans(sig.RunID) {
+		if span.Name != "node" {
+			assert.Equal(t, nodeSpans[span.NodeID].SpanID, span.ParentID)
+			children[span.NodeID] = append(children[span.NodeID], span.Name)
+		}
+	}
+	assert.Len(t, nodeSpans, 2)
+	assert.ElementsMatch(t, []string{"pre-hook", "llm.chat", "post-hook", "send"}, children["ai"])
+	assert.ElementsMatch(t, []string{"pre-hook", "post-hook"}, children["punctuate"])
+	assert.Equal(t, sig.ID, nodeSpans["punctuate"].SignalID)
+	assert.Equal(t, []string{nodeSpans["ai"].SignalID}, sig.ParentIDs)
+
+	for _, span := range exp.RunSpans(sig.RunID) {
+		if span.Name == "llm.chat" {
+			assert.Equal(t, "mock", span.Attributes["llm.model"])
+			assert.Equal(t, "nmock", span.Attributes["llm.provider"])
+			assert.Equal(t, 0, span.Attributes["llm.usage.total_tokens"])
+		}
+		if span.Name == "send" {
+			assert.Equal(t, "punctuate", span.Attributes["send.target"])
+		}
+	}
+}
+
+func TestTracing_Failure(t *testing.T) {
+	exp := nlib.NewMemorySpanExporter()
+	mgr := nlib.NewSimpleStateManager(nil)
+	mgr.SetSpanExporter(exp)
+	after := func(sig node.Signal) (node.Signal, error) { return sig, errors.New("boom") }
+	n := nlib.NewSimpleBranchNode(mgr, node.Options{ID: "broken", Hooks: nlib.NewSimpleNodeHooks(nil, after)})
+
+	sig, err := nlib.Run(context.Background(), n, node.Signal{Task: nlib.NewTextCarrier("hello")})
+	assert.Error(t, err)
+
+	errs := make(map[string]string)
+	for _, span := range exp.RunSpans(sig.RunID) {
+		errs[span.Name] = span.Err
+	}
+	assert.Equal(t, map[string]string{"pre-hook": "", "post-hook": "boom", "node": "boom"}, errs)
+}
+
+func TestFileSpanExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	exp, err := nlib.NewFileSpanExporter(path)
+	assert.NoError(t, err)
+	assert.NoError(t, exp.ExportSpan(node.Span{TraceID: "t1", SpanID: "s1", Name: "node", NodeID: "a"}))
+	assert.NoError(t, exp.ExportSpan(node.Span{TraceID: "t1", SpanID: "s2", ParentID: "s1", Name: "llm.chat", NodeID: "a"}))
+	assert.NoError(t, exp.Close())
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+	spans := []node.Span{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		span := node.Span{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &span))
+		spans = append(spans, span)
+	}
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "s1", spans[1].ParentID)
+		assert.Equal(t, "llm.chat", spans[1].Name)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	bodies := make(chan []byte, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	exp := nlib.NewOTLPExporter(srv.URL, "test-service")
+	start := time.Unix(100, 0)
+	assert.NoError(t, exp.ExportSpan(node.Span{
+		TraceID: nlib.TraceID("r1"), SpanID: "0102030405060708", Name: "node", NodeID: "ai", RunID: "r1",
+		Start: start, End: start.Add(time.Second), Err: "boom",
+		Attributes: map[string]any{"signal.parent_ids": []string{"p1"}, "llm.request.messages": 2},
+	}))
+	assert.NoError(t, exp.Close())
+
+	body := <-bodies
+	req := struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID           string `json:"traceId"`
+					Name              string `json:"name"`
+					StartTimeUnixNano string `json:"startTimeUnixNano"`
+					Attributes        []struct {
+						Key   string         `json:"key"`
+						Value map[string]any `json:"value"`
+					} `json:"attributes"`
+					Status struct {
+						Code    int    `json:"code"`
+						Message string `json:"message"`
+					} `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}{}
+	assert.NoError(t, json.Unmarshal(body, &req))
+	span := req.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	assert.Equal(t, nlib.TraceID("r1"), span.TraceID)
+	assert.Len(t, span.TraceID, 32)
+	assert.Equal(t, "node ai", span.Name)
+	assert.Equal(t, "100000000000", span.StartTimeUnixNano)
+	assert.Equal(t, 2, span.Status.Code)
+	assert.Equal(t, "boom", span.Status.Message)
+	attrs := make(map[string]map[string]any)
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	assert.Equal(t, "2", attrs["llm.request.messages"]["intValue"])
+	assert.Equal(t, "r1", attrs["wiggle.run_id"]["stringValue"])
+	assert.Contains(t, attrs, "signal.parent_ids")
+	assert.Empty(t, bodies)
+}
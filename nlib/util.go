@@ -41,14 +41,7 @@ func GenerateUUID() (string, error) {
 }
 
 // FilterMetaKey filters the metadata of a signal to return only the metadata that matches the given key.
-// It iterates over the signal's metadata, checks if the key matches, and appends the matching metadata to the result slice.
-// It returns the filtered slice of metadata.
+// It returns every value of the key in the order they were added, the same as sig.Meta.Values(key).
 func FilterMetaKey(sig node.Signal, key string) []node.Meta {
-	m := []node.Meta{}
-	for _, meta := range sig.Meta {
-		if meta.Key == key { // Check if the meta key matches the provided key
-			m = append(m, meta) // Add the matching meta to the result slice
-		}
-	}
-	return m // Return the filtered metadata
+	return sig.Meta.Values(key)
 }
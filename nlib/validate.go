@@ -0,0 +1,231 @@
+package nlib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dshills/wiggle/node"
+)
+
+// ValidationIssue describes a problem found in a graph
+type ValidationIssue struct {
+	NodeID  string // ID of the node with the problem
+	Message string // Description of the problem
+}
+
+func (i ValidationIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.NodeID, i.Message)
+}
+
+// ValidationError is returned by Validate and holds every issue found
+type ValidationError struct {
+	Issues []ValidationIssue
+}
+
+func (e *ValidationError) Error() string {
+	lines := []string{fmt.Sprintf("graph validation found %d issue(s)", len(e.Issues))}
+	for _, issue := range e.Issues {
+		lines = append(lines, issue.String())
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// Validate inspects the graph reachable from the entry node before any signal is sent.
+// It reports nodes missing required functions or targets, cycles that do not pass
+// through a LoopNode or InteractiveNode, dead ends where signals would be dropped
+// or never arrive, and duplicate IDs. Any additional nodes that are not reachable
+// from the entry node are reported as unreachable. All issues are returned together
+// in a *ValidationError, or nil if the graph is valid.
+func Validate(entry node.Node, nodes ...node.Node) error {
+	v := validator{}
+	if entry == nil {
+		v.add("", "no entry node")
+		return v.err()
+	}
+
+	reachable := make(map[node.Node]bool)
+	ids := make(map[string]node.Node)
+	Walk(func(n node.Node) {
+		reachable[n] = true
+		if other, ok := ids[n.ID()]; ok && other != n {
+			v.add(n.ID(), "duplicate node ID")
+		}
+		ids[n.ID()] = n
+		v.checkNode(n)
+	}, entry)
+
+	v.checkCycles(entry)
+
+	for _, n := range nodes {
+		if n != nil && !reachable[n] {
+			v.add(n.ID(), fmt.Sprintf("not reachable from %s", entry.ID()))
+		}
+	}
+	return v.err()
+}
+
+// validator collects the issues found by Validate
+type validator struct {
+	issues []ValidationIssue
+}
+
+func (v *validator) add(id, msg string) {
+	v.issues = append(v.issues, ValidationIssue{NodeID: id, Message: msg})
+}
+
+func (v *validator) err() error {
+	if len(v.issues) == 0 {
+		return nil
+	}
+	return &ValidationError{Issues: v.issues}
+}
+
+// checkNode checks the configuration of a single node using the concrete nlib types
+func (v *validator) checkNode(n node.Node) {
+	id := n.ID()
+	if id == "" {
+		v.add(id, "node has no ID")
+	}
+	if n.InputCh() == nil {
+		v.add(id, "node has no input channel")
+	}
+	if con, ok := n.(interface{ Nodes() []node.Node }); ok {
+		for _, c := range con.Nodes() {
+			if c == nil {
+				v.add(id, "connected to a nil node")
+			}
+		}
+	}
+
+	switch t := n.(type) {
+	case *AINode:
+		if t.lm == nil {
+			v.add(id, "AI node has no LLM")
+		}
+	case *OutputStringNode:
+		if t.writer == nil {
+			v.add(id, "output node has no writer")
+		}
+	case *SimpleStringReaderNode:
+		if t.reader == nil {
+			v.add(id, "reader node has no reader")
+		}
+	case *SimplePartitionerNode:
+		if t.partitionFunc == nil {
+			v.add(id, "partitioner node has no partition function")
+		}
+		if t.integrationFunc == nil {
+			v.add(id, "partitioner node has no integration function")
+		}
+		if t.factory == nil {
+			v.add(id, "partitioner node has no node factory")
+		}
+	case *SimpleBranchNode:
+		for i, cond := range t.conditions {
+			if cond.Target == nil {
+				v.add(id, fmt.Sprintf("branch condition %d has no target", i))
+			}
+			if cond.ConditionFn == nil {
+				v.add(id, fmt.Sprintf("branch condition %d has no condition function", i))
+			}
+		}
+		if len(t.conditions) > 0 && len(t.Nodes()) == 0 {
+			v.add(id, "dead end: signals matching no condition are dropped")
+		}
+	case *SimpleLoopNode:
+		if t.condFn == nil {
+			v.add(id, "loop node has no condition function and never ends")
+		}
+		if t.startNode == nil {
+			v.add(id, "loop node has no start node")
+		} else if !reaches(t.startNode, t) {
+			v.add(id, fmt.Sprintf("dead end: start node %s does not lead back to the loop", t.startNode.ID()))
+		}
+		if len(t.Nodes()) == 0 {
+			v.add(id, "dead end: signals leaving the loop are dropped")
+		}
+	case *SimpleJoinNode:
+		if t.mergeFn == nil {
+			v.add(id, "join node has no merge function")
+		}
+		if len(t.upstream) == 0 && t.count < 1 {
+			v.add(id, "join node has no upstream nodes or count")
+		}
+	case *SimpleSetNode:
+		if t.startNode == nil {
+			v.add(id, "set node has no start node")
+		}
+		if t.finalNode == nil {
+			v.add(id, "set node has no final node")
+		}
+		if t.startNode != nil && t.finalNode != nil && !reaches(t.startNode, t.finalNode) {
+			v.add(id, fmt.Sprintf("dead end: final node %s is not reachable from start node %s", t.finalNode.ID(), t.startNode.ID()))
+		}
+	}
+}
+
+// checkCycles reports cycles that cannot end. Loop nodes end their cycle with a
+// condition and interactive nodes when the user quits, so cycles through them are allowed.
+func (v *validator) checkCycles(entry node.Node) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[node.Node]int)
+	path := []node.Node{}
+
+	var visit func(n node.Node)
+	visit = func(n node.Node) {
+		state[n] = visiting
+		path = append(path, n)
+		if !endsCycle(n) {
+			for _, next := range Successors(n) {
+				if next == nil {
+					continue
+				}
+				switch state[next] {
+				case unvisited:
+					visit(next)
+				case visiting:
+					v.add(next.ID(), "cycle outside a loop: "+cyclePath(path, next))
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[n] = done
+	}
+	visit(entry)
+}
+
+// endsCycle reports whether a node can end a cycle it is part of
+func endsCycle(n node.Node) bool {
+	switch n.(type) {
+	case node.LoopNode, *InteractiveNode:
+		return true
+	}
+	return false
+}
+
+// cyclePath formats the cycle on the DFS path starting at node from
+func cyclePath(path []node.Node, from node.Node) string {
+	ids := []string{}
+	for i := len(path) - 1; i >= 0; i-- {
+		ids = append([]string{path[i].ID()}, ids...)
+		if path[i] == from {
+			break
+		}
+	}
+	return strings.Join(append(ids, from.ID()), " -> ")
+}
+
+// reaches reports whether target can be reached from start
+func reaches(start, target node.Node) bool {
+	found := false
+	Walk(func(n node.Node) {
+		if n == target {
+			found = true
+		}
+	}, start)
+	return found
+}
@@ -0,0 +1,104 @@
+package nlib_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/node"
+	"github.com/stretchr/testify/assert"
+)
+
+// issueMessages returns the issues of a validation error as strings
+func issueMessages(t *testing.T, err error) []string {
+	t.Helper()
+	var verr *nlib.ValidationError
+	if !assert.True(t, errors.As(err, &verr)) {
+		return nil
+	}
+	msgs := []string{}
+	for _, issue := range verr.Issues {
+		msgs = append(msgs, issue.String())
+	}
+	return msgs
+}
+
+func TestValidate_ValidGraph(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	first := newTransformNode(mgr, "first", strings.ToUpper)
+	loop := nlib.NewSimpleLoopNode(first, func(node.Signal) bool { return true }, mgr, node.Options{ID: "loop"})
+	last := newTransformNode(mgr, "last", strings.ToLower)
+	first.Connect(loop)
+	loop.Connect(last)
+
+	assert.NoError(t, nlib.Validate(first))
+}
+
+func TestValidate_ReportsAllIssues(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	first := newTransformNode(mgr, "first", strings.ToUpper)
+	branch := nlib.NewSimpleBranchNode(mgr, node.Options{ID: "branch"})
+	branch.AddConditional(node.BranchCondition{ConditionFn: func(node.Signal) bool { return true }})
+	loop := nlib.NewSimpleLoopNode(nil, nil, mgr, node.Options{ID: "loop"})
+	part := nlib.NewSimplePartitionerNode(nil, nil, nil, mgr, node.Options{ID: "part"})
+	dup := newTransformNode(mgr, "first", strings.ToUpper)
+	orphan := newTransformNode(mgr, "orphan", strings.ToUpper)
+	first.Connect(branch, loop, part, dup)
+
+	msgs := issueMessages(t, nlib.Validate(first, first, orphan))
+	assert.ElementsMatch(t, []string{
+		"branch: branch condition 0 has no target",
+		"branch: dead end: signals matching no condition are dropped",
+		"loop: loop node has no condition function and never ends",
+		"loop: loop node has no start node",
+		"loop: dead end: signals leaving the loop are dropped",
+		"part: partitioner node has no partition function",
+		"part: partitioner node has no integration function",
+		"part: partitioner node has no node factory",
+		"first: duplicate node ID",
+		"orphan: not reachable from first",
+	}, msgs)
+}
+
+func TestValidate_Cycles(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	a := newTransformNode(mgr, "a", strings.ToUpper)
+	b := newTransformNode(mgr, "b", strings.ToUpper)
+	c := newTransformNode(mgr, "c", strings.ToUpper)
+	a.Connect(b)
+	b.Connect(c)
+	c.Connect(a)
+
+	msgs := issueMessages(t, nlib.Validate(a))
+	assert.Equal(t, []string{"a: cycle outside a loop: a -> b -> c -> a"}, msgs)
+
+	// A chat loop through an interactive node is allowed
+	chat := nlib.NewInteractiveNode(mgr, node.Options{ID: "chat"})
+	reply := newTransformNode(mgr, "reply", strings.ToUpper)
+	chat.Connect(reply)
+	reply.Connect(chat)
+	assert.NoError(t, nlib.Validate(chat))
+}
+
+func TestValidate_SetAndLoopDeadEnds(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	start := newTransformNode(mgr, "start", strings.ToUpper)
+	final := newTransformNode(mgr, "final", strings.ToUpper)
+	set := nlib.NewSimpleSetNode(start, final, mgr, node.Options{ID: "set"})
+
+	body := newTransformNode(mgr, "body", strings.ToUpper)
+	loop := nlib.NewSimpleLoopNode(body, func(node.Signal) bool { return true }, mgr, node.Options{ID: "loop"})
+	set.Connect(loop)
+	loop.Connect(newTransformNode(mgr, "after", strings.ToUpper))
+
+	msgs := issueMessages(t, nlib.Validate(set))
+	assert.ElementsMatch(t, []string{
+		"set: dead end: final node final is not reachable from start node start",
+		"loop: dead end: start node body does not lead back to the loop",
+	}, msgs)
+}
+
+func TestValidate_NilEntry(t *testing.T) {
+	assert.Error(t, nlib.Validate(nil))
+}
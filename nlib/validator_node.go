@@ -1,7 +1,6 @@
 package nlib
 
 import (
-	"context"
 	"fmt"
 
 	"github.com/dshills/wiggle/node"
@@ -21,24 +20,17 @@ func NewJSONValidatorNode(mgr node.StateManager, jsonSchema schema.Schema, optio
 	n.SetOptions(options)
 	n.SetStateManager(mgr)
 	n.MakeInputCh()
+	n.SetProcessFunc(n.ProcessSignal)
 
-	go func() {
-		for {
-			select {
-			case sig := <-n.InputCh():
-				n.LogInfo("Received Signal")
-				n.ProcessSignal(sig)
-			case <-n.StateManager().Register():
-				n.LogInfo("Received done")
-			}
-		}
-	}()
 	return &n
 }
 
 func (n *JSONValidatorNode) ProcessSignal(sig node.Signal) {
 	var err error
-	sig, err = n.PreProcessSignal(sig)
+	ctx, done := n.BeginSignal(sig)
+	defer done()
+
+	sig, err = n.PreProcessSignal(ctx, sig)
 	if err != nil {
 		n.Fail(sig, err)
 		return
@@ -62,13 +54,13 @@ func (n *JSONValidatorNode) ProcessSignal(sig node.Signal) {
 	sig.Status = StatusSuccess
 
 	// Run post-processing hooks and forward the signal to connected nodes.
-	sig, err = n.PostProcessSignal(sig)
+	sig, err = n.PostProcessSignal(ctx, sig)
 	if err != nil {
 		n.Fail(sig, err)
 		return
 	}
 
-	if err := n.SendToConnected(context.TODO(), sig); err != nil {
+	if err := n.SendToConnected(ctx, sig); err != nil {
 		n.Fail(sig, err)
 		return
 	}
@@ -0,0 +1,59 @@
+package nmock
+
+import (
+	"context"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/stretchr/testify/mock"
+)
+
+// Compile-time check
+var _ llm.LLM = (*MockLLM)(nil)
+var _ llm.ToolChatter = (*MockLLM)(nil)
+
+// MockLLM is a testing mock for llm.LLM
+type MockLLM struct {
+	mock.Mock
+}
+
+func (m *MockLLM) GenerateResponse(info string, instruct string) (string, error) {
+	args := m.Called(info, instruct)
+	return args.String(0), args.Error(1)
+}
+
+// Chat returns the llm.Response set with Return, or an llm.Message wrapped in a Response
+func (m *MockLLM) Chat(ctx context.Context, msgs llm.MessageList) (llm.Response, error) {
+	args := m.Called(ctx, msgs)
+	if msg, ok := args.Get(0).(llm.Message); ok {
+		return llm.Response{Message: msg}, args.Error(1)
+	}
+	return args.Get(0).(llm.Response), args.Error(1)
+}
+
+// ChatWithTools returns the llm.Response set with Return, or an llm.Message wrapped in a Response
+func (m *MockLLM) ChatWithTools(ctx context.Context, msgs llm.MessageList, tools []llm.Tool) (llm.Response, error) {
+	args := m.Called(ctx, msgs, tools)
+	if msg, ok := args.Get(0).(llm.Message); ok {
+		return llm.Response{Message: msg}, args.Error(1)
+	}
+	return args.Get(0).(llm.Response), args.Error(1)
+}
+
+func (m *MockLLM) GenEmbed(ctx context.Context, txt string) ([]float32, error) {
+	args := m.Called(ctx, txt)
+	return args.Get(0).([]float32), args.Error(1)
+}
+
+func (m *MockLLM) AvailableModels() ([]llm.Model, error) {
+	args := m.Called()
+	return args.Get(0).([]llm.Model), args.Error(1)
+}
+
+func (m *MockLLM) SetModel(model string) {
+	m.Called(model)
+}
+
+func (m *MockLLM) Model() string {
+	args := m.Called()
+	return args.String(0)
+}
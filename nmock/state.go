@@ -1,12 +1,18 @@
 package nmock
 
 import (
+	"context"
+
 	"github.com/dshills/wiggle/node"
 	"github.com/stretchr/testify/mock"
 )
 
 // Compile-time check
-var _ node.StateManager = (*MockStateManager)(nil)
+var _ node.RunStateManager = (*MockStateManager)(nil)
+var _ node.CheckpointStateManager = (*MockStateManager)(nil)
+var _ node.TracingStateManager = (*MockStateManager)(nil)
+var _ node.MetricsStateManager = (*MockStateManager)(nil)
+var _ node.StructuredLogStateManager = (*MockStateManager)(nil)
 
 // MockStateManager is a testing mock for StateManager, providing mock behavior
 // for methods such as logging, state updates, resource management, coordination, context, and history management.
@@ -24,6 +30,23 @@ func (m *MockStateManager) Log(message string) {
 	m.Called(message)
 }
 
+// LogAt records a leveled log entry. The fields are passed to the mock as a single slice.
+func (m *MockStateManager) LogAt(level node.Level, msg string, fields ...any) {
+	m.Called(level, msg, fields)
+}
+
+// StructuredLogger returns the mock StructuredLogger associated with the StateManager.
+func (m *MockStateManager) StructuredLogger() node.StructuredLogger {
+	args := m.Called()
+	l, _ := args.Get(0).(node.StructuredLogger)
+	return l
+}
+
+// SetStructuredLogger sets a mock structured logger for the StateManager.
+func (m *MockStateManager) SetStructuredLogger(l node.StructuredLogger) {
+	m.Called(l)
+}
+
 // UpdateState mocks the behavior of updating the state of a signal.
 func (m *MockStateManager) UpdateState(sig node.Signal) {
 	m.Called(sig)
@@ -45,6 +68,42 @@ func (m *MockStateManager) SetResourceManager(mgr node.ResourceManager) {
 	m.Called(mgr)
 }
 
+// CheckpointStore returns the mock CheckpointStore recording run progress.
+func (m *MockStateManager) CheckpointStore() node.CheckpointStore {
+	args := m.Called()
+	store, _ := args.Get(0).(node.CheckpointStore)
+	return store
+}
+
+// SetCheckpointStore sets the CheckpointStore in this mock.
+func (m *MockStateManager) SetCheckpointStore(store node.CheckpointStore) {
+	m.Called(store)
+}
+
+// Metrics returns the mock Metrics recording node metrics.
+func (m *MockStateManager) Metrics() node.Metrics {
+	args := m.Called()
+	metrics, _ := args.Get(0).(node.Metrics)
+	return metrics
+}
+
+// SetMetrics sets the Metrics in this mock.
+func (m *MockStateManager) SetMetrics(metrics node.Metrics) {
+	m.Called(metrics)
+}
+
+// SpanExporter returns the mock SpanExporter receiving the spans of traced runs.
+func (m *MockStateManager) SpanExporter() node.SpanExporter {
+	args := m.Called()
+	exp, _ := args.Get(0).(node.SpanExporter)
+	return exp
+}
+
+// SetSpanExporter sets the SpanExporter in this mock.
+func (m *MockStateManager) SetSpanExporter(exp node.SpanExporter) {
+	m.Called(exp)
+}
+
 // Coordinator returns the mock Coordinator for managing node coordination.
 func (m *MockStateManager) Coordinator() node.Coordinator {
 	args := m.Called()
@@ -67,6 +126,17 @@ func (m *MockStateManager) WaitFor(n node.Node) {
 	m.Called(n)
 }
 
+// WaitForRun mocks waiting for a node to process the signal of a run.
+func (m *MockStateManager) WaitForRun(ctx context.Context, runID string, n node.Node) (node.Signal, error) {
+	args := m.Called(ctx, runID, n)
+	return args.Get(0).(node.Signal), args.Error(1)
+}
+
+// EndRun mocks releasing the state held for a run.
+func (m *MockStateManager) EndRun(runID string) {
+	m.Called(runID)
+}
+
 // GetState returns the state of a signal in the mock StateManager.
 func (m *MockStateManager) GetState(sig node.Signal) node.State {
 	args := m.Called(sig)
@@ -133,3 +203,9 @@ func (m *MockStateManager) FilterHistory(nodeid string) []node.Signal {
 	args := m.Called(nodeid)
 	return args.Get(0).([]node.Signal)
 }
+
+// FilterRunHistory filters the history of signals by run ID. Mocks the filtering behavior.
+func (m *MockStateManager) FilterRunHistory(runID string) []node.Signal {
+	args := m.Called(runID)
+	return args.Get(0).([]node.Signal)
+}
@@ -0,0 +1,42 @@
+package node
+
+// Level is the severity of a log entry. The values match those of log/slog.
+type Level int
+
+const (
+	LevelDebug Level = -4 // Detailed tracing of signals through the nodes
+	LevelInfo  Level = 0  // Normal progress such as a completed LLM request
+	LevelWarn  Level = 4  // Recovered problems such as a retried or ignored error
+	LevelError Level = 8  // Failures such as a failed signal
+)
+
+// String returns the lower case name of the level
+func (l Level) String() string {
+	switch {
+	case l < LevelInfo:
+		return "debug"
+	case l < LevelWarn:
+		return "info"
+	case l < LevelError:
+		return "warn"
+	}
+	return "error"
+}
+
+// Log field keys used by the nodes to correlate entries
+const (
+	LogKeyNodeID   = "node_id"
+	LogKeyRunID    = "run_id"
+	LogKeySignalID = "signal_id"
+	LogKeyError    = "error"
+)
+
+// StructuredLogger writes leveled log entries made of a message and key/value fields.
+// Fields alternate keys and values like the arguments of slog.Logger.Log, for example
+// LogAt(LevelError, "signal failed", LogKeyNodeID, "summarize", LogKeyError, err).
+// Nodes log through the StateManager, which skips entries below the levels the logger
+// reports as enabled.
+type StructuredLogger interface {
+	Enabled(Level) bool
+	LogAt(level Level, msg string, fields ...any)
+}
@@ -0,0 +1,356 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Meta represents key-value pairs of metadata associated with a signal.
+// It is used to store additional information that may be relevant for
+// processing, such as configuration settings, model parameters, or
+// contextual data, allowing nodes to access and act on this metadata
+// as part of the workflow. The value is stored as a string along with
+// its type, and the rule decides how it travels to derived signals.
+type Meta struct {
+	Key   string   `json:"key"`
+	Value string   `json:"value"`
+	Type  MetaType `json:"type,omitempty"` // Type of the value, empty for a string
+	Rule  MetaRule `json:"rule,omitempty"` // Propagation rule, empty to inherit
+}
+
+// MetaType is the type of a metadata value
+type MetaType string
+
+const (
+	MetaString MetaType = "string"
+	MetaInt    MetaType = "int"
+	MetaFloat  MetaType = "float"
+	MetaBool   MetaType = "bool"
+	MetaTime   MetaType = "time" // RFC 3339 with nanoseconds
+	MetaJSON   MetaType = "json" // Encoded JSON document
+)
+
+// MetaRule decides how a metadata key travels from a signal to the signals derived from it
+type MetaRule string
+
+const (
+	MetaInherit    MetaRule = "inherit"    // Setting the key replaces its values, which are passed on to derived signals
+	MetaDrop       MetaRule = "drop"       // The values stay on the signal they were set on and are not passed on
+	MetaAccumulate MetaRule = "accumulate" // Setting the key adds a value, and merged signals combine the values of every parent
+)
+
+// StringMeta returns a string metadata value
+func StringMeta(key, value string) Meta {
+	return Meta{Key: key, Value: value, Type: MetaString}
+}
+
+// IntMeta returns an integer metadata value
+func IntMeta(key string, value int) Meta {
+	return Meta{Key: key, Value: strconv.Itoa(value), Type: MetaInt}
+}
+
+// FloatMeta returns a floating point metadata value
+func FloatMeta(key string, value float64) Meta {
+	return Meta{Key: key, Value: strconv.FormatFloat(value, 'g', -1, 64), Type: MetaFloat}
+}
+
+// BoolMeta returns a boolean metadata value
+func BoolMeta(key string, value bool) Meta {
+	return Meta{Key: key, Value: strconv.FormatBool(value), Type: MetaBool}
+}
+
+// TimeMeta returns a time metadata value
+func TimeMeta(key string, value time.Time) Meta {
+	return Meta{Key: key, Value: value.Format(time.RFC3339Nano), Type: MetaTime}
+}
+
+// JSONMeta returns a metadata value holding value encoded as JSON
+func JSONMeta(key string, value any) (Meta, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return Meta{}, fmt.Errorf("meta %s: %w", key, err)
+	}
+	return Meta{Key: key, Value: string(data), Type: MetaJSON}, nil
+}
+
+// WithRule returns the value with a propagation rule
+func (m Meta) WithRule(rule MetaRule) Meta {
+	m.Rule = rule
+	return m
+}
+
+// Any returns the value converted to its type: a string, int, float64, bool, time.Time
+// or, for JSON, the decoded document. A value that cannot be converted is returned as
+// its string.
+func (m Meta) Any() any {
+	switch m.Type {
+	case MetaInt:
+		if v, err := strconv.Atoi(m.Value); err == nil {
+			return v
+		}
+	case MetaFloat:
+		if v, err := strconv.ParseFloat(m.Value, 64); err == nil {
+			return v
+		}
+	case MetaBool:
+		if v, err := strconv.ParseBool(m.Value); err == nil {
+			return v
+		}
+	case MetaTime:
+		if v, err := time.Parse(time.RFC3339Nano, m.Value); err == nil {
+			return v
+		}
+	case MetaJSON:
+		var v any
+		if err := json.Unmarshal([]byte(m.Value), &v); err == nil {
+			return v
+		}
+	}
+	return m.Value
+}
+
+// Metadata is the metadata of a signal. A key can hold several values. Metadata is
+// copy-on-write: the methods that change it return new Metadata and never modify the
+// receiver, so the signals sent to several nodes cannot change each other's metadata.
+// Change a signal's metadata by assigning the result:
+//
+//	sig.Meta = sig.Meta.SetInt("attempt", 2)
+type Metadata []Meta
+
+// Get returns the latest value of a key
+func (md Metadata) Get(key string) (Meta, bool) {
+	for i := len(md) - 1; i >= 0; i-- {
+		if md[i].Key == key {
+			return md[i], true
+		}
+	}
+	return Meta{}, false
+}
+
+// Values returns every value of a key in the order they were added
+func (md Metadata) Values(key string) []Meta {
+	values := []Meta{}
+	for _, m := range md {
+		if m.Key == key {
+			values = append(values, m)
+		}
+	}
+	return values
+}
+
+// Keys returns the keys in the order they were first added
+func (md Metadata) Keys() []string {
+	seen := make(map[string]bool)
+	keys := []string{}
+	for _, m := range md {
+		if !seen[m.Key] {
+			seen[m.Key] = true
+			keys = append(keys, m.Key)
+		}
+	}
+	return keys
+}
+
+// Rule returns the propagation rule of a key, MetaInherit unless one of its values sets another
+func (md Metadata) Rule(key string) MetaRule {
+	if m, ok := md.Get(key); ok && m.Rule != "" {
+		return m.Rule
+	}
+	return MetaInherit
+}
+
+// GetString returns the latest value of a key as a string
+func (md Metadata) GetString(key string) (string, bool) {
+	m, ok := md.Get(key)
+	return m.Value, ok
+}
+
+// GetInt returns the latest value of a key as an int. It returns false if the key is
+// missing or its value is not an integer.
+func (md Metadata) GetInt(key string) (int, bool) {
+	m, ok := md.Get(key)
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.Atoi(m.Value)
+	return v, err == nil
+}
+
+// GetFloat returns the latest value of a key as a float64. It returns false if the key
+// is missing or its value is not a number.
+func (md Metadata) GetFloat(key string) (float64, bool) {
+	m, ok := md.Get(key)
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(m.Value, 64)
+	return v, err == nil
+}
+
+// GetBool returns the latest value of a key as a bool. It returns false if the key is
+// missing or its value is not a boolean.
+func (md Metadata) GetBool(key string) (value bool, ok bool) {
+	m, ok := md.Get(key)
+	if !ok {
+		return false, false
+	}
+	v, err := strconv.ParseBool(m.Value)
+	return v, err == nil
+}
+
+// GetTime returns the latest value of a key as a time. It returns false if the key is
+// missing or its value is not an RFC 3339 time.
+func (md Metadata) GetTime(key string) (time.Time, bool) {
+	m, ok := md.Get(key)
+	if !ok {
+		return time.Time{}, false
+	}
+	v, err := time.Parse(time.RFC3339Nano, m.Value)
+	return v, err == nil
+}
+
+// GetJSON decodes the latest value of a key into v
+func (md Metadata) GetJSON(key string, v any) error {
+	m, ok := md.Get(key)
+	if !ok {
+		return fmt.Errorf("meta %s: not set", key)
+	}
+	if err := json.Unmarshal([]byte(m.Value), v); err != nil {
+		return fmt.Errorf("meta %s: %w", key, err)
+	}
+	return nil
+}
+
+// Set returns the metadata with the value set. The value replaces the values of its
+// key, or is added to them when the key accumulates. A value without a rule takes the
+// rule of its key.
+func (md Metadata) Set(m Meta) Metadata {
+	rule := md.Rule(m.Key)
+	if m.Rule == "" && rule != MetaInherit {
+		m.Rule = rule
+	}
+	if m.Rule == MetaAccumulate {
+		return md.Add(m)
+	}
+	return append(md.Remove(m.Key), m)
+}
+
+// Add returns the metadata with the value added to the values of its key
+func (md Metadata) Add(m Meta) Metadata {
+	out := make(Metadata, 0, len(md)+1)
+	return append(append(out, md...), m)
+}
+
+// Remove returns the metadata without the values of a key
+func (md Metadata) Remove(key string) Metadata {
+	out := make(Metadata, 0, len(md)+1)
+	for _, m := range md {
+		if m.Key != key {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// SetRule returns the metadata with the propagation rule of a key's values set to rule
+func (md Metadata) SetRule(key string, rule MetaRule) Metadata {
+	out := make(Metadata, 0, len(md))
+	for _, m := range md {
+		if m.Key == key {
+			m.Rule = rule
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// SetString returns the metadata with a string value set
+func (md Metadata) SetString(key, value string) Metadata {
+	return md.Set(StringMeta(key, value))
+}
+
+// SetInt returns the metadata with an integer value set
+func (md Metadata) SetInt(key string, value int) Metadata {
+	return md.Set(IntMeta(key, value))
+}
+
+// SetFloat returns the metadata with a floating point value set
+func (md Metadata) SetFloat(key string, value float64) Metadata {
+	return md.Set(FloatMeta(key, value))
+}
+
+// SetBool returns the metadata with a boolean value set
+func (md Metadata) SetBool(key string, value bool) Metadata {
+	return md.Set(BoolMeta(key, value))
+}
+
+// SetTime returns the metadata with a time value set
+func (md Metadata) SetTime(key string, value time.Time) Metadata {
+	return md.Set(TimeMeta(key, value))
+}
+
+// SetJSON returns the metadata with value set encoded as JSON
+func (md Metadata) SetJSON(key string, value any) (Metadata, error) {
+	m, err := JSONMeta(key, value)
+	if err != nil {
+		return md, err
+	}
+	return md.Set(m), nil
+}
+
+// Propagate returns the metadata passed on to a signal derived from this one: every
+// value except those with the MetaDrop rule
+func (md Metadata) Propagate() Metadata {
+	if len(md) == 0 {
+		return md
+	}
+	out := make(Metadata, 0, len(md))
+	for _, m := range md {
+		if m.Rule != MetaDrop {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// MergeMetadata combines the metadata of signals merged into one, for example by a join.
+// Accumulating keys keep the values of every parent, without repeating values the parents
+// share. Other keys keep the values of the last parent that has the key.
+func MergeMetadata(parents ...Metadata) Metadata {
+	out := Metadata{}
+	for _, md := range parents {
+		for _, key := range md.Keys() {
+			values := md.Values(key)
+			if md.Rule(key) != MetaAccumulate {
+				out = append(out.Remove(key), values...)
+				continue
+			}
+			for _, m := range values {
+				if !out.contains(m) {
+					out = append(out, m)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func (md Metadata) contains(m Meta) bool {
+	for _, v := range md {
+		if v == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Map returns the latest value of each key converted to its type by Meta.Any
+func (md Metadata) Map() map[string]any {
+	values := make(map[string]any)
+	for _, m := range md {
+		values[m.Key] = m.Any()
+	}
+	return values
+}
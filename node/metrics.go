@@ -0,0 +1,11 @@
+package node
+
+// Metrics records counters, gauges and histograms describing the processing of signals.
+// Set an implementation on the StateManager to collect metrics. Labels identify a series
+// of a metric, for example the node or LLM model it was recorded for. The methods are
+// called from the goroutines processing signals and must be safe for concurrent use.
+type Metrics interface {
+	AddCounter(name string, labels map[string]string, delta float64)       // Increase a counter
+	SetGauge(name string, labels map[string]string, value float64)         // Set the current value of a gauge
+	ObserveHistogram(name string, labels map[string]string, value float64) // Record a sample in a histogram
+}
@@ -1,7 +1,9 @@
 package node
 
 import (
+	"context"
 	"io"
+	"time"
 )
 
 // Node represents a generic processing unit in a chain of tasks.
@@ -18,16 +20,40 @@ type Node interface {
 	SetStateManager(StateManager)
 }
 
+// Lifecycle is implemented by nodes that process signals in their own goroutine.
+// Nodes are constructed idle. Start launches processing, which continues until the
+// context is cancelled or Stop is called. Stop blocks until the goroutine has exited.
+type Lifecycle interface {
+	Start(ctx context.Context)
+	Stop()
+}
+
 // Options defines a configuration structure that provides various settings
 // for nodes, including an identifier, hooks for extensibility, guidance for processing,
 // and error guidance for handling failures.
 type Options struct {
-	ID            string        // A unique identifier for the node or operation
-	Hooks         Hooks         // Hooks provide custom extensibility points for the node's behavior
-	Guidance      Guidance      // Guidance contains instructions or prompts that guide the node's processing logic
-	ErrorGuidance ErrorGuidance // ErrorGuidance contains instructions or prompts for handling errors or failures in processing
+	ID            string             // A unique identifier for the node or operation
+	Hooks         Hooks              // Hooks provide custom extensibility points for the node's behavior
+	Guidance      Guidance           // Guidance contains instructions or prompts that guide the node's processing logic
+	ErrorGuidance ErrorGuidance      // ErrorGuidance contains instructions or prompts for handling errors or failures in processing
+	Timeout       time.Duration      // Timeout limits the time spent processing a single signal, zero means no limit
+	BufferSize    int                // BufferSize is the capacity of the node's input channel, zero means unbuffered
+	Backpressure  BackpressurePolicy // Backpressure decides what senders do when the input buffer is full, defaults to BackpressureBlock
+	Concurrency   int                // Concurrency is the number of signals processed at once, zero or one processes them one at a time
+	Ordered       bool               // Ordered makes concurrent nodes send their output in the order the signals were received
 }
 
+// BackpressurePolicy decides what happens when a signal is sent to a node whose
+// input buffer is full. The drop-oldest and error policies only apply to nodes
+// with a BufferSize; sends to an unbuffered node always block.
+type BackpressurePolicy string
+
+const (
+	BackpressureBlock      BackpressurePolicy = "block"       // The sender waits until there is room or its context is done
+	BackpressureDropOldest BackpressurePolicy = "drop-oldest" // The oldest buffered signal is dropped and recorded as failed
+	BackpressureError      BackpressurePolicy = "error"       // The send fails immediately and the sender fails the signal
+)
+
 // PartitionerFn is a function type that takes an input string and splits it
 // into smaller parts or tasks. It is used by PartitionerNodes to divide
 // large or complex data into manageable chunks, enabling parallel processing
@@ -47,6 +73,10 @@ type IntegratorFn func([]string) (string, error)
 // is comprised of many Nodes.
 type Factory func(count int) []Node
 
+// FactoryFn is a Factory that returns an error when the nodes cannot be created.
+// The PartitionerNode fails the signal with the error.
+type FactoryFn func(count int) ([]Node, error)
+
 // PartitionerNode splits the input signal into smaller tasks or chunks
 // using a specified partition function. These partitions are then distributed
 // to child nodes for parallel processing. The interface allows for efficient
@@ -93,6 +123,23 @@ type BranchNode interface {
 	Conditions() []BranchCondition
 }
 
+// MergeFn combines the signals received by a JoinNode for a single run
+// into the Result of the signal it emits.
+type MergeFn func([]Signal) (DataCarrier, error)
+
+// JoinNode waits for signals from several upstream nodes that belong to the
+// same run and merges them into one signal. It completes when every upstream
+// node has sent a signal, when count signals have arrived, or when the wait
+// timeout expires, merging whatever has arrived.
+// The "join" after a fan-out in a set of nodes
+type JoinNode interface {
+	Node
+	SetMergeFunc(MergeFn)
+	SetUpstream(ids ...string)
+	SetCount(count int)
+	SetWaitTimeout(time.Duration)
+}
+
 // OutputNode writes data to a writer
 type OutputNode interface {
 	Node
@@ -1,28 +1,180 @@
 package node
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 // Signal represents the core data structure passed between nodes in a processing chain.
 // It contains the data being processed, contextual information, metadata, response data,
 // and a history of transformations. Signals enable the flow of information across nodes,
 // allowing each node to modify, route, and act on the data while keeping track of its
 // progression throughout the workflow.
 type Signal struct {
+	ID         string   // Unique ID of the signal, set when it is created or enters the graph
+	ParentIDs  []string // IDs of the signals this signal was derived from, several for merged signals
 	Err        string
-	Meta       []Meta
+	Meta       Metadata
 	NodeID     string
 	FromNodeID string
+	RunID      string   // Identifies the run the signal belongs to, set when it enters the graph
+	Path       []string // IDs of the nodes the signal and its ancestors have traversed, ending with NodeID
 	Result     DataCarrier
 	Status     string
 	Task       DataCarrier
+	Created    time.Time // When the signal was created
+	Started    time.Time // When NodeID started processing the signal
+	Finished   time.Time // When NodeID finished processing the signal
+	ctx        context.Context
+}
+
+// Context returns the run-scoped context carried by the signal. Nodes use it to
+// abort in-flight work when a run is cancelled or its deadline expires.
+// If no context has been set context.Background() is returned.
+func (s Signal) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// WithContext returns a copy of the signal carrying ctx. The context travels
+// with the signal and with every signal derived from it as it moves through the graph.
+func (s Signal) WithContext(ctx context.Context) Signal {
+	s.ctx = ctx
+	return s
+}
+
+// DecodeCarrier creates the DataCarrier of a Task or Result when a Signal is decoded
+// from JSON. The nlib package sets it to decode into an *nlib.Carrier.
+var DecodeCarrier func(data []byte) (DataCarrier, error)
+
+// signalJSON is the serialized form of a Signal. The context is not serialized.
+type signalJSON struct {
+	ID         string          `json:"id,omitempty"`
+	ParentIDs  []string        `json:"parent_ids,omitempty"`
+	NodeID     string          `json:"node_id,omitempty"`
+	FromNodeID string          `json:"from_node_id,omitempty"`
+	RunID      string          `json:"run_id,omitempty"`
+	Status     string          `json:"status,omitempty"`
+	Err        string          `json:"err,omitempty"`
+	Task       json.RawMessage `json:"task,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Meta       Metadata        `json:"meta,omitempty"`
+	Path       []string        `json:"path,omitempty"`
+	Created    *time.Time      `json:"created,omitempty"`
+	Started    *time.Time      `json:"started,omitempty"`
+	Finished   *time.Time      `json:"finished,omitempty"`
+}
+
+// carrierJSON is the serialized form of a DataCarrier
+type carrierJSON struct {
+	Text      string      `json:"text,omitempty"`
+	JSON      []byte      `json:"json,omitempty"`
+	Vector    [][]float32 `json:"vector,omitempty"`
+	Base64    []string    `json:"base64,omitempty"`
+	ImageURLs []string    `json:"image_urls,omitempty"`
+}
+
+// MarshalJSON encodes the signal so it can be stored and restored with UnmarshalJSON.
+// Task and Result are encoded through the DataCarrier methods.
+func (s Signal) MarshalJSON() ([]byte, error) {
+	task, err := marshalCarrier(s.Task)
+	if err != nil {
+		return nil, fmt.Errorf("task: %w", err)
+	}
+	result, err := marshalCarrier(s.Result)
+	if err != nil {
+		return nil, fmt.Errorf("result: %w", err)
+	}
+	return json.Marshal(signalJSON{
+		ID:         s.ID,
+		ParentIDs:  s.ParentIDs,
+		NodeID:     s.NodeID,
+		FromNodeID: s.FromNodeID,
+		RunID:      s.RunID,
+		Status:     s.Status,
+		Err:        s.Err,
+		Task:       task,
+		Result:     result,
+		Meta:       s.Meta,
+		Path:       s.Path,
+		Created:    timeOrNil(s.Created),
+		Started:    timeOrNil(s.Started),
+		Finished:   timeOrNil(s.Finished),
+	})
+}
+
+// UnmarshalJSON decodes a signal encoded with MarshalJSON. Task and Result are
+// created with DecodeCarrier. The decoded signal has no context.
+func (s *Signal) UnmarshalJSON(data []byte) error {
+	sj := signalJSON{}
+	if err := json.Unmarshal(data, &sj); err != nil {
+		return err
+	}
+	task, err := unmarshalCarrier(sj.Task)
+	if err != nil {
+		return fmt.Errorf("task: %w", err)
+	}
+	result, err := unmarshalCarrier(sj.Result)
+	if err != nil {
+		return fmt.Errorf("result: %w", err)
+	}
+	*s = Signal{
+		ID:         sj.ID,
+		ParentIDs:  sj.ParentIDs,
+		NodeID:     sj.NodeID,
+		FromNodeID: sj.FromNodeID,
+		RunID:      sj.RunID,
+		Status:     sj.Status,
+		Err:        sj.Err,
+		Task:       task,
+		Result:     result,
+		Meta:       sj.Meta,
+		Path:       sj.Path,
+	}
+	if sj.Created != nil {
+		s.Created = *sj.Created
+	}
+	if sj.Started != nil {
+		s.Started = *sj.Started
+	}
+	if sj.Finished != nil {
+		s.Finished = *sj.Finished
+	}
+	return nil
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func marshalCarrier(c DataCarrier) (json.RawMessage, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return json.Marshal(carrierJSON{
+		Text:      c.String(),
+		JSON:      c.JSON(),
+		Vector:    c.Vector(),
+		Base64:    c.Base64(),
+		ImageURLs: c.ImageURLs(),
+	})
 }
 
-// Meta represents key-value pairs of metadata associated with a signal.
-// It is used to store additional information that may be relevant for
-// processing, such as configuration settings, model parameters, or
-// contextual data, allowing nodes to access and act on this metadata
-// as part of the workflow.
-type Meta struct {
-	Key   string
-	Value string
+func unmarshalCarrier(data json.RawMessage) (DataCarrier, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	if DecodeCarrier == nil {
+		return nil, fmt.Errorf("no DecodeCarrier set")
+	}
+	return DecodeCarrier(data)
 }
 
 // DataCarrier provides an abstraction for handling different types of data
@@ -1,6 +1,9 @@
 package node
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type State struct {
 	Completed int
@@ -43,11 +46,65 @@ type StateManager interface {
 	FilterHistory(nodeid string) []Signal
 }
 
+// RunStateManager is a StateManager that keeps the state and history of each run apart,
+// so one graph can serve concurrent runs. nlib uses these methods when the StateManager
+// of a graph implements them.
+type RunStateManager interface {
+	StateManager
+	// WaitForRun blocks until the node has processed the signal for the run and returns
+	// that signal. If the run fails first the failing signal is returned with an error.
+	WaitForRun(ctx context.Context, runID string, n Node) (Signal, error)
+	// EndRun releases the state, history and context held for the run
+	EndRun(runID string)
+	FilterRunHistory(runID string) []Signal
+}
+
+// CheckpointStateManager is a StateManager that saves the output of each node of a run
+// to a CheckpointStore. nlib checkpoints runs, and can resume them, when the StateManager
+// of a graph implements it and has a store set.
+type CheckpointStateManager interface {
+	StateManager
+	CheckpointStore() CheckpointStore
+	SetCheckpointStore(CheckpointStore)
+}
+
+// TracingStateManager is a StateManager that exports the spans of traced runs. Nodes
+// record spans when the StateManager of a graph implements it and has an exporter set.
+type TracingStateManager interface {
+	StateManager
+	SpanExporter() SpanExporter
+	SetSpanExporter(SpanExporter)
+}
+
+// MetricsStateManager is a StateManager that collects metrics. Nodes record metrics when
+// the StateManager of a graph implements it and has Metrics set.
+type MetricsStateManager interface {
+	StateManager
+	Metrics() Metrics
+	SetMetrics(Metrics)
+}
+
+// StructuredLogStateManager is a StateManager that writes leveled entries with fields to
+// a StructuredLogger. Nodes log through LogAt when the StateManager of a graph implements
+// it, and pass their entries to Log formatted as JSON otherwise.
+type StructuredLogStateManager interface {
+	StateManager
+	StructuredLogger() StructuredLogger
+	SetStructuredLogger(StructuredLogger)
+	LogAt(level Level, msg string, fields ...any)
+}
+
 // Coordinator is responsible for managing the synchronization and execution flow
 // across multiple nodes. It provides mechanisms such as waiting for the completion
 // of tasks, handling timeouts, and coordinating the parallel execution of nodes,
 // ensuring that complex workflows proceed smoothly and efficiently.
 type Coordinator interface {
+	// Begin marks the signal as in-flight at Signal.NodeID and returns the context
+	// the node should use while processing it. The context is cancelled when the
+	// coordinator times out.
+	Begin(Signal) context.Context
+	// End marks the signal as no longer in-flight at Signal.NodeID
+	End(Signal)
 	WaitForCompletion(nodes ...Node) error
 	CancelOnTimeout(duration time.Duration)
 }
@@ -55,7 +112,8 @@ type Coordinator interface {
 // Logger is responsible for logging messages during the execution of nodes.
 // It provides a simple interface to track the flow of data, errors, and
 // other significant events in the system, aiding in debugging and monitoring
-// the behavior of nodes in a chain of tasks.
+// the behavior of nodes in a chain of tasks. Nodes log through StructuredLogger;
+// a Logger set on the StateManager receives their entries formatted as JSON.
 type Logger interface {
 	Log(string)
 }
@@ -89,3 +147,21 @@ type HistoryManager interface {
 	GetHistory() []Signal          // Retrieve full history
 	Filter(nodeid string) []Signal // Get specific history
 }
+
+// RunHistoryManager is a HistoryManager that can return and drop the history of a
+// single run
+type RunHistoryManager interface {
+	HistoryManager
+	FilterRun(runID string) []Signal // Get the history of a single run
+	RemoveRun(runID string)          // Drop the history of a single run
+}
+
+// CheckpointStore records the output signal of each node as a run progresses so that a
+// run interrupted part way through, for example by the process exiting, can be resumed
+// without repeating the work of the nodes that had already completed.
+type CheckpointStore interface {
+	Save(Signal) error                   // Record the output of Signal.NodeID for Signal.RunID
+	Load(runID string) ([]Signal, error) // Get the checkpoints of a run in the order they were saved
+	Runs() ([]string, error)             // Get the IDs of the runs with checkpoints
+	Remove(runID string) error           // Drop the checkpoints of a run
+}
@@ -0,0 +1,33 @@
+package node
+
+import "time"
+
+// Span records a timed operation of a run: a node processing a signal, a step of that
+// processing such as a hook, guidance or a send, or a request to an LLM. The spans of a
+// run share a TraceID derived from the run ID, and every span carries the IDs of the run,
+// node and signal it belongs to so it can be matched with the signal's lineage.
+type Span struct {
+	TraceID    string         `json:"trace_id"`             // Shared by every span of a run
+	SpanID     string         `json:"span_id"`              // Unique ID of the span
+	ParentID   string         `json:"parent_id,omitempty"`  // SpanID of the enclosing span, empty for the span of a node
+	Name       string         `json:"name"`                 // Operation, for example "node", "pre-hook" or "llm.chat"
+	RunID      string         `json:"run_id,omitempty"`     // Run the signal belongs to
+	NodeID     string         `json:"node_id,omitempty"`    // Node processing the signal
+	SignalID   string         `json:"signal_id,omitempty"`  // Signal being processed
+	Start      time.Time      `json:"start"`                // When the operation started
+	End        time.Time      `json:"end"`                  // When the operation ended
+	Attributes map[string]any `json:"attributes,omitempty"` // Details of the operation such as the LLM model
+	Err        string         `json:"err,omitempty"`        // Error the operation failed with
+}
+
+// Duration returns the time the operation took
+func (s Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// SpanExporter receives each span as it ends. Set one on the StateManager to trace runs.
+// ExportSpan is called from the goroutines processing signals and must be safe for
+// concurrent use.
+type SpanExporter interface {
+	ExportSpan(Span) error
+}
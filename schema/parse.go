@@ -47,6 +47,11 @@ func ParseJSONSchema(data map[string]interface{}) (Schema, error) {
 		schema.Type = t
 	}
 
+	// Handle "description"
+	if description, ok := data["description"].(string); ok {
+		schema.Description = description
+	}
+
 	// Handle "pattern"
 	if pattern, ok := data["pattern"].(string); ok {
 		schema.Pattern = pattern
@@ -21,8 +21,9 @@ func TestParseJSONSchema(t *testing.T) {
 				"maxLength": 10.0,
 			},
 			"age": map[string]interface{}{
-				"type":     "integer",
-				"minValue": 0.0,
+				"type":        "integer",
+				"description": "Age in years",
+				"minValue":    0.0,
 			},
 		},
 		"required": []interface{}{"field", "age"},
@@ -38,8 +39,9 @@ func TestParseJSONSchema(t *testing.T) {
 				MaxLength: intPointer(10),
 			},
 			"age": {
-				Type:     "integer",
-				MinValue: intPointer(0),
+				Type:        "integer",
+				Description: "Age in years",
+				MinValue:    intPointer(0),
 			},
 		},
 		Required: []string{"field", "age"},
@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// FromType returns a Schema describing the JSON encoding of values of type t, for
+// example the arguments of a tool. Struct fields are named by their json tag and
+// described by their description tag. Fields without omitempty are required.
+//
+// Example Usage:
+//
+//	type args struct {
+//	    City  string `json:"city" description:"Name of the city"`
+//	    Units string `json:"units,omitempty" description:"celsius or fahrenheit"`
+//	}
+//
+//	s := FromType(reflect.TypeOf(args{}))
+func FromType(t reflect.Type) Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{Type: "number"}
+	case reflect.String:
+		return Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{Type: "string"} // Encoded as base64
+		}
+		items := FromType(t.Elem())
+		return Schema{Type: "array", Items: &items}
+	case reflect.Struct:
+		if t == timeType {
+			return Schema{Type: "string", Format: "date-time"}
+		}
+		s := Schema{Type: "object", Properties: make(map[string]Schema)}
+		addFields(&s, t)
+		return s
+	}
+	return Schema{Type: "object"} // Maps and interfaces
+}
+
+// addFields adds the exported fields of the struct type t to s, including the fields
+// of embedded structs without a json name
+func addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			addFields(s, ft)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		prop := FromType(f.Type)
+		prop.Description = f.Tag.Get("description")
+		s.Properties[name] = prop
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
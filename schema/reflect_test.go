@@ -0,0 +1,48 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type reflectBase struct {
+	ID string `json:"id"`
+}
+
+type reflectArgs struct {
+	reflectBase
+	City    string    `json:"city" description:"Name of the city"`
+	Days    int       `json:"days,omitempty"`
+	Metric  *bool     `json:"metric,omitempty"`
+	Tags    []string  `json:"tags,omitempty"`
+	Since   time.Time `json:"since,omitempty"`
+	Ignored string    `json:"-"`
+	hidden  string
+}
+
+func TestFromType(t *testing.T) {
+	expected := Schema{
+		Type: "object",
+		Properties: map[string]Schema{
+			"id":     {Type: "string"},
+			"city":   {Type: "string", Description: "Name of the city"},
+			"days":   {Type: "integer"},
+			"metric": {Type: "boolean"},
+			"tags":   {Type: "array", Items: &Schema{Type: "string"}},
+			"since":  {Type: "string", Format: "date-time"},
+		},
+		Required: []string{"id", "city"},
+	}
+
+	s := FromType(reflect.TypeOf(&reflectArgs{}))
+	if !reflect.DeepEqual(s, expected) {
+		t.Errorf("Schema does not match expected schema. Got %+v, expected %+v", s, expected)
+	}
+
+	// Values encoded from the type validate against its schema
+	data := map[string]interface{}{"id": "1", "city": "Paris", "days": 3.0, "tags": []interface{}{"a"}}
+	if err := Validate(data, s, nil); err != nil {
+		t.Errorf("Expected valid data, got %v", err)
+	}
+}
@@ -19,22 +19,23 @@ func FromSchemaJSON(schemaBytes []byte) (Schema, error) {
 
 // Schema structure definition
 type Schema struct {
-	Type       string            `json:"type"`
-	Pattern    string            `json:"pattern,omitempty"`
-	Required   []string          `json:"required,omitempty"`
-	Properties map[string]Schema `json:"properties,omitempty"`
-	Enum       []interface{}     `json:"enum,omitempty"`
-	OneOf      []Schema          `json:"oneOf,omitempty"`
-	AnyOf      []Schema          `json:"anyOf,omitempty"`
-	AllOf      []Schema          `json:"allOf,omitempty"`
-	Not        *Schema           `json:"not,omitempty"`
-	MinValue   *int              `json:"minValue,omitempty"`
-	MinLength  *int              `json:"minLength,omitempty"`
-	MaxLength  *int              `json:"maxLength,omitempty"`
-	MinItems   *int              `json:"minItems,omitempty"`
-	MaxItems   *int              `json:"maxItems,omitempty"`
-	Items      *Schema           `json:"items,omitempty"`
-	Format     string            `json:"format,omitempty"`
+	Type        string            `json:"type"`
+	Description string            `json:"description,omitempty"`
+	Pattern     string            `json:"pattern,omitempty"`
+	Required    []string          `json:"required,omitempty"`
+	Properties  map[string]Schema `json:"properties,omitempty"`
+	Enum        []interface{}     `json:"enum,omitempty"`
+	OneOf       []Schema          `json:"oneOf,omitempty"`
+	AnyOf       []Schema          `json:"anyOf,omitempty"`
+	AllOf       []Schema          `json:"allOf,omitempty"`
+	Not         *Schema           `json:"not,omitempty"`
+	MinValue    *int              `json:"minValue,omitempty"`
+	MinLength   *int              `json:"minLength,omitempty"`
+	MaxLength   *int              `json:"maxLength,omitempty"`
+	MinItems    *int              `json:"minItems,omitempty"`
+	MaxItems    *int              `json:"maxItems,omitempty"`
+	Items       *Schema           `json:"items,omitempty"`
+	Format      string            `json:"format,omitempty"`
 }
 
 // ToJSON converts a Schema struct to a JSON string
@@ -56,6 +57,11 @@ func (schema Schema) toMap() map[string]interface{} {
 		result["type"] = schema.Type
 	}
 
+	// Set the "description" field
+	if schema.Description != "" {
+		result["description"] = schema.Description
+	}
+
 	// Set the "pattern" field
 	if schema.Pattern != "" {
 		result["pattern"] = schema.Pattern
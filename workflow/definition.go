@@ -0,0 +1,144 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format identifies the encoding of a workflow definition
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+)
+
+// Definition describes a workflow: the LLMs it uses, its nodes and how they are connected.
+type Definition struct {
+	Name  string            `json:"name,omitempty" yaml:"name,omitempty"`   // Name of the workflow
+	Entry string            `json:"entry,omitempty" yaml:"entry,omitempty"` // ID of the entry node, defaults to the first node
+	LLMs  map[string]LLMDef `json:"llms,omitempty" yaml:"llms,omitempty"`   // Named LLMs referenced by nodes
+	Nodes []NodeDef         `json:"nodes" yaml:"nodes"`                     // Nodes of the workflow
+}
+
+// LLMDef describes an LLM. The base URL and API key are read from the environment
+// so workflow files do not contain secrets. BaseURLEnv and APIKeyEnv default to
+// <PROVIDER>_API_URL and <PROVIDER>_API_KEY.
+type LLMDef struct {
+	Provider   string `json:"provider" yaml:"provider"`                             // openai, anthropic, gemini, mistral, ollama or a registered provider
+	Model      string `json:"model" yaml:"model"`                                   // Model name
+	BaseURL    string `json:"base_url,omitempty" yaml:"base_url,omitempty"`         // Base URL, overrides BaseURLEnv
+	BaseURLEnv string `json:"base_url_env,omitempty" yaml:"base_url_env,omitempty"` // Environment variable holding the base URL
+	APIKeyEnv  string `json:"api_key_env,omitempty" yaml:"api_key_env,omitempty"`   // Environment variable holding the API key
+	MaxTokens  int    `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`     // Maximum tokens, used by providers that require it
+}
+
+// NodeDef describes a single node. Which fields apply depends on the node type.
+type NodeDef struct {
+	ID            string            `json:"id" yaml:"id"`                                             // Unique node ID
+	Type          string            `json:"type" yaml:"type"`                                         // Registered node type
+	LLM           string            `json:"llm,omitempty" yaml:"llm,omitempty"`                       // Name of an LLM in Definition.LLMs
+	Guidance      *GuidanceDef      `json:"guidance,omitempty" yaml:"guidance,omitempty"`             // Prompt guidance
+	System        string            `json:"system,omitempty" yaml:"system,omitempty"`                 // AI nodes: system prompt
+	ErrorGuidance *ErrorGuidanceDef `json:"error_guidance,omitempty" yaml:"error_guidance,omitempty"` // Error handling
+	Hooks         *HooksDef         `json:"hooks,omitempty" yaml:"hooks,omitempty"`                   // Registered hooks
+	Timeout       string            `json:"timeout,omitempty" yaml:"timeout,omitempty"`               // Per signal timeout, e.g. "30s"
+	BufferSize    int               `json:"buffer_size,omitempty" yaml:"buffer_size,omitempty"`       // Capacity of the input channel
+	Backpressure  string            `json:"backpressure,omitempty" yaml:"backpressure,omitempty"`     // block (default), drop-oldest or error
+	Concurrency   int               `json:"concurrency,omitempty" yaml:"concurrency,omitempty"`       // Number of signals processed at once
+	Ordered       bool              `json:"ordered,omitempty" yaml:"ordered,omitempty"`               // Send output in the order signals were received
+	Schema        string            `json:"schema,omitempty" yaml:"schema,omitempty"`                 // JSON schema file used by validator nodes
+	Writer        string            `json:"writer,omitempty" yaml:"writer,omitempty"`                 // Output nodes: stdout (default) or stderr
+	Partition     string            `json:"partition,omitempty" yaml:"partition,omitempty"`           // Partitioner nodes: partition strategy
+	Integration   string            `json:"integration,omitempty" yaml:"integration,omitempty"`       // Partitioner nodes: integration strategy
+	Overlap       int               `json:"overlap,omitempty" yaml:"overlap,omitempty"`               // Sentence partitioning overlap
+	Worker        *NodeDef          `json:"worker,omitempty" yaml:"worker,omitempty"`                 // Partitioner nodes: node built for each partition
+	Start         string            `json:"start,omitempty" yaml:"start,omitempty"`                   // Loop and set nodes: ID of the start node
+	Final         string            `json:"final,omitempty" yaml:"final,omitempty"`                   // Set nodes: ID of the final node
+	Until         string            `json:"until,omitempty" yaml:"until,omitempty"`                   // Loop nodes: registered condition ending the loop
+	Branches      []BranchDef       `json:"branches,omitempty" yaml:"branches,omitempty"`             // Branch nodes: conditional targets
+	Upstream      []string          `json:"upstream,omitempty" yaml:"upstream,omitempty"`             // Join nodes: IDs of the nodes to wait for
+	Count         int               `json:"count,omitempty" yaml:"count,omitempty"`                   // Join nodes: number of signals to wait for
+	WaitTimeout   string            `json:"wait_timeout,omitempty" yaml:"wait_timeout,omitempty"`     // Join nodes: maximum wait, e.g. "10s"
+	Merge         string            `json:"merge,omitempty" yaml:"merge,omitempty"`                   // Join nodes: registered merge function
+	Next          []string          `json:"next,omitempty" yaml:"next,omitempty"`                     // IDs of the connected nodes
+	Params        map[string]any    `json:"params,omitempty" yaml:"params,omitempty"`                 // Parameters for custom node types
+}
+
+// GuidanceDef describes an nlib.SimpleGuidance
+type GuidanceDef struct {
+	Role           string   `json:"role,omitempty" yaml:"role,omitempty"`
+	Task           string   `json:"task,omitempty" yaml:"task,omitempty"`
+	TargetAudience string   `json:"target_audience,omitempty" yaml:"target_audience,omitempty"`
+	Goal           string   `json:"goal,omitempty" yaml:"goal,omitempty"`
+	Steps          []string `json:"steps,omitempty" yaml:"steps,omitempty"`
+	OutputFormat   string   `json:"output_format,omitempty" yaml:"output_format,omitempty"`
+	Tone           string   `json:"tone,omitempty" yaml:"tone,omitempty"`
+	Schema         string   `json:"schema,omitempty" yaml:"schema,omitempty"` // JSON schema file describing the output
+}
+
+// ErrorGuidanceDef selects an error guidance strategy: http, retry or ignore
+type ErrorGuidanceDef struct {
+	Strategy  string `json:"strategy" yaml:"strategy"`
+	Retries   int    `json:"retries,omitempty" yaml:"retries,omitempty"`
+	BaseDelay string `json:"base_delay,omitempty" yaml:"base_delay,omitempty"` // e.g. "500ms"
+}
+
+// HooksDef names registered hooks run before and after a node processes a signal
+type HooksDef struct {
+	Before string `json:"before,omitempty" yaml:"before,omitempty"`
+	After  string `json:"after,omitempty" yaml:"after,omitempty"`
+}
+
+// BranchDef sends the signal to Target when the registered condition When is met
+type BranchDef struct {
+	When   string `json:"when" yaml:"when"`
+	Target string `json:"target" yaml:"target"`
+}
+
+// Parse decodes a workflow definition
+func Parse(data []byte, format Format) (*Definition, error) {
+	def := Definition{}
+	switch format {
+	case FormatJSON:
+		if err := json.Unmarshal(data, &def); err != nil {
+			return nil, fmt.Errorf("workflow: parse json: %w", err)
+		}
+	case FormatYAML:
+		if err := yaml.Unmarshal(data, &def); err != nil {
+			return nil, fmt.Errorf("workflow: parse yaml: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("workflow: unknown format %q", format)
+	}
+	return &def, nil
+}
+
+// ParseFile reads and decodes a workflow definition. The format is chosen by
+// the file extension: .json, .yaml or .yml.
+func ParseFile(path string) (*Definition, error) {
+	format, err := formatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("workflow: %w", err)
+	}
+	return Parse(data, format)
+}
+
+func formatFromPath(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	}
+	return "", fmt.Errorf("workflow: unknown file type %s", path)
+}
@@ -0,0 +1,380 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/node"
+	"github.com/dshills/wiggle/schema"
+)
+
+// Workflow is a live graph of nodes built from a Definition
+type Workflow struct {
+	Name         string            // Name of the workflow
+	Entry        node.Node         // Node that receives the input signal
+	StateManager node.StateManager // State manager shared by every node
+	nodes        map[string]node.Node
+	order        []string
+}
+
+// Node returns the node with the given ID or nil
+func (w *Workflow) Node(id string) node.Node {
+	return w.nodes[id]
+}
+
+// Nodes returns the nodes in the order they were defined
+func (w *Workflow) Nodes() []node.Node {
+	nodes := []node.Node{}
+	for _, id := range w.order {
+		nodes = append(nodes, w.nodes[id])
+	}
+	return nodes
+}
+
+// Graph returns a Graph managing the lifecycle of the workflow's nodes
+func (w *Workflow) Graph() *nlib.Graph {
+	return nlib.NewGraph(w.Entry)
+}
+
+// Validate checks the workflow with nlib.Validate. Defined nodes that cannot
+// be reached from the entry node are reported as unreachable.
+func (w *Workflow) Validate() error {
+	return nlib.Validate(w.Entry, w.Nodes()...)
+}
+
+// Run sends the input to the entry node and waits for the run to finish. See nlib.Run.
+func (w *Workflow) Run(ctx context.Context, input node.Signal) (node.Signal, error) {
+	return nlib.Run(ctx, w.Entry, input)
+}
+
+// Replay runs a recorded run again, answering LLM calls from the recording. See nlib.Replay.
+func (w *Workflow) Replay(ctx context.Context, rec *nlib.Recording) (node.Signal, error) {
+	return nlib.Replay(ctx, w.Entry, rec)
+}
+
+// Resume continues an interrupted run from the checkpoints of the workflow's
+// StateManager. See nlib.Resume.
+func (w *Workflow) Resume(ctx context.Context, runID string) (node.Signal, error) {
+	return nlib.Resume(ctx, w.Entry, runID)
+}
+
+// Loader builds workflows using the node types and functions of a Registry
+type Loader struct {
+	Registry     *Registry
+	StateManager node.StateManager
+}
+
+// NewLoader returns a Loader. A nil registry uses NewRegistry and a nil
+// state manager uses a new nlib.SimpleStateManager.
+func NewLoader(reg *Registry, mgr node.StateManager) *Loader {
+	if reg == nil {
+		reg = NewRegistry()
+	}
+	if mgr == nil {
+		mgr = nlib.NewSimpleStateManager(nil)
+	}
+	return &Loader{Registry: reg, StateManager: mgr}
+}
+
+// LoadFile reads a workflow file and builds it. Relative file paths in the
+// definition, such as schema files, are resolved from the file's directory.
+func (l *Loader) LoadFile(path string) (*Workflow, error) {
+	def, err := ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return l.Build(def, filepath.Dir(path))
+}
+
+// Load decodes a workflow definition and builds it
+func (l *Loader) Load(data []byte, format Format) (*Workflow, error) {
+	def, err := Parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+	return l.Build(def, "")
+}
+
+// Build constructs every node of the definition and connects them.
+// dir is used to resolve relative file paths.
+func (l *Loader) Build(def *Definition, dir string) (*Workflow, error) {
+	b := &builder{loader: l, def: def, dir: dir, llms: make(map[string]llm.LLM), schemas: make(map[string]schema.Schema)}
+	wf := &Workflow{Name: def.Name, StateManager: l.StateManager, nodes: make(map[string]node.Node)}
+
+	if len(def.Nodes) == 0 {
+		return nil, fmt.Errorf("workflow: no nodes defined")
+	}
+
+	// Construct the nodes
+	for _, nd := range def.Nodes {
+		if nd.ID == "" {
+			return nil, fmt.Errorf("workflow: node of type %q has no id", nd.Type)
+		}
+		if _, ok := wf.nodes[nd.ID]; ok {
+			return nil, fmt.Errorf("workflow: duplicate node id %s", nd.ID)
+		}
+		n, err := b.build(nd)
+		if err != nil {
+			return nil, fmt.Errorf("workflow: node %s: %w", nd.ID, err)
+		}
+		wf.nodes[nd.ID] = n
+		wf.order = append(wf.order, nd.ID)
+	}
+
+	// Connect them
+	for _, nd := range def.Nodes {
+		if err := b.connect(wf, nd); err != nil {
+			return nil, fmt.Errorf("workflow: node %s: %w", nd.ID, err)
+		}
+	}
+
+	entry := def.Entry
+	if entry == "" {
+		entry = def.Nodes[0].ID
+	}
+	if wf.Entry = wf.nodes[entry]; wf.Entry == nil {
+		return nil, fmt.Errorf("workflow: unknown entry node %s", entry)
+	}
+	return wf, nil
+}
+
+// builder holds the state of a single Build. It is used after the Build returns to
+// build the workers of partitioners, so the LLMs and schemas it caches are guarded by mu.
+type builder struct {
+	loader  *Loader
+	def     *Definition
+	dir     string
+	mu      sync.Mutex
+	llms    map[string]llm.LLM
+	schemas map[string]schema.Schema
+}
+
+// build constructs a single node without connecting it
+func (b *builder) build(nd NodeDef) (node.Node, error) {
+	fn, err := b.loader.Registry.nodeConstructor(nd.Type)
+	if err != nil {
+		return nil, err
+	}
+	bc := BuildContext{
+		Def:          nd,
+		StateManager: b.loader.StateManager,
+		Registry:     b.loader.Registry,
+		Dir:          b.dir,
+		build:        b.build,
+		schema:       b.schema,
+	}
+	if bc.Options, err = b.options(bc); err != nil {
+		return nil, err
+	}
+	if nd.LLM != "" {
+		if bc.LLM, err = b.llm(nd.LLM); err != nil {
+			return nil, err
+		}
+	}
+	return fn(bc)
+}
+
+// options builds the node.Options described by a node definition
+func (b *builder) options(bc BuildContext) (node.Options, error) {
+	nd := bc.Def
+	options := node.Options{ID: nd.ID}
+
+	if nd.Timeout != "" {
+		d, err := time.ParseDuration(nd.Timeout)
+		if err != nil {
+			return options, fmt.Errorf("timeout: %w", err)
+		}
+		options.Timeout = d
+	}
+
+	options.BufferSize = nd.BufferSize
+	options.Concurrency = nd.Concurrency
+	options.Ordered = nd.Ordered
+	switch policy := node.BackpressurePolicy(nd.Backpressure); policy {
+	case "", node.BackpressureBlock, node.BackpressureDropOldest, node.BackpressureError:
+		options.Backpressure = policy
+	default:
+		return options, fmt.Errorf("unknown backpressure policy %q", nd.Backpressure)
+	}
+
+	if nd.Hooks != nil {
+		var before, after node.HookFn
+		var err error
+		if nd.Hooks.Before != "" {
+			if before, err = bc.Registry.Hook(nd.Hooks.Before); err != nil {
+				return options, err
+			}
+		}
+		if nd.Hooks.After != "" {
+			if after, err = bc.Registry.Hook(nd.Hooks.After); err != nil {
+				return options, err
+			}
+		}
+		options.Hooks = nlib.NewSimpleNodeHooks(before, after)
+	}
+
+	if g := nd.Guidance; g != nil {
+		guide := &nlib.SimpleGuidance{
+			Role:           g.Role,
+			Task:           g.Task,
+			TargetAudience: g.TargetAudience,
+			Goal:           g.Goal,
+			Steps:          g.Steps,
+			OutputFormat:   g.OutputFormat,
+			Tone:           g.Tone,
+		}
+		if g.Schema != "" {
+			sc, err := bc.Schema(g.Schema)
+			if err != nil {
+				return options, fmt.Errorf("guidance: %w", err)
+			}
+			guide.Schema = &sc
+		}
+		options.Guidance = guide
+	}
+
+	if eg := nd.ErrorGuidance; eg != nil {
+		var delay time.Duration
+		if eg.BaseDelay != "" {
+			var err error
+			if delay, err = time.ParseDuration(eg.BaseDelay); err != nil {
+				return options, fmt.Errorf("error_guidance: %w", err)
+			}
+		}
+		switch eg.Strategy {
+		case "http":
+			options.ErrorGuidance = nlib.NewHTTPErrorGuidance(eg.Retries, delay)
+		case "retry":
+			options.ErrorGuidance = nlib.NewRetryErrorGuidance(eg.Retries, delay)
+		case "ignore":
+			options.ErrorGuidance = nlib.NewIgnoreErrorGuidance()
+		default:
+			return options, fmt.Errorf("unknown error guidance strategy %q", eg.Strategy)
+		}
+	}
+
+	return options, nil
+}
+
+// schema returns the schema at path, reading it the first time it is used
+func (b *builder) schema(path string) (schema.Schema, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if sc, ok := b.schemas[path]; ok {
+		return sc, nil
+	}
+	sc, err := readSchema(path)
+	if err != nil {
+		return sc, err
+	}
+	b.schemas[path] = sc
+	return sc, nil
+}
+
+// llm returns the named LLM, building it the first time it is used
+func (b *builder) llm(name string) (llm.LLM, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if lm, ok := b.llms[name]; ok {
+		return lm, nil
+	}
+	def, ok := b.def.LLMs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown llm %s", name)
+	}
+	fn, err := b.loader.Registry.llmConstructor(def.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.ToUpper(def.Provider)
+	if def.BaseURL == "" {
+		env := def.BaseURLEnv
+		if env == "" {
+			env = prefix + "_API_URL"
+		}
+		def.BaseURL = os.Getenv(env)
+	}
+	keyEnv := def.APIKeyEnv
+	if keyEnv == "" {
+		keyEnv = prefix + "_API_KEY"
+	}
+
+	lm, err := fn(def, os.Getenv(keyEnv))
+	if err != nil {
+		return nil, fmt.Errorf("llm %s: %w", name, err)
+	}
+	b.llms[name] = lm
+	return lm, nil
+}
+
+// connect wires a node to the nodes it references
+func (b *builder) connect(wf *Workflow, nd NodeDef) error {
+	n := wf.nodes[nd.ID]
+	lookup := func(id string) (node.Node, error) {
+		if target := wf.nodes[id]; target != nil {
+			return target, nil
+		}
+		return nil, fmt.Errorf("unknown node %s", id)
+	}
+
+	for _, id := range nd.Next {
+		target, err := lookup(id)
+		if err != nil {
+			return err
+		}
+		n.Connect(target)
+	}
+
+	if len(nd.Branches) > 0 {
+		branch, ok := n.(node.BranchNode)
+		if !ok {
+			return fmt.Errorf("branches defined on a %s node", nd.Type)
+		}
+		for _, bd := range nd.Branches {
+			target, err := lookup(bd.Target)
+			if err != nil {
+				return err
+			}
+			cond, err := b.loader.Registry.Condition(bd.When)
+			if err != nil {
+				return err
+			}
+			branch.AddConditional(node.BranchCondition{Target: target, ConditionFn: cond})
+		}
+	}
+
+	switch t := n.(type) {
+	case node.LoopNode:
+		if nd.Start == "" {
+			return fmt.Errorf("loop node requires a start node")
+		}
+		start, err := lookup(nd.Start)
+		if err != nil {
+			return err
+		}
+		t.SetStartNode(start)
+	case node.SetNode:
+		if nd.Start == "" || nd.Final == "" {
+			return fmt.Errorf("set node requires start and final nodes")
+		}
+		start, err := lookup(nd.Start)
+		if err != nil {
+			return err
+		}
+		final, err := lookup(nd.Final)
+		if err != nil {
+			return err
+		}
+		t.SetStartNode(start)
+		t.SetFinalNode(final)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package workflow_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/nmock"
+	"github.com/dshills/wiggle/node"
+	"github.com/dshills/wiggle/workflow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newTestRegistry returns a registry with a mock LLM provider, a custom
+// "suffix" node type and a few named functions
+func newTestRegistry() *workflow.Registry {
+	reg := workflow.NewRegistry()
+	reg.RegisterLLM("mock", func(def workflow.LLMDef, _ string) (llm.LLM, error) {
+		lm := new(nmock.MockLLM)
+		lm.On("Model").Return(def.Model)
+		lm.On("Chat", mock.Anything, mock.Anything).Return(llm.Message{Role: llm.RoleAssistant, Content: "HELLO"}, nil)
+		return lm, nil
+	})
+	reg.RegisterNode("suffix", func(bc workflow.BuildContext) (node.Node, error) {
+		suffix, _ := bc.Def.Params["text"].(string)
+		after := func(sig node.Signal) (node.Signal, error) {
+			sig.Result = nlib.NewTextCarrier(sig.Task.String() + suffix)
+			return sig, nil
+		}
+		bc.Options.Hooks = nlib.NewSimpleNodeHooks(nil, after)
+		return nlib.NewSimpleBranchNode(bc.StateManager, bc.Options), nil
+	})
+	reg.RegisterHook("passthrough", func(sig node.Signal) (node.Signal, error) {
+		sig.Result = sig.Task
+		return sig, nil
+	})
+	reg.RegisterCondition("short", func(sig node.Signal) bool { return len(sig.Task.String()) < 5 })
+	reg.RegisterCondition("three", func(sig node.Signal) bool { return len(sig.Task.String()) >= 3 })
+	return reg
+}
+
+const chainYAML = `
+name: chain
+llms:
+  fast:
+    provider: mock
+    model: mock-1
+nodes:
+  - id: ask
+    type: ai
+    llm: fast
+    system: You answer in capitals.
+    timeout: 5s
+    error_guidance:
+      strategy: retry
+      retries: 2
+      base_delay: 10ms
+    next: [shout]
+  - id: shout
+    type: suffix
+    params:
+      text: "!"
+`
+
+func TestLoader_YAMLChain(t *testing.T) {
+	loader := workflow.NewLoader(newTestRegistry(), nil)
+	wf, err := loader.Load([]byte(chainYAML), workflow.FormatYAML)
+	assert.NoError(t, err)
+	assert.Equal(t, "chain", wf.Name)
+	assert.Equal(t, "ask", wf.Entry.ID())
+	assert.Len(t, wf.Nodes(), 2)
+
+	msgs := wf.Node("ask").(*nlib.AINode).Messages(node.Signal{Task: nlib.NewTextCarrier("hello")})
+	assert.Equal(t, llm.MessageList{llm.SystemMsg("You answer in capitals."), llm.UserMsg("hello")}, msgs)
+
+	sig, err := wf.Run(context.Background(), node.Signal{Task: nlib.NewTextCarrier("hello")})
+	assert.NoError(t, err)
+	assert.Equal(t, "shout", sig.NodeID)
+	assert.Equal(t, "HELLO!", sig.Result.String())
+}
+
+const branchJSON = `{
+  "nodes": [
+    {"id": "route", "type": "branch", "hooks": {"after": "passthrough"},
+     "branches": [{"when": "short", "target": "small"}], "next": ["large"]},
+    {"id": "small", "type": "suffix", "params": {"text": " (short)"}},
+    {"id": "large", "type": "suffix", "params": {"text": " (long)"}}
+  ]
+}`
+
+func TestLoader_JSONBranch(t *testing.T) {
+	loader := workflow.NewLoader(newTestRegistry(), nil)
+	wf, err := loader.Load([]byte(branchJSON), workflow.FormatJSON)
+	assert.NoError(t, err)
+
+	graph := wf.Graph()
+	graph.Start(context.Background())
+	defer graph.Stop()
+
+	sig, err := wf.Run(context.Background(), node.Signal{Task: nlib.NewTextCarrier("hi")})
+	assert.NoError(t, err)
+	assert.Equal(t, "hi (short)", sig.Result.String())
+
+	sig, err = wf.Run(context.Background(), node.Signal{Task: nlib.NewTextCarrier("hello world")})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world (long)", sig.Result.String())
+}
+
+const loopYAML = `
+entry: add
+nodes:
+  - id: add
+    type: suffix
+    params: {text: x}
+    next: [loop]
+  - id: loop
+    type: loop
+    start: add
+    until: three
+    hooks: {after: passthrough}
+    next: [done]
+  - id: done
+    type: suffix
+`
+
+func TestLoader_Loop(t *testing.T) {
+	loader := workflow.NewLoader(newTestRegistry(), nil)
+	wf, err := loader.Load([]byte(loopYAML), workflow.FormatYAML)
+	assert.NoError(t, err)
+
+	sig, err := wf.Run(context.Background(), node.Signal{Task: nlib.NewTextCarrier("")})
+	assert.NoError(t, err)
+	assert.Equal(t, "xxx", sig.Result.String())
+}
+
+func TestLoader_LoadFileResolvesSchema(t *testing.T) {
+	dir := t.TempDir()
+	schemaJSON := `{"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]}`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "person.json"), []byte(schemaJSON), 0o600))
+	wfYAML := "nodes:\n  - id: check\n    type: validator\n    schema: person.json\n"
+	path := filepath.Join(dir, "workflow.yml")
+	assert.NoError(t, os.WriteFile(path, []byte(wfYAML), 0o600))
+
+	wf, err := workflow.NewLoader(nil, nil).LoadFile(path)
+	assert.NoError(t, err)
+	assert.IsType(t, &nlib.JSONValidatorNode{}, wf.Node("check"))
+}
+
+const partitionerYAML = `
+nodes:
+  - id: split
+    type: partitioner
+    partition: lines
+    worker:
+      type: suffix
+      params: {text: "!"}
+`
+
+// newPartitionerRegistry returns the test registry with a partition strategy splitting lines
+func newPartitionerRegistry() *workflow.Registry {
+	reg := newTestRegistry()
+	reg.RegisterPartitioner("lines", func(s string) ([]string, error) { return strings.Split(s, "\n"), nil })
+	return reg
+}
+
+func TestLoader_PartitionerWorkerIDs(t *testing.T) {
+	mgr := nlib.NewSimpleStateManager(nil)
+	wf, err := workflow.NewLoader(newPartitionerRegistry(), mgr).Load([]byte(partitionerYAML), workflow.FormatYAML)
+	assert.NoError(t, err)
+
+	// workers returns the IDs of the workers that processed the signals of a run
+	workers := func(runID string) []string {
+		ids := []string{}
+		for _, sig := range mgr.FilterRunHistory(runID) {
+			if strings.HasPrefix(sig.NodeID, "split-worker-") {
+				ids = append(ids, sig.NodeID)
+			}
+		}
+		return ids
+	}
+
+	first, err := wf.Run(context.Background(), node.Signal{Task: nlib.NewTextCarrier("a\nb")})
+	assert.NoError(t, err)
+	assert.Contains(t, first.Result.String(), "a!")
+	assert.Contains(t, first.Result.String(), "b!")
+	second, err := wf.Run(context.Background(), node.Signal{Task: nlib.NewTextCarrier("c\nd")})
+	assert.NoError(t, err)
+
+	firstWorkers, secondWorkers := workers(first.RunID), workers(second.RunID)
+	assert.NotEmpty(t, firstWorkers)
+	assert.NotEmpty(t, secondWorkers)
+	for _, id := range firstWorkers {
+		assert.NotContains(t, secondWorkers, id, "each signal has its own workers")
+	}
+}
+
+func TestLoader_PartitionerWorkerBuildError(t *testing.T) {
+	reg := newPartitionerRegistry()
+	builds := 0
+	reg.RegisterNode("flaky", func(bc workflow.BuildContext) (node.Node, error) {
+		if builds++; builds > 1 {
+			return nil, fmt.Errorf("out of workers")
+		}
+		return nlib.NewSimpleBranchNode(bc.StateManager, bc.Options), nil
+	})
+	yaml := "nodes:\n  - id: split\n    type: partitioner\n    partition: lines\n    worker: {type: flaky}\n"
+	wf, err := workflow.NewLoader(reg, nil).Load([]byte(yaml), workflow.FormatYAML)
+	assert.NoError(t, err, "the worker is built once when the workflow is loaded")
+
+	_, err = wf.Run(context.Background(), node.Signal{Task: nlib.NewTextCarrier("a\nb")})
+	assert.ErrorContains(t, err, "creating partition nodes: worker split-worker-")
+	assert.ErrorContains(t, err, "out of workers")
+}
+
+func TestLoader_PartitionerReadsSchemaOnce(t *testing.T) {
+	dir := t.TempDir()
+	schemaJSON := `{"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]}`
+	schemaPath := filepath.Join(dir, "person.json")
+	assert.NoError(t, os.WriteFile(schemaPath, []byte(schemaJSON), 0o600))
+	wfYAML := "nodes:\n  - id: split\n    type: partitioner\n    partition: lines\n    worker: {type: validator, schema: person.json}\n"
+	path := filepath.Join(dir, "workflow.yml")
+	assert.NoError(t, os.WriteFile(path, []byte(wfYAML), 0o600))
+
+	wf, err := workflow.NewLoader(newPartitionerRegistry(), nil).LoadFile(path)
+	assert.NoError(t, err)
+
+	// The workers built for the signal use the schema read when the workflow was loaded
+	assert.NoError(t, os.Remove(schemaPath))
+	sig, err := wf.Run(context.Background(), node.Signal{Task: nlib.NewTextCarrier("{\"name\": \"a\"}\n{\"name\": \"b\"}")})
+	assert.NoError(t, err)
+	assert.Contains(t, sig.Result.String(), `"b"`)
+}
+
+func TestLoader_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{"unknown type", "nodes:\n  - {id: a, type: nope}\n", `unknown node type "nope"`},
+		{"duplicate id", "nodes:\n  - {id: a, type: suffix}\n  - {id: a, type: suffix}\n", "duplicate node id a"},
+		{"unknown target", "nodes:\n  - {id: a, type: suffix, next: [b]}\n", "unknown node b"},
+		{"unknown llm", "nodes:\n  - {id: a, type: ai, llm: missing}\n", "unknown llm missing"},
+		{"unknown condition", "nodes:\n  - {id: a, type: loop, start: a, until: never}\n", `unknown condition "never"`},
+		{"bad timeout", "nodes:\n  - {id: a, type: suffix, timeout: soon}\n", "timeout"},
+		{"bad backpressure", "nodes:\n  - {id: a, type: suffix, backpressure: later}\n", `unknown backpressure policy "later"`},
+		{"missing entry", "entry: b\nnodes:\n  - {id: a, type: suffix}\n", "unknown entry node b"},
+	}
+
+	loader := workflow.NewLoader(newTestRegistry(), nil)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := loader.Load([]byte(tc.yaml), workflow.FormatYAML)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err, fmt.Sprintf("error: %v", err))
+			}
+		})
+	}
+}
+
+func TestWorkflow_ValidateReportsUnreachable(t *testing.T) {
+	loader := workflow.NewLoader(newTestRegistry(), nil)
+	wf, err := loader.Load([]byte("nodes:\n  - {id: a, type: suffix}\n  - {id: b, type: suffix}\n"), workflow.FormatYAML)
+	assert.NoError(t, err)
+
+	err = wf.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "b: not reachable from a")
+	}
+}
@@ -0,0 +1,403 @@
+package workflow
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dshills/wiggle/llm"
+	"github.com/dshills/wiggle/llm/anthropic"
+	"github.com/dshills/wiggle/llm/gemini"
+	"github.com/dshills/wiggle/llm/mistral"
+	"github.com/dshills/wiggle/llm/ollama"
+	"github.com/dshills/wiggle/llm/openai"
+	"github.com/dshills/wiggle/nlib"
+	"github.com/dshills/wiggle/node"
+	"github.com/dshills/wiggle/schema"
+)
+
+// NodeConstructor builds a node from its definition
+type NodeConstructor func(bc BuildContext) (node.Node, error)
+
+// LLMConstructor builds an LLM from its definition. BaseURL has been resolved from
+// the environment and apiKey read from APIKeyEnv.
+type LLMConstructor func(def LLMDef, apiKey string) (llm.LLM, error)
+
+// BuildContext is passed to a NodeConstructor
+type BuildContext struct {
+	Def          NodeDef           // The node definition
+	Options      node.Options      // ID, hooks, guidance, error guidance and timeout built from Def
+	StateManager node.StateManager // State manager shared by the workflow
+	LLM          llm.LLM           // The node's LLM, nil if Def.LLM is not set
+	Registry     *Registry         // Registry used to build the workflow
+	Dir          string            // Directory of the workflow file, used to resolve relative paths
+	build        func(NodeDef) (node.Node, error)
+	schema       func(path string) (schema.Schema, error)
+}
+
+// Path resolves a path relative to the workflow file
+func (bc BuildContext) Path(path string) string {
+	if path == "" || filepath.IsAbs(path) || bc.Dir == "" {
+		return path
+	}
+	return filepath.Join(bc.Dir, path)
+}
+
+// Schema reads the JSON schema at path, resolved relative to the workflow file. A file is
+// read once per workflow, so nodes built while the workflow runs, such as the workers of
+// a partitioner, reuse the schema read when it was built.
+func (bc BuildContext) Schema(path string) (schema.Schema, error) {
+	if bc.schema == nil {
+		return readSchema(bc.Path(path))
+	}
+	return bc.schema(bc.Path(path))
+}
+
+// Build constructs a standalone node from a definition, such as the worker of a partitioner.
+// Connections of the definition are not resolved.
+func (bc BuildContext) Build(def NodeDef) (node.Node, error) {
+	return bc.build(def)
+}
+
+// Registry holds the node types, LLM providers and named functions a workflow can reference.
+type Registry struct {
+	mu           sync.RWMutex
+	nodes        map[string]NodeConstructor
+	llms         map[string]LLMConstructor
+	conditions   map[string]node.ConditionFn
+	hooks        map[string]node.HookFn
+	partitioners map[string]node.PartitionerFn
+	integrators  map[string]node.IntegratorFn
+	mergers      map[string]node.MergeFn
+}
+
+// NewRegistry returns a Registry with the nlib node types, the LLM providers
+// and the nlib partition and integration strategies registered.
+func NewRegistry() *Registry {
+	r := &Registry{
+		nodes:        make(map[string]NodeConstructor),
+		llms:         make(map[string]LLMConstructor),
+		conditions:   make(map[string]node.ConditionFn),
+		hooks:        make(map[string]node.HookFn),
+		partitioners: make(map[string]node.PartitionerFn),
+		integrators:  make(map[string]node.IntegratorFn),
+		mergers:      make(map[string]node.MergeFn),
+	}
+
+	r.RegisterNode("ai", newAINode)
+	r.RegisterNode("output", newOutputNode)
+	r.RegisterNode("reader", newReaderNode)
+	r.RegisterNode("interactive", newInteractiveNode)
+	r.RegisterNode("validator", newValidatorNode)
+	r.RegisterNode("branch", newBranchNode)
+	r.RegisterNode("loop", newLoopNode)
+	r.RegisterNode("set", newSetNode)
+	r.RegisterNode("partitioner", newPartitionerNode)
+	r.RegisterNode("join", newJoinNode)
+
+	r.RegisterLLM("openai", func(def LLMDef, apiKey string) (llm.LLM, error) {
+		return openai.New(def.BaseURL, def.Model, apiKey, nil), nil
+	})
+	r.RegisterLLM("anthropic", func(def LLMDef, apiKey string) (llm.LLM, error) {
+		return anthropic.New(def.BaseURL, def.Model, apiKey, def.MaxTokens), nil
+	})
+	r.RegisterLLM("gemini", func(def LLMDef, apiKey string) (llm.LLM, error) {
+		return gemini.New(def.BaseURL, def.Model, apiKey, nil), nil
+	})
+	r.RegisterLLM("mistral", func(def LLMDef, apiKey string) (llm.LLM, error) {
+		return mistral.New(def.BaseURL, def.Model, apiKey, nil), nil
+	})
+	r.RegisterLLM("ollama", func(def LLMDef, _ string) (llm.LLM, error) {
+		return ollama.New(def.BaseURL, def.Model, nil), nil
+	})
+
+	r.RegisterPartitioner("semantic", nlib.SemanticChunkingPartition)
+	r.RegisterPartitioner("task", nlib.TaskBasedPartitioning)
+	r.RegisterIntegrator("concatenate", nlib.SimpleConcatenationIntegrator)
+	r.RegisterMerge("tasks", nlib.MergeTasks)
+
+	return r
+}
+
+// RegisterNode registers a constructor for a node type, replacing any existing one
+func (r *Registry) RegisterNode(typ string, fn NodeConstructor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodes[typ] = fn
+}
+
+// RegisterLLM registers a constructor for an LLM provider, replacing any existing one
+func (r *Registry) RegisterLLM(provider string, fn LLMConstructor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.llms[provider] = fn
+}
+
+// RegisterCondition registers a condition used by branch and loop nodes
+func (r *Registry) RegisterCondition(name string, fn node.ConditionFn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.conditions[name] = fn
+}
+
+// RegisterHook registers a hook function used in node hooks
+func (r *Registry) RegisterHook(name string, fn node.HookFn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks[name] = fn
+}
+
+// RegisterPartitioner registers a partition strategy used by partitioner nodes
+func (r *Registry) RegisterPartitioner(name string, fn node.PartitionerFn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.partitioners[name] = fn
+}
+
+// RegisterIntegrator registers an integration strategy used by partitioner nodes
+func (r *Registry) RegisterIntegrator(name string, fn node.IntegratorFn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.integrators[name] = fn
+}
+
+// RegisterMerge registers a merge function used by join nodes
+func (r *Registry) RegisterMerge(name string, fn node.MergeFn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mergers[name] = fn
+}
+
+func (r *Registry) nodeConstructor(typ string) (NodeConstructor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if fn, ok := r.nodes[typ]; ok {
+		return fn, nil
+	}
+	return nil, fmt.Errorf("unknown node type %q", typ)
+}
+
+func (r *Registry) llmConstructor(provider string) (LLMConstructor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if fn, ok := r.llms[provider]; ok {
+		return fn, nil
+	}
+	return nil, fmt.Errorf("unknown LLM provider %q", provider)
+}
+
+// Condition returns a registered condition
+func (r *Registry) Condition(name string) (node.ConditionFn, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if fn, ok := r.conditions[name]; ok {
+		return fn, nil
+	}
+	return nil, fmt.Errorf("unknown condition %q", name)
+}
+
+// Hook returns a registered hook
+func (r *Registry) Hook(name string) (node.HookFn, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if fn, ok := r.hooks[name]; ok {
+		return fn, nil
+	}
+	return nil, fmt.Errorf("unknown hook %q", name)
+}
+
+// Partitioner returns a registered partition strategy
+func (r *Registry) Partitioner(name string) (node.PartitionerFn, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if fn, ok := r.partitioners[name]; ok {
+		return fn, nil
+	}
+	return nil, fmt.Errorf("unknown partition strategy %q", name)
+}
+
+// Integrator returns a registered integration strategy
+func (r *Registry) Integrator(name string) (node.IntegratorFn, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if fn, ok := r.integrators[name]; ok {
+		return fn, nil
+	}
+	return nil, fmt.Errorf("unknown integration strategy %q", name)
+}
+
+// Merge returns a registered merge function
+func (r *Registry) Merge(name string) (node.MergeFn, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if fn, ok := r.mergers[name]; ok {
+		return fn, nil
+	}
+	return nil, fmt.Errorf("unknown merge function %q", name)
+}
+
+func newAINode(bc BuildContext) (node.Node, error) {
+	if bc.LLM == nil {
+		return nil, fmt.Errorf("ai node requires an llm")
+	}
+	n := nlib.NewAINode(bc.LLM, bc.StateManager, bc.Options)
+	n.SetSystemPrompt(bc.Def.System)
+	return n, nil
+}
+
+func newOutputNode(bc BuildContext) (node.Node, error) {
+	var w io.Writer
+	switch bc.Def.Writer {
+	case "", "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	default:
+		return nil, fmt.Errorf("unknown writer %q", bc.Def.Writer)
+	}
+	return nlib.NewOutputStringNode(w, bc.StateManager, bc.Options), nil
+}
+
+func newReaderNode(bc BuildContext) (node.Node, error) {
+	return nlib.NewSimpleStringReaderNode(os.Stdin, bc.StateManager, bc.Options), nil
+}
+
+func newInteractiveNode(bc BuildContext) (node.Node, error) {
+	return nlib.NewInteractiveNode(bc.StateManager, bc.Options), nil
+}
+
+func newValidatorNode(bc BuildContext) (node.Node, error) {
+	if bc.Def.Schema == "" {
+		return nil, fmt.Errorf("validator node requires a schema")
+	}
+	sc, err := bc.Schema(bc.Def.Schema)
+	if err != nil {
+		return nil, err
+	}
+	return nlib.NewJSONValidatorNode(bc.StateManager, sc, bc.Options), nil
+}
+
+// Branch conditions are added when the workflow is connected
+func newBranchNode(bc BuildContext) (node.Node, error) {
+	return nlib.NewSimpleBranchNode(bc.StateManager, bc.Options), nil
+}
+
+// The start node is set when the workflow is connected
+func newLoopNode(bc BuildContext) (node.Node, error) {
+	var cond node.ConditionFn
+	if bc.Def.Until != "" {
+		var err error
+		if cond, err = bc.Registry.Condition(bc.Def.Until); err != nil {
+			return nil, err
+		}
+	}
+	return nlib.NewSimpleLoopNode(nil, cond, bc.StateManager, bc.Options), nil
+}
+
+// The start and final nodes are set when the workflow is connected
+func newSetNode(bc BuildContext) (node.Node, error) {
+	return nlib.NewSimpleSetNode(nil, nil, bc.StateManager, bc.Options), nil
+}
+
+func newPartitionerNode(bc BuildContext) (node.Node, error) {
+	def := bc.Def
+	if def.Worker == nil {
+		return nil, fmt.Errorf("partitioner node requires a worker")
+	}
+
+	var pfn node.PartitionerFn
+	switch def.Partition {
+	case "", "sentence":
+		overlap := def.Overlap
+		if overlap < 1 {
+			overlap = 3
+		}
+		pfn = func(s string) ([]string, error) { return nlib.SentenceSplittingWithOverlap(s, overlap) }
+	default:
+		var err error
+		if pfn, err = bc.Registry.Partitioner(def.Partition); err != nil {
+			return nil, err
+		}
+	}
+
+	var ifn node.IntegratorFn
+	switch def.Integration {
+	case "coherence":
+		if bc.LLM == nil {
+			return nil, fmt.Errorf("coherence integration requires an llm")
+		}
+		lm := bc.LLM
+		ifn = func(parts []string) (string, error) { return nlib.CoherenceRewritingIntegrator(lm, parts) }
+	case "":
+		ifn = nlib.SimpleConcatenationIntegrator
+	default:
+		var err error
+		if ifn, err = bc.Registry.Integrator(def.Integration); err != nil {
+			return nil, err
+		}
+	}
+
+	// Check the worker can be built before any signal is processed
+	worker := *def.Worker
+	if worker.ID == "" {
+		worker.ID = def.ID + "-worker"
+	}
+	if _, err := bc.Build(worker); err != nil {
+		return nil, fmt.Errorf("worker: %w", err)
+	}
+
+	// The workers of each signal get their own IDs so their history can be told apart
+	factory := func(count int) ([]node.Node, error) {
+		suffix, err := nlib.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		nodes := []node.Node{}
+		for i := 0; i < count; i++ {
+			wd := worker
+			wd.ID = fmt.Sprintf("%s-%s-%d", worker.ID, suffix, i)
+			n, err := bc.Build(wd)
+			if err != nil {
+				return nil, fmt.Errorf("worker %s: %w", wd.ID, err)
+			}
+			nodes = append(nodes, n)
+		}
+		return nodes, nil
+	}
+	n := nlib.NewSimplePartitionerNode(pfn, ifn, nil, bc.StateManager, bc.Options)
+	n.SetNodeFactoryFn(factory)
+	return n, nil
+}
+
+func newJoinNode(bc BuildContext) (node.Node, error) {
+	def := bc.Def
+	var mfn node.MergeFn
+	if def.Merge != "" {
+		var err error
+		if mfn, err = bc.Registry.Merge(def.Merge); err != nil {
+			return nil, err
+		}
+	}
+	n := nlib.NewSimpleJoinNode(mfn, bc.StateManager, bc.Options)
+	n.SetUpstream(def.Upstream...)
+	n.SetCount(def.Count)
+	if def.WaitTimeout != "" {
+		d, err := time.ParseDuration(def.WaitTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("wait_timeout: %w", err)
+		}
+		n.SetWaitTimeout(d)
+	}
+	return n, nil
+}
+
+func readSchema(path string) (schema.Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return schema.Schema{}, err
+	}
+	return schema.FromSchemaJSON(data)
+}